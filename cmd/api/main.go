@@ -2,14 +2,23 @@ package main
 
 import (
 	"log"
+	"math/rand"
+	"os"
+	"quoridor/internal/auth"
 	"quoridor/internal/config"
+	"quoridor/internal/cors"
 	"quoridor/internal/database"
 	"quoridor/internal/events"
 	"quoridor/internal/game"
 	"quoridor/internal/matchmaking"
+	"quoridor/internal/ratings"
 	"quoridor/internal/router"
 	"quoridor/internal/server"
 	"quoridor/internal/sockets"
+	"quoridor/internal/users"
+	"time"
+
+	"github.com/google/uuid"
 )
 
 func main() {
@@ -20,21 +29,88 @@ func main() {
 	cfg := config.ReadConfig()
 	database := database.SetupDatabase(cfg)
 
+	instanceId := newInstanceId()
 	eventService := events.NewEventService()
 
+	userRepository := users.NewMongoUserRepository(database, "users")
+	userService := users.NewUserService(userRepository)
+	userHandler := users.NewUserHandler(userService)
+
+	tokenService := auth.NewJwtTokenService([]byte(cfg.JwtSecret), cfg.TokenExpiry)
+	authHandler := auth.NewAuthHandler(userService, tokenService)
+
+	ratingRepository := ratings.NewMongoRatingRepository(database, "ratings")
+	ratingService := ratings.NewRatingService(ratingRepository)
+
 	gameEngine := game.NewGameEngine()
-	gameRepository := game.NewMongoGameRepository(database, "games")
-	gameService := game.NewGameService(gameEngine, gameRepository)
+	var hotGameRepository game.GameRepository
+	switch cfg.GameStorage {
+	case "event_sourced":
+		hotGameRepository = game.NewEventSourcedGameRepository(database, "games", "game_events")
+	default:
+		hotGameRepository = game.NewMongoGameRepository(database, "games")
+	}
+	gameArchiveRepository := game.NewMongoGameArchiveRepository(database, "games_archive")
+	gameRepository := game.NewArchiveAwareGameRepository(hotGameRepository, gameArchiveRepository)
+	gameBot := game.NewShortestPathBot(gameEngine, rand.New(rand.NewSource(time.Now().UnixNano())))
+	gameService := game.NewGameService(gameEngine, gameRepository, userService, ratingService, gameBot)
+	gameHandler := game.NewGameHandler(gameService)
+
+	gameArchiver := game.NewGameArchiver(hotGameRepository, gameArchiveRepository, cfg.ArchiveAfter, cfg.AbandonedGameTTL, cfg.ArchiveInterval)
+	gameArchiver.StartArchiving()
 
-	mmQueue := matchmaking.NewInMemoryMatchmakingQueue()
-	mmService := matchmaking.NewMatchmakingService(mmQueue, eventService)
+	var mmQueue matchmaking.MatchmakingQueue
+	switch cfg.MatchmakingQueue {
+	case "mongo":
+		mmStore := matchmaking.NewMongoMatchmakingStore(database, "matchmaking_queue")
+		mmQueue = matchmaking.NewDistributedMatchmakingQueue(instanceId, mmStore, matchmaking.NewDefaultRatingWindowScorer(), matchmaking.NewSystemClock())
+	default:
+		mmQueue = matchmaking.NewInMemoryMatchmakingQueue(matchmaking.NewDefaultRatingWindowScorer(), matchmaking.NewSystemClock())
+	}
+	mmService := matchmaking.NewMatchmakingService(mmQueue, eventService, ratingService, userService, cfg.BotMatchAfter)
 	mmService.StartMatchmaking()
 
-	websocketService := sockets.NewWebsocketService(mmService, gameService)
-	websocketHandler := sockets.NewWebsocketHandler(websocketService)
+	// a single instance needs no broker, several instances need an adapter of a shared broker instead
+	var broker sockets.MessageBroker
+	switch cfg.BackplaneBroker {
+	case "memory":
+		broker = sockets.NewInProcessBroker()
+	default:
+		log.Fatalf("Unknown BACKPLANE_BROKER: %v", cfg.BackplaneBroker)
+	}
+	backplane := sockets.NewBrokerBackplane(instanceId, broker)
+	websocketService := sockets.NewWebsocketService(mmService, gameService, backplane, sockets.SessionPolicy(cfg.SessionPolicy))
+	heartbeat := sockets.HeartbeatConfig{PingInterval: cfg.WsPingInterval, PongWait: cfg.WsPongWait, WriteWait: cfg.WsWriteWait}
+	rateLimiter := sockets.NewRateLimiter(sockets.RateLimitConfig{
+		MessagesPerSecond:     cfg.WsRateLimit,
+		Burst:                 cfg.WsRateBurst,
+		UserMessagesPerSecond: cfg.WsUserRateLimit,
+		UserBurst:             cfg.WsUserRateBurst,
+		MaxViolations:         cfg.WsMaxRateViolations,
+		MaxMessageSize:        cfg.WsMaxMessageSize,
+	})
+	origins := cors.NewOriginPolicy(cfg.AllowedOrigins)
+	websocketHandler := sockets.NewWebsocketHandler(websocketService, tokenService, heartbeat, sockets.OverflowPolicy(cfg.WsOverflowPolicy), rateLimiter, origins, sockets.NewConnectionMetrics())
 
 	eventService.RegisterHandler(events.EventTypeMatchFound, websocketService.HandleMatchFound)
+	eventService.RegisterHandler(events.EventTypeBotMatchFound, websocketService.HandleBotMatchFound)
+	eventService.RegisterHandler(events.EventTypeQueueStatus, websocketService.HandleQueueStatus)
 
-	router := router.NewRouter(websocketHandler)
+	if err := websocketService.ResumeGames(); err != nil {
+		log.Fatalf("Failed to resume games in progress: %v", err)
+	}
+
+	server.ServeInternal(router.NewInternalRouter(websocketHandler), cfg.InternalAddr)
+
+	router := router.NewRouter(websocketHandler, gameHandler, userHandler, authHandler, tokenService, origins)
 	server.Serve(router)
 }
+
+// unique per process, so instances on the same host don't share the matchmaking lock
+func newInstanceId() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+	return hostname + "-" + uuid.NewString()
+}
@@ -0,0 +1,114 @@
+package auth
+
+import (
+	"log"
+	"net/http"
+	"quoridor/internal/errors"
+	"quoridor/internal/users"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AuthHandler interface {
+	HandleLogin(c *gin.Context)
+	HandleGuestLogin(c *gin.Context)
+	HandleUpgradeGuest(c *gin.Context)
+}
+
+type AuthHandlerImpl struct {
+	userService  users.UserService
+	tokenService TokenService
+}
+
+func NewAuthHandler(userService users.UserService, tokenService TokenService) *AuthHandlerImpl {
+	return &AuthHandlerImpl{
+		userService:  userService,
+		tokenService: tokenService,
+	}
+}
+
+// POST https://quoridory.domain.io/v1/auth/token
+func (handler *AuthHandlerImpl) HandleLogin(c *gin.Context) {
+	request := LoginRequest{}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		log.Printf("Failed to unmarshal login request: err=%v", err)
+		c.JSON(http.StatusBadRequest, errors.ErrorResponse{ErrorType: errors.ErrBadRequest.Error()})
+		return
+	}
+
+	user, err := handler.userService.Authenticate(request.Username, request.Password)
+	if err != nil {
+		c.JSON(errors.HttpStatus(err), errors.ErrorResponse{ErrorType: err.Error()})
+		return
+	}
+
+	token, err := handler.tokenService.IssueToken(user.UserId, false)
+	if err != nil {
+		c.JSON(errors.HttpStatus(err), errors.ErrorResponse{ErrorType: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, token)
+}
+
+// POST https://quoridory.domain.io/v1/auth/guest
+func (handler *AuthHandlerImpl) HandleGuestLogin(c *gin.Context) {
+	user, err := handler.userService.RegisterGuest()
+	if err != nil {
+		c.JSON(errors.HttpStatus(err), errors.ErrorResponse{ErrorType: err.Error()})
+		return
+	}
+
+	token, err := handler.tokenService.IssueToken(user.UserId, true)
+	if err != nil {
+		c.JSON(errors.HttpStatus(err), errors.ErrorResponse{ErrorType: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, token)
+}
+
+/*
+POST https://quoridory.domain.io/v1/auth/upgrade
+the guest is identified by its token, a new token for the registered user is returned
+*/
+func (handler *AuthHandlerImpl) HandleUpgradeGuest(c *gin.Context) {
+	claims, err := handler.tokenService.VerifyToken(TokenFromRequest(c))
+	if err != nil {
+		c.JSON(errors.HttpStatus(err), errors.ErrorResponse{ErrorType: err.Error()})
+		return
+	}
+
+	request := users.RegisterUserRequest{}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		log.Printf("Failed to unmarshal guest upgrade request: err=%v", err)
+		c.JSON(http.StatusBadRequest, errors.ErrorResponse{ErrorType: errors.ErrBadRequest.Error()})
+		return
+	}
+
+	user, err := handler.userService.UpgradeGuest(claims.Subject, &request)
+	if err != nil {
+		c.JSON(errors.HttpStatus(err), errors.ErrorResponse{ErrorType: err.Error()})
+		return
+	}
+
+	token, err := handler.tokenService.IssueToken(user.UserId, false)
+	if err != nil {
+		c.JSON(errors.HttpStatus(err), errors.ErrorResponse{ErrorType: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, token)
+}
+
+/*
+the token is taken from the "Authorization: Bearer <token>" header,
+or from the "token" query parameter since browsers can't set headers on websocket requests
+*/
+func TokenFromRequest(c *gin.Context) string {
+	if token, found := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer "); found {
+		return token
+	}
+	return c.Query("token")
+}
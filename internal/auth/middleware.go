@@ -0,0 +1,42 @@
+package auth
+
+import (
+	"net/http"
+	"quoridor/internal/errors"
+
+	"github.com/gin-gonic/gin"
+)
+
+// key of the id of the authenticated user in the gin context
+const USER_ID_KEY = "auth_user_id"
+
+// rejects the requests without a valid token, the id of the user is set in the context for the next handlers
+func RequireToken(tokenService TokenService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := TokenFromRequest(c)
+		if token == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, errors.ErrorResponse{ErrorType: errors.ErrUnauthorized.Error()})
+			return
+		}
+
+		claims, err := tokenService.VerifyToken(token)
+		if err != nil {
+			c.AbortWithStatusJSON(errors.HttpStatus(err), errors.ErrorResponse{ErrorType: err.Error()})
+			return
+		}
+
+		c.Set(USER_ID_KEY, claims.Subject)
+		c.Next()
+	}
+}
+
+// must run after RequireToken, rejects the requests for resources of other users
+func RequireOwner(param string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString(USER_ID_KEY) != c.Param(param) {
+			c.AbortWithStatusJSON(http.StatusForbidden, errors.ErrorResponse{ErrorType: errors.ErrForbidden.Error()})
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,71 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestRouter(tokenService TokenService) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.PATCH("/v1/users/:user_id", RequireToken(tokenService), RequireOwner("user_id"), func(c *gin.Context) {
+		c.String(http.StatusOK, c.GetString(USER_ID_KEY))
+	})
+	return router
+}
+
+func patchUser(router *gin.Engine, userId, token string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(http.MethodPatch, "/v1/users/"+userId, nil)
+	if token != "" {
+		request.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func TestRequireToken_givenOwnerToken_shouldPassUserId(t *testing.T) {
+	service := NewJwtTokenService(generateSecret(t), time.Hour)
+	router := newTestRouter(service)
+
+	token, err := service.IssueToken("user1", false)
+	assert.NoError(t, err)
+
+	response := patchUser(router, "user1", token.Token)
+
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.Equal(t, "user1", response.Body.String())
+}
+
+func TestRequireToken_givenNoOrInvalidToken_shouldReject(t *testing.T) {
+	service := NewJwtTokenService(generateSecret(t), time.Hour)
+	router := newTestRouter(service)
+
+	otherToken, err := NewJwtTokenService(generateSecret(t), time.Hour).IssueToken("user1", false)
+	assert.NoError(t, err)
+
+	for _, token := range []string{"", "invalid", otherToken.Token} {
+		response := patchUser(router, "user1", token)
+
+		assert.Equal(t, http.StatusUnauthorized, response.Code)
+	}
+}
+
+func TestRequireOwner_givenTokenOfOtherUser_shouldReject(t *testing.T) {
+	service := NewJwtTokenService(generateSecret(t), time.Hour)
+	router := newTestRouter(service)
+
+	token, err := service.IssueToken("user2", false)
+	assert.NoError(t, err)
+
+	response := patchUser(router, "user1", token.Token)
+
+	assert.Equal(t, http.StatusForbidden, response.Code)
+	assert.JSONEq(t, `{"error_type":"forbidden"}`, response.Body.String())
+}
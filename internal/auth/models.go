@@ -0,0 +1,25 @@
+package auth
+
+import (
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type LoginRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+type TokenResponse struct {
+	UserId    string    `json:"user_id"`
+	Guest     bool      `json:"guest,omitempty"`
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// the id of the user is stored in the subject claim
+type TokenClaims struct {
+	jwt.RegisteredClaims
+	Guest bool `json:"guest,omitempty"`
+}
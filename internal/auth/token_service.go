@@ -0,0 +1,86 @@
+package auth
+
+import (
+	"log"
+	"quoridor/internal/errors"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+const TOKEN_ISSUER = "quoridor"
+
+type TokenService interface {
+	IssueToken(userId string, guest bool) (*TokenResponse, error)
+	VerifyToken(token string) (*TokenClaims, error)
+}
+
+// issues and verifies HMAC-SHA256 signed JWTs
+type JwtTokenService struct {
+	secret []byte
+	expiry time.Duration
+}
+
+func NewJwtTokenService(secret []byte, expiry time.Duration) *JwtTokenService {
+	return &JwtTokenService{
+		secret: secret,
+		expiry: expiry,
+	}
+}
+
+func (service *JwtTokenService) IssueToken(userId string, guest bool) (*TokenResponse, error) {
+	log.Printf("Issuing token: userId=%v, guest=%v", userId, guest)
+
+	now := time.Now()
+	expiresAt := now.Add(service.expiry)
+	claims := TokenClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
+			Issuer:    TOKEN_ISSUER,
+			Subject:   userId,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+		Guest: guest,
+	}
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(service.secret)
+	if err != nil {
+		log.Printf("Error while signing the token. userId=%v, err=%v", userId, err)
+		return nil, errors.ErrInternalError
+	}
+
+	return &TokenResponse{
+		UserId:    userId,
+		Guest:     guest,
+		Token:     token,
+		ExpiresAt: expiresAt,
+	}, nil
+}
+
+// returns the claims of a valid token, the subject is the id of the user the token was issued for
+func (service *JwtTokenService) VerifyToken(token string) (*TokenClaims, error) {
+	claims := &TokenClaims{}
+	_, err := jwt.ParseWithClaims(
+		token,
+		claims,
+		func(*jwt.Token) (interface{}, error) {
+			return service.secret, nil
+		},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithIssuer(TOKEN_ISSUER),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		log.Printf("Invalid token: err=%v", err)
+		return nil, errors.ErrUnauthorized
+	}
+
+	if claims.Subject == "" {
+		log.Println("Invalid token: subject is missing")
+		return nil, errors.ErrUnauthorized
+	}
+
+	return claims, nil
+}
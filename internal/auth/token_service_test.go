@@ -0,0 +1,99 @@
+package auth
+
+import (
+	"crypto/rand"
+	"testing"
+	"time"
+
+	"quoridor/internal/errors"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func generateSecret(t *testing.T) []byte {
+	secret := make([]byte, 32)
+	_, err := rand.Read(secret)
+	assert.NoError(t, err)
+	return secret
+}
+
+func TestIssueToken(t *testing.T) {
+	service := NewJwtTokenService(generateSecret(t), time.Hour)
+
+	token, err := service.IssueToken("user1", false)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "user1", token.UserId)
+	assert.NotEmpty(t, token.Token)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), token.ExpiresAt, time.Second)
+
+	claims, err := service.VerifyToken(token.Token)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "user1", claims.Subject)
+	assert.False(t, claims.Guest)
+}
+
+func TestIssueToken_givenGuest_shouldIncludeGuestClaim(t *testing.T) {
+	service := NewJwtTokenService(generateSecret(t), time.Hour)
+
+	token, err := service.IssueToken("guest1", true)
+	assert.NoError(t, err)
+	assert.True(t, token.Guest)
+
+	claims, err := service.VerifyToken(token.Token)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "guest1", claims.Subject)
+	assert.True(t, claims.Guest)
+}
+
+func TestVerifyToken_givenExpiredToken_shouldReturnError(t *testing.T) {
+	service := NewJwtTokenService(generateSecret(t), -time.Minute)
+
+	token, err := service.IssueToken("user1", false)
+	assert.NoError(t, err)
+
+	_, err = service.VerifyToken(token.Token)
+
+	assert.ErrorIs(t, err, errors.ErrUnauthorized)
+}
+
+func TestVerifyToken_givenTokenSignedWithAnotherSecret_shouldReturnError(t *testing.T) {
+	service := NewJwtTokenService(generateSecret(t), time.Hour)
+	otherService := NewJwtTokenService(generateSecret(t), time.Hour)
+
+	token, err := otherService.IssueToken("user1", false)
+	assert.NoError(t, err)
+
+	_, err = service.VerifyToken(token.Token)
+
+	assert.ErrorIs(t, err, errors.ErrUnauthorized)
+}
+
+func TestVerifyToken_givenUnsignedToken_shouldReturnError(t *testing.T) {
+	service := NewJwtTokenService(generateSecret(t), time.Hour)
+
+	claims := TokenClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    TOKEN_ISSUER,
+			Subject:   "user1",
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodNone, claims).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	assert.NoError(t, err)
+
+	_, err = service.VerifyToken(token)
+
+	assert.ErrorIs(t, err, errors.ErrUnauthorized)
+}
+
+func TestVerifyToken_givenMalformedToken_shouldReturnError(t *testing.T) {
+	service := NewJwtTokenService(generateSecret(t), time.Hour)
+
+	_, err := service.VerifyToken("not a token")
+
+	assert.ErrorIs(t, err, errors.ErrUnauthorized)
+}
@@ -2,6 +2,8 @@ package config
 
 import (
 	"log"
+	"slices"
+	"time"
 
 	"github.com/spf13/viper"
 )
@@ -10,7 +12,41 @@ type Config struct {
 	Port        int    `mapstructure:"PORT"`
 	AppEnv      string `mapstructure:"APP_ENV"`
 
+	InternalAddr string `mapstructure:"INTERNAL_ADDR"` // serves the stats of the instance, must not be reachable from the internet
+
+	AllowedOrigins []string `mapstructure:"ALLOWED_ORIGINS"` // comma separated, "*" allows every origin
+
 	DatabaseURI string `mapstructure:"DATABASE_URI"`
+
+	JwtSecret   string        `mapstructure:"JWT_SECRET"`
+	TokenExpiry time.Duration `mapstructure:"TOKEN_EXPIRY"`
+
+	GameStorage string `mapstructure:"GAME_STORAGE"` // "document" or "event_sourced"
+
+	ArchiveAfter     time.Duration `mapstructure:"ARCHIVE_AFTER"`
+	AbandonedGameTTL time.Duration `mapstructure:"ABANDONED_GAME_TTL"`
+	ArchiveInterval  time.Duration `mapstructure:"ARCHIVE_INTERVAL"`
+
+	BotMatchAfter time.Duration `mapstructure:"BOT_MATCH_AFTER"` // 0 disables games against bots
+
+	MatchmakingQueue string `mapstructure:"MATCHMAKING_QUEUE"` // "memory" or "mongo", the latter is shared by all instances
+
+	BackplaneBroker string `mapstructure:"BACKPLANE_BROKER"` // "memory", instances sharing the queue need a broker shared by all of them
+
+	SessionPolicy string `mapstructure:"SESSION_POLICY"` // "multiple" or "kick_older"
+
+	WsPingInterval time.Duration `mapstructure:"WS_PING_INTERVAL"`
+	WsPongWait     time.Duration `mapstructure:"WS_PONG_WAIT"` // has to be longer than the ping interval
+	WsWriteWait    time.Duration `mapstructure:"WS_WRITE_WAIT"`
+
+	WsOverflowPolicy string `mapstructure:"WS_OVERFLOW_POLICY"` // "drop_oldest" or "disconnect", for clients that read too slowly
+
+	WsRateLimit         float64 `mapstructure:"WS_RATE_LIMIT"` // messages per second of a connection
+	WsRateBurst         int     `mapstructure:"WS_RATE_BURST"`
+	WsUserRateLimit     float64 `mapstructure:"WS_USER_RATE_LIMIT"` // messages per second of all connections of a user
+	WsUserRateBurst     int     `mapstructure:"WS_USER_RATE_BURST"`
+	WsMaxRateViolations int     `mapstructure:"WS_MAX_RATE_VIOLATIONS"` // throttled messages a minute before the connection is closed
+	WsMaxMessageSize    int64   `mapstructure:"WS_MAX_MESSAGE_SIZE"`    // bytes
 }
 
 func ReadConfig() *Config {
@@ -18,16 +54,56 @@ func ReadConfig() *Config {
 	viper.SetConfigFile(".env")
 	viper.AutomaticEnv()
 
+	viper.SetDefault("INTERNAL_ADDR", "127.0.0.1:8081")
+	viper.SetDefault("TOKEN_EXPIRY", "24h")
+	viper.SetDefault("GAME_STORAGE", "document")
+	viper.SetDefault("ARCHIVE_AFTER", "720h")
+	viper.SetDefault("ABANDONED_GAME_TTL", "24h")
+	viper.SetDefault("ARCHIVE_INTERVAL", "1h")
+	viper.SetDefault("BOT_MATCH_AFTER", "2m")
+	viper.SetDefault("MATCHMAKING_QUEUE", "memory")
+	viper.SetDefault("BACKPLANE_BROKER", "memory")
+	viper.SetDefault("SESSION_POLICY", "multiple")
+	viper.SetDefault("WS_PING_INTERVAL", "30s")
+	viper.SetDefault("WS_PONG_WAIT", "60s")
+	viper.SetDefault("WS_WRITE_WAIT", "10s")
+	viper.SetDefault("WS_OVERFLOW_POLICY", "drop_oldest")
+	viper.SetDefault("WS_RATE_LIMIT", 5)
+	viper.SetDefault("WS_RATE_BURST", 10)
+	viper.SetDefault("WS_USER_RATE_LIMIT", 10)
+	viper.SetDefault("WS_USER_RATE_BURST", 20)
+	viper.SetDefault("WS_MAX_RATE_VIOLATIONS", 20)
+	viper.SetDefault("WS_MAX_MESSAGE_SIZE", 4096)
+
 	err := viper.ReadInConfig()
 	if err != nil {
 		log.Fatalf("Can't find config file: %v", err)
 	}
 
+	viper.SetDefault("ALLOWED_ORIGINS", defaultAllowedOrigins(viper.GetString("APP_ENV")))
+
 	err = viper.Unmarshal(&config)
 	if err != nil {
 		log.Fatalf("Failed to load configuration: %v", err)
 	}
 
+	if config.JwtSecret == "" {
+		log.Fatal("JWT_SECRET is required")
+	}
+
+	if config.WsPingInterval >= config.WsPongWait {
+		log.Fatal("WS_PING_INTERVAL must be shorter than WS_PONG_WAIT")
+	}
+
+	// users matched on different instances would never get each other's game messages
+	if config.MatchmakingQueue == "mongo" && config.BackplaneBroker == "memory" {
+		log.Fatal("MATCHMAKING_QUEUE 'mongo' requires a BACKPLANE_BROKER shared by all instances, 'memory' works within one instance only")
+	}
+
+	if config.AppEnv == "prod" && slices.Contains(config.AllowedOrigins, "*") {
+		log.Fatal("ALLOWED_ORIGINS can't allow every origin in 'prod' env")
+	}
+
 	switch config.AppEnv {
 	case "local":
 		log.Println("Service is running on 'local' env")
@@ -38,4 +114,18 @@ func ReadConfig() *Config {
 	}
 
 	return &config
-}
\ No newline at end of file
+}
+
+// web clients of the env, the ones run by developers on their machines are allowed outside of prod
+func defaultAllowedOrigins(env string) []string {
+	switch env {
+	case "local":
+		return []string{"*"}
+	case "dev":
+		return []string{"https://dev.quoridory.domain.io", "http://localhost:3000"}
+	case "prod":
+		return []string{"https://quoridory.domain.io"}
+	default:
+		return []string{}
+	}
+}
@@ -0,0 +1,88 @@
+package cors
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// allows every origin, only meant for local development
+const ANY_ORIGIN = "*"
+
+const (
+	ALLOWED_METHODS   = "GET, POST, PATCH, OPTIONS"
+	ALLOWED_HEADERS   = "Authorization, Content-Type"
+	PREFLIGHT_MAX_AGE = "600"
+)
+
+// origins of the web clients allowed to call the api and open websockets, e.g. https://quoridory.domain.io
+type OriginPolicy struct {
+	origins   map[string]bool
+	anyOrigin bool
+}
+
+func NewOriginPolicy(origins []string) *OriginPolicy {
+	policy := &OriginPolicy{origins: map[string]bool{}}
+	for _, origin := range origins {
+		origin = normalizeOrigin(origin)
+		if origin == ANY_ORIGIN {
+			policy.anyOrigin = true
+		} else if origin != "" {
+			policy.origins[origin] = true
+		}
+	}
+	return policy
+}
+
+func (p *OriginPolicy) Allowed(origin string) bool {
+	return p.anyOrigin || p.origins[normalizeOrigin(origin)]
+}
+
+/*
+check of the websocket upgrade. browsers always send the origin, so requests without it come from other clients,
+e.g. the mobile apps, which can't open sockets on behalf of a user that visits another website
+*/
+func (p *OriginPolicy) CheckOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	return origin == "" || p.Allowed(origin)
+}
+
+/*
+sets the cors headers for the allowed origins and answers their preflight requests.
+the responses to other origins have no cors headers, so browsers don't let the scripts of those origins read them
+*/
+func Middleware(policy *OriginPolicy) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		if origin == "" {
+			c.Next()
+			return
+		}
+
+		c.Header("Vary", "Origin")
+		allowed := policy.Allowed(origin)
+		if allowed {
+			c.Header("Access-Control-Allow-Origin", origin)
+		}
+
+		if c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != "" {
+			if !allowed {
+				c.AbortWithStatus(http.StatusForbidden)
+				return
+			}
+
+			c.Header("Access-Control-Allow-Methods", ALLOWED_METHODS)
+			c.Header("Access-Control-Allow-Headers", ALLOWED_HEADERS)
+			c.Header("Access-Control-Max-Age", PREFLIGHT_MAX_AGE)
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func normalizeOrigin(origin string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(origin)), "/")
+}
@@ -0,0 +1,105 @@
+package cors
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestRouter(origins ...string) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(Middleware(NewOriginPolicy(origins)))
+	router.GET("/v1/health", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	return router
+}
+
+func serve(router *gin.Engine, method, origin string, preflight bool) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(method, "/v1/health", nil)
+	if origin != "" {
+		request.Header.Set("Origin", origin)
+	}
+	if preflight {
+		request.Header.Set("Access-Control-Request-Method", http.MethodGet)
+	}
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func TestOriginPolicy_Allowed(t *testing.T) {
+	policy := NewOriginPolicy([]string{"https://quoridory.domain.io/", " http://localhost:3000"})
+
+	assert.True(t, policy.Allowed("https://quoridory.domain.io"))
+	assert.True(t, policy.Allowed("HTTPS://Quoridory.domain.io"))
+	assert.True(t, policy.Allowed("http://localhost:3000"))
+	assert.False(t, policy.Allowed("http://quoridory.domain.io"))
+	assert.False(t, policy.Allowed("https://evil.example.com"))
+	assert.False(t, policy.Allowed(""))
+
+	assert.True(t, NewOriginPolicy([]string{ANY_ORIGIN}).Allowed("https://evil.example.com"))
+	assert.False(t, NewOriginPolicy(nil).Allowed("https://quoridory.domain.io"))
+}
+
+func TestOriginPolicy_CheckOrigin(t *testing.T) {
+	policy := NewOriginPolicy([]string{"https://quoridory.domain.io"})
+
+	request := httptest.NewRequest(http.MethodGet, "/v1/ws", nil)
+	assert.True(t, policy.CheckOrigin(request))
+
+	request.Header.Set("Origin", "https://quoridory.domain.io")
+	assert.True(t, policy.CheckOrigin(request))
+
+	request.Header.Set("Origin", "https://evil.example.com")
+	assert.False(t, policy.CheckOrigin(request))
+}
+
+func TestMiddleware_givenAllowedOrigin_shouldSetHeaders(t *testing.T) {
+	router := newTestRouter("https://quoridory.domain.io")
+
+	response := serve(router, http.MethodGet, "https://quoridory.domain.io", false)
+
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.Equal(t, "https://quoridory.domain.io", response.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "Origin", response.Header().Get("Vary"))
+}
+
+func TestMiddleware_givenOtherOrigin_shouldNotSetHeaders(t *testing.T) {
+	router := newTestRouter("https://quoridory.domain.io")
+
+	response := serve(router, http.MethodGet, "https://evil.example.com", false)
+
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.Empty(t, response.Header().Get("Access-Control-Allow-Origin"))
+}
+
+func TestMiddleware_givenPreflight_shouldAnswerAllowedOriginsOnly(t *testing.T) {
+	router := newTestRouter("https://quoridory.domain.io")
+
+	response := serve(router, http.MethodOptions, "https://quoridory.domain.io", true)
+
+	assert.Equal(t, http.StatusNoContent, response.Code)
+	assert.Equal(t, "https://quoridory.domain.io", response.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, ALLOWED_METHODS, response.Header().Get("Access-Control-Allow-Methods"))
+	assert.Equal(t, ALLOWED_HEADERS, response.Header().Get("Access-Control-Allow-Headers"))
+
+	response = serve(router, http.MethodOptions, "https://evil.example.com", true)
+
+	assert.Equal(t, http.StatusForbidden, response.Code)
+	assert.Empty(t, response.Header().Get("Access-Control-Allow-Origin"))
+}
+
+func TestMiddleware_givenNoOrigin_shouldPassThrough(t *testing.T) {
+	router := newTestRouter()
+
+	response := serve(router, http.MethodGet, "", false)
+
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.Empty(t, response.Header().Get("Vary"))
+}
@@ -0,0 +1,26 @@
+package errors
+
+import "net/http"
+
+type ErrorResponse struct {
+	ErrorType string `json:"error_type"`
+}
+
+func HttpStatus(err error) int {
+	switch err {
+	case ErrBadRequest, ErrUnsupportedVersion:
+		return http.StatusBadRequest
+	case ErrInvalidCredentials, ErrUnauthorized:
+		return http.StatusUnauthorized
+	case ErrForbidden:
+		return http.StatusForbidden
+	case ErrGameNotFound, ErrUserNotFound:
+		return http.StatusNotFound
+	case ErrUsernameTaken, ErrNotAGuest:
+		return http.StatusConflict
+	case ErrRateLimited:
+		return http.StatusTooManyRequests
+	default:
+		return http.StatusInternalServerError
+	}
+}
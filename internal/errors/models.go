@@ -11,4 +11,13 @@ var (
 	ErrInvalidMove          = errors.New("invalid_move")
 	ErrInvalidWallPlacement = errors.New("invalid_wall_placement")
 	ErrNotAPlayer           = errors.New("not_a_player")
+	ErrUserNotFound         = errors.New("user_not_found")
+	ErrUsernameTaken        = errors.New("username_taken")
+	ErrNotAGuest            = errors.New("not_a_guest")
+	ErrRankedNotAllowed     = errors.New("ranked_not_allowed")
+	ErrInvalidCredentials   = errors.New("invalid_credentials")
+	ErrUnauthorized         = errors.New("unauthorized")
+	ErrForbidden            = errors.New("forbidden")
+	ErrUnsupportedVersion   = errors.New("unsupported_version")
+	ErrRateLimited          = errors.New("rate_limited")
 )
@@ -3,7 +3,9 @@ package events
 type EventType string
 
 const (
-	EventTypeMatchFound EventType = "match_found"
+	EventTypeMatchFound    EventType = "match_found"
+	EventTypeBotMatchFound EventType = "bot_match_found"
+	EventTypeQueueStatus   EventType = "queue_status"
 )
 
 type Event struct {
@@ -0,0 +1,168 @@
+package game
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+var (
+	pawnMoveNotation = regexp.MustCompile(`^([a-z])(\d+)$`)
+	wallNotation     = regexp.MustCompile(`^([a-z])(\d+)([a-z])(\d+)([hv])$`)
+)
+
+func newArchivedGame(state *Game, archivedAt time.Time) (*ArchivedGame, error) {
+	record, err := encodeGameRecord(state)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ArchivedGame{
+		GameId:      state.GameId,
+		GameStatus:  state.GameStatus,
+		Variant:     state.Variant,
+		EndReason:   state.EndReason,
+		Winner:      state.Winner,
+		Player1:     &PlayerSummary{UserId: state.Player1.UserId, DisplayName: state.Player1.DisplayName},
+		Player2:     &PlayerSummary{UserId: state.Player2.UserId, DisplayName: state.Player2.DisplayName},
+		MovesCount:  len(state.Moves),
+		CreatedAt:   state.CreatedAt,
+		CompletedAt: state.CompletedAt,
+		ArchivedAt:  archivedAt,
+		Record:      record,
+	}, nil
+}
+
+func encodeGameRecord(state *Game) ([]byte, error) {
+	header := copyGameState(state)
+	header.Walls = nil
+	header.Moves = nil
+
+	record := GameRecord{
+		Game:     header,
+		Moves:    make([]string, 0, len(state.Moves)),
+		MoveTime: make([]int64, 0, len(state.Moves)),
+	}
+	for _, move := range state.Moves {
+		record.Moves = append(record.Moves, moveToNotation(move))
+		record.MoveTime = append(record.MoveTime, move.Timestamp.Sub(state.CreatedAt).Milliseconds())
+	}
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+
+	var buffer bytes.Buffer
+	writer := gzip.NewWriter(&buffer)
+	if _, err := writer.Write(data); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	return buffer.Bytes(), nil
+}
+
+func decodeGameRecord(data []byte) (*Game, error) {
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	decompressed, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	record := GameRecord{}
+	if err := json.Unmarshal(decompressed, &record); err != nil {
+		return nil, err
+	}
+
+	if record.Game == nil || record.Game.Player1 == nil || record.Game.Player2 == nil || len(record.Moves) != len(record.MoveTime) {
+		return nil, fmt.Errorf("malformed game record")
+	}
+
+	state := record.Game
+	state.Walls = []*Wall{}
+	state.Moves = make([]*Move, 0, len(record.Moves))
+
+	for i, notation := range record.Moves {
+		userId := state.Player1.UserId
+		if i%2 == 1 {
+			userId = state.Player2.UserId
+		}
+
+		move, err := moveFromNotation(userId, notation)
+		if err != nil {
+			return nil, err
+		}
+		move.Timestamp = state.CreatedAt.Add(time.Duration(record.MoveTime[i]) * time.Millisecond)
+
+		if move.Wall != nil {
+			state.Walls = append(state.Walls, move.Wall)
+		}
+		state.Moves = append(state.Moves, move)
+	}
+
+	return state, nil
+}
+
+func moveToNotation(move *Move) string {
+	if move.Type == MoveTypePlaceWall {
+		direction := "h"
+		if move.Wall.Direction == Vertical {
+			direction = "v"
+		}
+		return positionToNotation(move.Wall.Pos1) + positionToNotation(move.Wall.Pos2) + direction
+	}
+
+	return positionToNotation(move.Position)
+}
+
+func moveFromNotation(userId, notation string) (*Move, error) {
+	if match := pawnMoveNotation.FindStringSubmatch(notation); match != nil {
+		return &Move{
+			UserId:   userId,
+			Type:     MoveTypeMove,
+			Position: positionFromNotation(match[1], match[2]),
+		}, nil
+	}
+
+	if match := wallNotation.FindStringSubmatch(notation); match != nil {
+		direction := Horizontal
+		if match[5] == "v" {
+			direction = Vertical
+		}
+
+		return &Move{
+			UserId: userId,
+			Type:   MoveTypePlaceWall,
+			Wall: &Wall{
+				Direction: direction,
+				Pos1:      positionFromNotation(match[1], match[2]),
+				Pos2:      positionFromNotation(match[3], match[4]),
+			},
+		}, nil
+	}
+
+	return nil, fmt.Errorf("invalid move notation: %v", notation)
+}
+
+// columns are letters starting from "a", rows are numbers starting from 1
+func positionToNotation(position *Position) string {
+	return fmt.Sprintf("%c%d", 'a'+position.X, position.Y+1)
+}
+
+func positionFromNotation(column, row string) *Position {
+	y, _ := strconv.Atoi(row)
+	return &Position{X: int(column[0] - 'a'), Y: y - 1}
+}
@@ -0,0 +1,155 @@
+package game
+
+import (
+	"context"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type GameArchiveRepository interface {
+	SaveArchivedGame(archived *ArchivedGame) error
+	GetArchivedGameById(gameId string) (*ArchivedGame, error)
+	GetGameSummariesByUserId(filter *GameHistoryFilter, after *GameHistoryCursor, limit int) ([]*GameSummary, error)
+}
+
+type MongoGameArchiveRepository struct {
+	database   *mongo.Database
+	collection *mongo.Collection
+}
+
+func NewMongoGameArchiveRepository(database *mongo.Database, collectionName string) *MongoGameArchiveRepository {
+	collection := database.Collection(collectionName)
+	return &MongoGameArchiveRepository{
+		database:   database,
+		collection: collection,
+	}
+}
+
+func (r *MongoGameArchiveRepository) SaveArchivedGame(archived *ArchivedGame) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := r.collection.ReplaceOne(
+		ctx,
+		bson.M{"_id": archived.GameId},
+		archived,
+		options.Replace().SetUpsert(true),
+	)
+	if err != nil {
+		log.Printf("Error saving archived game: %v", err)
+		return err
+	}
+	return nil
+}
+
+func (r *MongoGameArchiveRepository) GetArchivedGameById(gameId string) (*ArchivedGame, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var archived ArchivedGame
+	err := r.collection.FindOne(ctx, bson.M{"_id": gameId}).Decode(&archived)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		log.Printf("Error loading archived game: %v", err)
+		return nil, err
+	}
+	return &archived, nil
+}
+
+func (r *MongoGameArchiveRepository) GetGameSummariesByUserId(filter *GameHistoryFilter, after *GameHistoryCursor, limit int) ([]*GameSummary, error) {
+	return findGameSummaries(r.collection, filter, after, limit, "$moves_count")
+}
+
+/*
+game repository that also looks into the archive,
+so archived games are still returned by id and included in the game history
+*/
+type ArchiveAwareGameRepository struct {
+	repository GameRepository
+	archive    GameArchiveRepository
+}
+
+func NewArchiveAwareGameRepository(repository GameRepository, archive GameArchiveRepository) *ArchiveAwareGameRepository {
+	return &ArchiveAwareGameRepository{
+		repository: repository,
+		archive:    archive,
+	}
+}
+
+func (r *ArchiveAwareGameRepository) SaveGame(state *Game) error {
+	return r.repository.SaveGame(state)
+}
+
+func (r *ArchiveAwareGameRepository) GetGameById(gameID string) (*Game, error) {
+	state, err := r.repository.GetGameById(gameID)
+	if err != nil || state != nil {
+		return state, err
+	}
+
+	archived, err := r.archive.GetArchivedGameById(gameID)
+	if err != nil || archived == nil {
+		return nil, err
+	}
+
+	state, err = decodeGameRecord(archived.Record)
+	if err != nil {
+		log.Printf("Error decoding archived game: gameId=%v, err=%v", gameID, err)
+		return nil, err
+	}
+	return state, nil
+}
+
+func (r *ArchiveAwareGameRepository) GetGamesByStatus(status GameStatus) ([]*Game, error) {
+	return r.repository.GetGamesByStatus(status)
+}
+
+func (r *ArchiveAwareGameRepository) GetGamesByUserIdAndStatus(userId string, status GameStatus) ([]*Game, error) {
+	return r.repository.GetGamesByUserIdAndStatus(userId, status)
+}
+
+func (r *ArchiveAwareGameRepository) GetGamesByStatusUpdatedBefore(status GameStatus, before time.Time, limit int) ([]*Game, error) {
+	return r.repository.GetGamesByStatusUpdatedBefore(status, before, limit)
+}
+
+func (r *ArchiveAwareGameRepository) DeleteGame(gameId string) error {
+	return r.repository.DeleteGame(gameId)
+}
+
+// both collections are ordered the same way, so merging their pages keeps the cursor valid
+func (r *ArchiveAwareGameRepository) GetGameSummariesByUserId(filter *GameHistoryFilter, after *GameHistoryCursor, limit int) ([]*GameSummary, error) {
+	summaries, err := r.repository.GetGameSummariesByUserId(filter, after, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	archived, err := r.archive.GetGameSummariesByUserId(filter, after, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	summaries = append(summaries, archived...)
+	slices.SortFunc(summaries, func(summary1, summary2 *GameSummary) int {
+		result := summary1.CreatedAt.Compare(summary2.CreatedAt)
+		if result == 0 {
+			result = strings.Compare(summary1.GameId, summary2.GameId)
+		}
+
+		if filter.Sort == SortOrderAsc {
+			return result
+		}
+		return -result
+	})
+
+	if len(summaries) > limit {
+		summaries = summaries[:limit]
+	}
+	return summaries, nil
+}
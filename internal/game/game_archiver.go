@@ -0,0 +1,113 @@
+package game
+
+import (
+	"log"
+	"time"
+)
+
+const ARCHIVE_BATCH_SIZE = 100
+
+type GameArchiver interface {
+	StartArchiving()
+}
+
+/*
+periodically moves completed games older than archiveAfter to the archive
+and deletes pending or aborted games that were not updated for abandonedTTL
+*/
+type GameArchiverImpl struct {
+	repository   GameRepository
+	archive      GameArchiveRepository
+	archiveAfter time.Duration
+	abandonedTTL time.Duration
+	interval     time.Duration
+}
+
+func NewGameArchiver(repository GameRepository, archive GameArchiveRepository, archiveAfter, abandonedTTL, interval time.Duration) *GameArchiverImpl {
+	return &GameArchiverImpl{
+		repository:   repository,
+		archive:      archive,
+		archiveAfter: archiveAfter,
+		abandonedTTL: abandonedTTL,
+		interval:     interval,
+	}
+}
+
+func (archiver *GameArchiverImpl) StartArchiving() {
+	log.Println("Starting game archiver goroutine...")
+	go func() {
+		ticker := time.NewTicker(archiver.interval)
+		for range ticker.C {
+			archiver.archiveGames()
+			archiver.purgeAbandonedGames()
+		}
+	}()
+}
+
+func (archiver *GameArchiverImpl) archiveGames() {
+	before := time.Now().Add(-archiver.archiveAfter)
+
+	for {
+		games, err := archiver.repository.GetGamesByStatusUpdatedBefore(GameStatusCompleted, before, ARCHIVE_BATCH_SIZE)
+		if err != nil {
+			log.Printf("Error while fetching games to archive: err=%v", err)
+			return
+		}
+
+		for _, game := range games {
+			if err := archiver.archiveGame(game); err != nil {
+				log.Printf("Error while archiving game: gameId=%v, err=%v", game.GameId, err)
+				return
+			}
+		}
+
+		if len(games) < ARCHIVE_BATCH_SIZE {
+			return
+		}
+	}
+}
+
+// the game is deleted only after it is stored in the archive, so it is never lost in between
+func (archiver *GameArchiverImpl) archiveGame(game *Game) error {
+	archived, err := newArchivedGame(game, time.Now())
+	if err != nil {
+		return err
+	}
+
+	if err := archiver.archive.SaveArchivedGame(archived); err != nil {
+		return err
+	}
+
+	if err := archiver.repository.DeleteGame(game.GameId); err != nil {
+		return err
+	}
+
+	log.Printf("Archived game with id=%s", game.GameId)
+	return nil
+}
+
+func (archiver *GameArchiverImpl) purgeAbandonedGames() {
+	before := time.Now().Add(-archiver.abandonedTTL)
+
+	for _, status := range []GameStatus{GameStatusPending, GameStatusAborted} {
+		for {
+			games, err := archiver.repository.GetGamesByStatusUpdatedBefore(status, before, ARCHIVE_BATCH_SIZE)
+			if err != nil {
+				log.Printf("Error while fetching abandoned games: status=%v, err=%v", status, err)
+				return
+			}
+
+			for _, game := range games {
+				if err := archiver.repository.DeleteGame(game.GameId); err != nil {
+					log.Printf("Error while purging abandoned game: gameId=%v, err=%v", game.GameId, err)
+					return
+				}
+				log.Printf("Purged abandoned game with id=%s, status=%v", game.GameId, status)
+			}
+
+			if len(games) < ARCHIVE_BATCH_SIZE {
+				break
+			}
+		}
+	}
+}
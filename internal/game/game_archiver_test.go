@@ -0,0 +1,199 @@
+package game
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockGameArchiveRepository struct {
+	mock.Mock
+}
+
+func (m *MockGameArchiveRepository) SaveArchivedGame(archived *ArchivedGame) error {
+	args := m.Called(archived)
+	return args.Error(0)
+}
+
+func (m *MockGameArchiveRepository) GetArchivedGameById(gameId string) (*ArchivedGame, error) {
+	args := m.Called(gameId)
+	return args.Get(0).(*ArchivedGame), args.Error(1)
+}
+
+func (m *MockGameArchiveRepository) GetGameSummariesByUserId(filter *GameHistoryFilter, after *GameHistoryCursor, limit int) ([]*GameSummary, error) {
+	args := m.Called(filter, after, limit)
+	return args.Get(0).([]*GameSummary), args.Error(1)
+}
+
+func newCompletedGame() *Game {
+	createdAt := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	player1, player2 := newPlayers("player1", "player2")
+	player1.Position = &Position{X: 4, Y: 1}
+	player2.Walls = 9
+
+	wall := &Wall{
+		Direction: Vertical,
+		Pos1:      &Position{X: 2, Y: 2},
+		Pos2:      &Position{X: 3, Y: 2},
+	}
+
+	return &Game{
+		GameId:     "test-game-id",
+		GameStatus: GameStatusCompleted,
+		Variant:    GameVariantStandard,
+		EndReason:  EndReasonResign,
+		Winner:     "player1",
+		Turn:       "player1",
+		Player1:    player1,
+		Player2:    player2,
+		Walls:      []*Wall{wall},
+		Moves: []*Move{
+			{UserId: "player1", Type: MoveTypeMove, Position: &Position{X: 4, Y: 1}, Timestamp: createdAt.Add(2 * time.Second)},
+			{UserId: "player2", Type: MoveTypePlaceWall, Wall: wall, Timestamp: createdAt.Add(5 * time.Second)},
+		},
+		CreatedAt:   createdAt,
+		UpdatedAt:   createdAt.Add(10 * time.Second),
+		CompletedAt: createdAt.Add(10 * time.Second),
+	}
+}
+
+func TestGameRecord_shouldRestoreArchivedGame(t *testing.T) {
+	state := newCompletedGame()
+
+	record, err := encodeGameRecord(state)
+	assert.NoError(t, err)
+
+	restored, err := decodeGameRecord(record)
+
+	assert.NoError(t, err)
+	assert.Equal(t, state.GameId, restored.GameId)
+	assert.Equal(t, state.GameStatus, restored.GameStatus)
+	assert.Equal(t, state.Winner, restored.Winner)
+	assert.Equal(t, state.Player1, restored.Player1)
+	assert.Equal(t, state.Player2, restored.Player2)
+	assert.Equal(t, state.Walls, restored.Walls)
+	assert.Equal(t, state.Moves, restored.Moves)
+	assert.True(t, state.CompletedAt.Equal(restored.CompletedAt))
+}
+
+func TestMoveNotation(t *testing.T) {
+	move := &Move{UserId: "player1", Type: MoveTypeMove, Position: &Position{X: 4, Y: 1}}
+	assert.Equal(t, "e2", moveToNotation(move))
+
+	wallMove := &Move{
+		UserId: "player2",
+		Type:   MoveTypePlaceWall,
+		Wall: &Wall{
+			Direction: Horizontal,
+			Pos1:      &Position{X: 2, Y: 2},
+			Pos2:      &Position{X: 2, Y: 3},
+		},
+	}
+	assert.Equal(t, "c3c4h", moveToNotation(wallMove))
+
+	parsed, err := moveFromNotation("player2", "c3c4h")
+	assert.NoError(t, err)
+	assert.Equal(t, wallMove, parsed)
+
+	_, err = moveFromNotation("player1", "z")
+	assert.Error(t, err)
+}
+
+func TestArchiveGames(t *testing.T) {
+	repo := new(MockGameRepository)
+	archive := new(MockGameArchiveRepository)
+	archiver := NewGameArchiver(repo, archive, time.Hour, time.Hour, time.Minute)
+
+	state := newCompletedGame()
+	repo.On("GetGamesByStatusUpdatedBefore", GameStatusCompleted, mock.Anything, ARCHIVE_BATCH_SIZE).Return([]*Game{state}, nil)
+	archive.On("SaveArchivedGame", mock.Anything).Return(nil)
+	repo.On("DeleteGame", "test-game-id").Return(nil)
+
+	archiver.archiveGames()
+
+	archive.AssertCalled(t, "SaveArchivedGame", mock.MatchedBy(func(archived *ArchivedGame) bool {
+		return archived.GameId == "test-game-id" &&
+			archived.Player1.UserId == "player1" &&
+			archived.MovesCount == 2 &&
+			len(archived.Record) > 0
+	}))
+	repo.AssertCalled(t, "DeleteGame", "test-game-id")
+}
+
+func TestArchiveGames_givenArchiveError_shouldKeepGame(t *testing.T) {
+	repo := new(MockGameRepository)
+	archive := new(MockGameArchiveRepository)
+	archiver := NewGameArchiver(repo, archive, time.Hour, time.Hour, time.Minute)
+
+	state := newCompletedGame()
+	repo.On("GetGamesByStatusUpdatedBefore", GameStatusCompleted, mock.Anything, ARCHIVE_BATCH_SIZE).Return([]*Game{state}, nil)
+	archive.On("SaveArchivedGame", mock.Anything).Return(assert.AnError)
+
+	archiver.archiveGames()
+
+	repo.AssertNotCalled(t, "DeleteGame", mock.Anything)
+}
+
+func TestPurgeAbandonedGames(t *testing.T) {
+	repo := new(MockGameRepository)
+	archive := new(MockGameArchiveRepository)
+	archiver := NewGameArchiver(repo, archive, time.Hour, time.Hour, time.Minute)
+
+	pending := &Game{GameId: "pending-game-id", GameStatus: GameStatusPending}
+	aborted := &Game{GameId: "aborted-game-id", GameStatus: GameStatusAborted}
+	repo.On("GetGamesByStatusUpdatedBefore", GameStatusPending, mock.Anything, ARCHIVE_BATCH_SIZE).Return([]*Game{pending}, nil)
+	repo.On("GetGamesByStatusUpdatedBefore", GameStatusAborted, mock.Anything, ARCHIVE_BATCH_SIZE).Return([]*Game{aborted}, nil)
+	repo.On("DeleteGame", mock.Anything).Return(nil)
+
+	archiver.purgeAbandonedGames()
+
+	repo.AssertCalled(t, "DeleteGame", "pending-game-id")
+	repo.AssertCalled(t, "DeleteGame", "aborted-game-id")
+	archive.AssertNotCalled(t, "SaveArchivedGame", mock.Anything)
+}
+
+func TestArchiveAwareGameRepository_GetGameById_givenArchivedGame_shouldRestoreIt(t *testing.T) {
+	repo := new(MockGameRepository)
+	archive := new(MockGameArchiveRepository)
+	archiveAwareRepo := NewArchiveAwareGameRepository(repo, archive)
+
+	state := newCompletedGame()
+	archived, err := newArchivedGame(state, time.Now())
+	assert.NoError(t, err)
+
+	repo.On("GetGameById", "test-game-id").Return((*Game)(nil), nil)
+	archive.On("GetArchivedGameById", "test-game-id").Return(archived, nil)
+
+	restored, err := archiveAwareRepo.GetGameById("test-game-id")
+
+	assert.NoError(t, err)
+	assert.Equal(t, state.GameId, restored.GameId)
+	assert.Equal(t, state.Moves, restored.Moves)
+}
+
+func TestArchiveAwareGameRepository_GetGameSummariesByUserId_shouldMergeArchive(t *testing.T) {
+	repo := new(MockGameRepository)
+	archive := new(MockGameArchiveRepository)
+	archiveAwareRepo := NewArchiveAwareGameRepository(repo, archive)
+
+	now := time.Now()
+	filter := &GameHistoryFilter{UserId: "player1"}
+	repo.On("GetGameSummariesByUserId", filter, (*GameHistoryCursor)(nil), 3).Return([]*GameSummary{
+		{GameId: "game4", CreatedAt: now},
+		{GameId: "game2", CreatedAt: now.Add(-2 * time.Hour)},
+	}, nil)
+	archive.On("GetGameSummariesByUserId", filter, (*GameHistoryCursor)(nil), 3).Return([]*GameSummary{
+		{GameId: "game3", CreatedAt: now.Add(-time.Hour)},
+		{GameId: "game1", CreatedAt: now.Add(-3 * time.Hour)},
+	}, nil)
+
+	summaries, err := archiveAwareRepo.GetGameSummariesByUserId(filter, nil, 3)
+
+	assert.NoError(t, err)
+	assert.Len(t, summaries, 3)
+	assert.Equal(t, "game4", summaries[0].GameId)
+	assert.Equal(t, "game3", summaries[1].GameId)
+	assert.Equal(t, "game2", summaries[2].GameId)
+}
@@ -0,0 +1,168 @@
+package game
+
+import (
+	"math/rand"
+	"strings"
+	"sync"
+)
+
+type GameBot interface {
+	NextMove(state *Game, botId string) *Move
+}
+
+const (
+	BOT_USER_ID_PREFIX = "bot-"
+	BOT_DISPLAY_NAME   = "Bot"
+
+	// the bot plays its best move every time at the max rating and rarely at the min one
+	BOT_MIN_RATING   = 800
+	BOT_MAX_RATING   = 2200
+	BOT_MIN_STRENGTH = 0.2
+
+	// the wall has to slow down the opponent by at least this many steps more than the bot
+	BOT_MIN_WALL_GAIN = 1
+)
+
+/*
+moves along the shortest path and places walls when the opponent is closer to their goal.
+the strength of the bot depends on its rating, weaker bots make random moves and skip walls more often
+*/
+type ShortestPathBot struct {
+	mutex  sync.Mutex
+	engine *GameEngineImpl
+	random *rand.Rand
+}
+
+func NewShortestPathBot(engine *GameEngineImpl, random *rand.Rand) *ShortestPathBot {
+	return &ShortestPathBot{
+		engine: engine,
+		random: random,
+	}
+}
+
+func IsBotId(userId string) bool {
+	return strings.HasPrefix(userId, BOT_USER_ID_PREFIX)
+}
+
+// returns nil when the bot has no valid move
+func (bot *ShortestPathBot) NextMove(state *Game, botId string) *Move {
+	player := bot.engine.getPlayer(state, botId)
+	opponent := bot.engine.getOpponent(state, botId)
+	strength := botStrength(player.BotRating)
+
+	if player.Walls > 0 && bot.chance(strength) {
+		if wall := bot.bestWall(state, player, opponent); wall != nil {
+			return &Move{UserId: botId, Type: MoveTypePlaceWall, Wall: wall}
+		}
+	}
+
+	positions := bot.validPositions(state, player)
+	if len(positions) == 0 {
+		return nil
+	}
+
+	position := bot.bestPosition(state, player, positions)
+	if position == nil || !bot.chance(strength) {
+		position = positions[bot.randomIndex(len(positions))]
+	}
+
+	return &Move{UserId: botId, Type: MoveTypeMove, Position: position}
+}
+
+func botStrength(rating float64) float64 {
+	strength := (rating - BOT_MIN_RATING) / (BOT_MAX_RATING - BOT_MIN_RATING)
+	return min(max(strength, BOT_MIN_STRENGTH), 1)
+}
+
+// all positions the player can move to, including jumps over the opponent
+func (bot *ShortestPathBot) validPositions(state *Game, player *Player) []*Position {
+	positions := []*Position{}
+	for dx := -2; dx <= 2; dx++ {
+		for dy := -2; dy <= 2; dy++ {
+			if dx == 0 && dy == 0 || abs(dx)+abs(dy) > 2 {
+				continue
+			}
+
+			position := &Position{X: player.Position.X + dx, Y: player.Position.Y + dy}
+			if bot.engine.IsMoveValid(state, player.UserId, position) {
+				positions = append(positions, position)
+			}
+		}
+	}
+	return positions
+}
+
+func (bot *ShortestPathBot) bestPosition(state *Game, player *Player, positions []*Position) *Position {
+	current := player.Position
+	defer func() {
+		player.Position = current
+	}()
+
+	var best *Position
+	bestLength := -1
+	for _, position := range positions {
+		player.Position = position
+		length := bot.engine.shortestPathLength(state, player)
+		if length != -1 && (best == nil || length < bestLength) {
+			best = position
+			bestLength = length
+		}
+	}
+	return best
+}
+
+// returns nil when the opponent is not ahead or no wall slows them down enough
+func (bot *ShortestPathBot) bestWall(state *Game, player, opponent *Player) *Wall {
+	playerLength := bot.engine.shortestPathLength(state, player)
+	opponentLength := bot.engine.shortestPathLength(state, opponent)
+	if opponentLength > playerLength {
+		return nil
+	}
+
+	var best *Wall
+	bestGain := BOT_MIN_WALL_GAIN - 1
+	for _, wall := range bot.candidateWalls() {
+		if !bot.engine.IsWallPlacementValid(state, wall) {
+			continue
+		}
+
+		state.Walls = append(state.Walls, wall)
+		gain := (bot.engine.shortestPathLength(state, opponent) - opponentLength) -
+			(bot.engine.shortestPathLength(state, player) - playerLength)
+		state.Walls = state.Walls[:len(state.Walls)-1]
+
+		if gain > bestGain {
+			best = wall
+			bestGain = gain
+		}
+	}
+	return best
+}
+
+func (bot *ShortestPathBot) candidateWalls() []*Wall {
+	walls := []*Wall{}
+	for x := 0; x < BOARD_SIZE-1; x++ {
+		for y := 0; y < BOARD_SIZE-1; y++ {
+			walls = append(walls,
+				&Wall{Direction: Horizontal, Pos1: &Position{X: x, Y: y}, Pos2: &Position{X: x, Y: y + 1}},
+				&Wall{Direction: Vertical, Pos1: &Position{X: x, Y: y}, Pos2: &Position{X: x + 1, Y: y}},
+			)
+		}
+	}
+	return walls
+}
+
+// rand.Rand is not safe for concurrent use and the bot plays in all bot games at once
+func (bot *ShortestPathBot) chance(probability float64) bool {
+	bot.mutex.Lock()
+	defer bot.mutex.Unlock()
+
+	return bot.random.Float64() < probability
+}
+
+func (bot *ShortestPathBot) randomIndex(n int) int {
+	bot.mutex.Lock()
+	defer bot.mutex.Unlock()
+
+	return bot.random.Intn(n)
+}
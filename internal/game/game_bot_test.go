@@ -0,0 +1,94 @@
+package game
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShortestPathBot_NextMove_givenStrongBot_shouldMoveTowardsGoal(t *testing.T) {
+	engine := NewGameEngine()
+	bot := NewShortestPathBot(engine, rand.New(rand.NewSource(1)))
+
+	player, botPlayer := newPlayers("player1", BOT_USER_ID_PREFIX+"1")
+	botPlayer.BotRating = BOT_MAX_RATING
+	state := &Game{
+		Player1: player,
+		Player2: botPlayer,
+		Turn:    botPlayer.UserId,
+		Walls: []*Wall{
+			{Direction: Horizontal, Pos1: &Position{X: 3, Y: 7}, Pos2: &Position{X: 3, Y: 8}},
+		},
+	}
+
+	move := bot.NextMove(state, botPlayer.UserId)
+
+	assert.Equal(t, MoveTypeMove, move.Type)
+	assert.Equal(t, botPlayer.UserId, move.UserId)
+	assert.Equal(t, &Position{X: 5, Y: 8}, move.Position)
+	assert.Equal(t, &Position{X: 4, Y: 8}, botPlayer.Position)
+}
+
+func TestShortestPathBot_NextMove_givenOpponentAhead_shouldPlaceWall(t *testing.T) {
+	engine := NewGameEngine()
+	bot := NewShortestPathBot(engine, rand.New(rand.NewSource(1)))
+
+	player, botPlayer := newPlayers("player1", BOT_USER_ID_PREFIX+"1")
+	player.Position = &Position{X: 2, Y: 6}
+	botPlayer.BotRating = BOT_MAX_RATING
+	state := &Game{
+		Player1: player,
+		Player2: botPlayer,
+		Turn:    botPlayer.UserId,
+		Walls:   []*Wall{},
+	}
+
+	move := bot.NextMove(state, botPlayer.UserId)
+
+	assert.Equal(t, MoveTypePlaceWall, move.Type)
+	assert.True(t, engine.IsWallPlacementValid(state, move.Wall))
+	assert.Len(t, state.Walls, 0)
+
+	state.Walls = append(state.Walls, move.Wall)
+	assert.Greater(t, engine.shortestPathLength(state, player), 2)
+}
+
+func TestShortestPathBot_NextMove_givenWeakBot_shouldAlwaysMakeValidMoves(t *testing.T) {
+	engine := NewGameEngine()
+	bot := NewShortestPathBot(engine, rand.New(rand.NewSource(1)))
+
+	player, botPlayer := newPlayers("player1", BOT_USER_ID_PREFIX+"1")
+	botPlayer.BotRating = BOT_MIN_RATING
+	state := &Game{
+		Player1: player,
+		Player2: botPlayer,
+		Turn:    botPlayer.UserId,
+		Walls:   []*Wall{},
+	}
+
+	for i := 0; i < 20; i++ {
+		move := bot.NextMove(state, botPlayer.UserId)
+
+		switch move.Type {
+		case MoveTypeMove:
+			assert.True(t, engine.IsMoveValid(state, botPlayer.UserId, move.Position))
+			botPlayer.Position = move.Position
+		case MoveTypePlaceWall:
+			assert.True(t, engine.IsWallPlacementValid(state, move.Wall))
+			state.Walls = append(state.Walls, move.Wall)
+			botPlayer.Walls--
+		}
+	}
+}
+
+func TestBotStrength(t *testing.T) {
+	assert.Equal(t, BOT_MIN_STRENGTH, botStrength(0))
+	assert.Equal(t, 0.5, botStrength(1500))
+	assert.Equal(t, 1.0, botStrength(3000))
+}
+
+func TestIsBotId(t *testing.T) {
+	assert.True(t, IsBotId(BOT_USER_ID_PREFIX+"1"))
+	assert.False(t, IsBotId("player1"))
+}
@@ -314,3 +314,32 @@ func (engine *GameEngineImpl) hasPathToGoal(state *Game, player *Player) bool {
 
 	return false
 }
+
+/*
+returns the number of steps the player needs to reach their goal, or -1 if the goal can't be reached.
+the opponent is ignored, the same way as in hasPathToGoal
+*/
+func (engine *GameEngineImpl) shortestPathLength(state *Game, player *Player) int {
+	distances := map[Position]int{*player.Position: 0}
+	queue := []*Position{player.Position}
+
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+
+		if current.Y == player.Goal {
+			return distances[*current]
+		}
+
+		for _, neighbor := range engine.getAdjacentPositions(current) {
+			if _, visited := distances[*neighbor]; visited || !engine.isWithinBounds(neighbor) || engine.crossesWall(state, current, neighbor) {
+				continue
+			}
+
+			distances[*neighbor] = distances[*current] + 1
+			queue = append(queue, neighbor)
+		}
+	}
+
+	return -1
+}
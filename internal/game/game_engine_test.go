@@ -332,3 +332,38 @@ func TestIsWallPlacementValid(t *testing.T) {
 		}
 	}
 }
+
+func TestShortestPathLength(t *testing.T) {
+	engine := NewGameEngine()
+
+	state := &Game{
+		Player1: &Player{
+			UserId:   "player1",
+			Position: &Position{X: 4, Y: 0},
+			Goal:     8,
+		},
+		Player2: &Player{
+			UserId:   "player2",
+			Position: &Position{X: 4, Y: 7},
+			Goal:     0,
+		},
+		Walls: []*Wall{
+			{Direction: Horizontal, Pos1: &Position{X: 3, Y: 0}, Pos2: &Position{X: 3, Y: 1}},
+		},
+	}
+
+	tests := []struct {
+		player   *Player
+		expected int
+	}{
+		{state.Player1, 9},
+		{state.Player2, 8},
+	}
+
+	for _, test := range tests {
+		result := engine.shortestPathLength(state, test.player)
+		if result != test.expected {
+			t.Errorf("shortestPathLength(state, %v) = %v; want %v", test.player.UserId, result, test.expected)
+		}
+	}
+}
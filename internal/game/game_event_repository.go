@@ -0,0 +1,359 @@
+package game
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+/*
+stores every move as an immutable event in the events collection,
+the game document is a projection of the log and doesn't contain the moves
+*/
+type EventSourcedGameRepository struct {
+	database   *mongo.Database
+	collection *mongo.Collection
+	events     *mongo.Collection
+}
+
+type gameProjection struct {
+	Game       `bson:",inline"`
+	Version    int `bson:"version"` // sequence of the last event applied to the projection
+	MovesCount int `bson:"moves_count"`
+}
+
+func NewEventSourcedGameRepository(database *mongo.Database, collectionName, eventsCollectionName string) *EventSourcedGameRepository {
+	collection := database.Collection(collectionName)
+	events := database.Collection(eventsCollectionName)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := events.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "game_id", Value: 1}, {Key: "sequence", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		log.Printf("Error creating game events index: %v", err)
+	}
+
+	return &EventSourcedGameRepository{
+		database:   database,
+		collection: collection,
+		events:     events,
+	}
+}
+
+func (r *EventSourcedGameRepository) SaveGame(state *Game) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	stored := gameProjection{}
+	err := r.collection.FindOne(
+		ctx,
+		bson.M{"_id": state.GameId},
+		options.FindOne().SetProjection(bson.M{"version": 1, "moves_count": 1, "status": 1, "player_1.rating_change": 1, "player_2.rating_change": 1}),
+	).Decode(&stored)
+	if err != nil && err != mongo.ErrNoDocuments {
+		log.Printf("Error loading game projection: %v", err)
+		return err
+	}
+
+	// the projection is behind the log when its last save failed after the events were appended
+	lastSequence, err := r.lastSequence(ctx, state.GameId)
+	if err != nil {
+		log.Printf("Error loading last game event: %v", err)
+		return err
+	}
+	if lastSequence > stored.Version {
+		log.Printf("Game projection is behind the log: gameId=%v, version=%d, last sequence=%d", state.GameId, stored.Version, lastSequence)
+		logged, version, err := r.replayLog(ctx, state.GameId)
+		if err != nil {
+			return err
+		}
+		stored.Version = version
+		stored.MovesCount = len(logged.Moves)
+		stored.GameStatus = logged.GameStatus
+		stored.Player1, stored.Player2 = logged.Player1, logged.Player2
+	}
+
+	events := newGameEvents(state, stored.Version, stored.MovesCount, stored.GameStatus, len(ratingChanges(&stored.Game)) > 0)
+	if len(events) > 0 {
+		documents := make([]interface{}, 0, len(events))
+		for _, event := range events {
+			documents = append(documents, event)
+		}
+
+		// sequence numbers are unique, so a concurrent save of the same game fails here
+		_, err = r.events.InsertMany(ctx, documents)
+		if err != nil {
+			log.Printf("Error appending game events: %v", err)
+			return err
+		}
+	}
+
+	projection := gameProjection{
+		Game:       *state,
+		Version:    stored.Version + len(events),
+		MovesCount: len(state.Moves),
+	}
+	projection.Moves = nil
+
+	return r.saveProjection(ctx, &projection)
+}
+
+/*
+replaces the projection unless a newer one is stored, so saving the same version again is harmless.
+the upsert fails on the id when a newer projection exists, which means there is nothing to save
+*/
+func (r *EventSourcedGameRepository) saveProjection(ctx context.Context, projection *gameProjection) error {
+	_, err := r.collection.ReplaceOne(
+		ctx,
+		bson.M{"_id": projection.GameId, "version": bson.M{"$lte": projection.Version}},
+		projection,
+		options.Replace().SetUpsert(true),
+	)
+	if mongo.IsDuplicateKeyError(err) {
+		log.Printf("Newer game projection is already stored: gameId=%v, version=%d", projection.GameId, projection.Version)
+		return nil
+	}
+	if err != nil {
+		log.Printf("Error saving game projection: %v", err)
+		return err
+	}
+	return nil
+}
+
+// returns 0 when the game has no events
+func (r *EventSourcedGameRepository) lastSequence(ctx context.Context, gameId string) (int, error) {
+	last := GameEvent{}
+	err := r.events.FindOne(
+		ctx,
+		bson.M{"game_id": gameId},
+		options.FindOne().SetSort(bson.D{{Key: "sequence", Value: -1}}).SetProjection(bson.M{"sequence": 1}),
+	).Decode(&last)
+	if err == mongo.ErrNoDocuments {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return last.Sequence, nil
+}
+
+// returns the game and the number of its events, the game is nil when it has no events
+func (r *EventSourcedGameRepository) replayLog(ctx context.Context, gameId string) (*Game, int, error) {
+	cursor, err := r.events.Find(
+		ctx,
+		bson.M{"game_id": gameId},
+		options.Find().SetSort(bson.D{{Key: "sequence", Value: 1}}),
+	)
+	if err != nil {
+		log.Printf("Error loading game events: %v", err)
+		return nil, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	events := []*GameEvent{}
+	if err := cursor.All(ctx, &events); err != nil {
+		log.Printf("Error decoding game events: %v", err)
+		return nil, 0, err
+	}
+
+	if len(events) == 0 {
+		return nil, 0, nil
+	}
+
+	state, err := replayGameEvents(events)
+	if err != nil {
+		log.Printf("Error replaying game events: %v", err)
+		return nil, 0, err
+	}
+	return state, len(events), nil
+}
+
+func (r *EventSourcedGameRepository) GetGameById(gameID string) (*Game, error) {
+	games, err := r.findGames(bson.M{"_id": gameID})
+	if err != nil {
+		log.Printf("Error loading game state: %v", err)
+		return nil, err
+	}
+
+	if len(games) == 0 {
+		return nil, nil
+	}
+	return games[0], nil
+}
+
+func (r *EventSourcedGameRepository) GetGamesByStatus(status GameStatus) ([]*Game, error) {
+	games, err := r.findGames(bson.M{"status": status})
+	if err != nil {
+		log.Printf("Error loading games by status: %v", err)
+		return nil, err
+	}
+
+	return games, nil
+}
+
+func (r *EventSourcedGameRepository) GetGamesByUserIdAndStatus(userId string, status GameStatus) ([]*Game, error) {
+	filter := bson.M{
+		"$and": []bson.M{
+			{"$or": []bson.M{
+				{"player_1.user_id": userId},
+				{"player_2.user_id": userId},
+			}},
+			{"status": status},
+		},
+	}
+
+	games, err := r.findGames(filter)
+	if err != nil {
+		log.Printf("Error loading games for user: %v, status: %v: %v", userId, status, err)
+		return nil, err
+	}
+
+	return games, nil
+}
+
+func (r *EventSourcedGameRepository) GetGamesByStatusUpdatedBefore(status GameStatus, before time.Time, limit int) ([]*Game, error) {
+	filter := bson.M{"status": status, "updated_at": bson.M{"$lt": before}}
+
+	games, err := r.findGames(filter, options.Find().SetLimit(int64(limit)))
+	if err != nil {
+		log.Printf("Error loading games by status: %v, updated before: %v: %v", status, before, err)
+		return nil, err
+	}
+
+	return games, nil
+}
+
+// deletes the projection together with the log of the game
+func (r *EventSourcedGameRepository) DeleteGame(gameId string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := r.collection.DeleteOne(ctx, bson.M{"_id": gameId})
+	if err != nil {
+		log.Printf("Error deleting game projection: %v", err)
+		return err
+	}
+
+	_, err = r.events.DeleteMany(ctx, bson.M{"game_id": gameId})
+	if err != nil {
+		log.Printf("Error deleting game events: %v", err)
+		return err
+	}
+	return nil
+}
+
+func (r *EventSourcedGameRepository) GetGameSummariesByUserId(filter *GameHistoryFilter, after *GameHistoryCursor, limit int) ([]*GameSummary, error) {
+	return findGameSummaries(r.collection, filter, after, limit, "$moves_count")
+}
+
+// replays the whole log of the game and replaces its projection
+func (r *EventSourcedGameRepository) RebuildProjection(gameId string) (*Game, error) {
+	log.Printf("Rebuilding game projection: gameId=%v", gameId)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	state, version, err := r.replayLog(ctx, gameId)
+	if err != nil || state == nil {
+		return nil, err
+	}
+
+	projection := gameProjection{
+		Game:       *state,
+		Version:    version,
+		MovesCount: len(state.Moves),
+	}
+	projection.Moves = nil
+
+	_, err = r.collection.ReplaceOne(
+		ctx,
+		bson.M{"_id": gameId},
+		projection,
+		options.Replace().SetUpsert(true),
+	)
+	if err != nil {
+		log.Printf("Error saving game projection: %v", err)
+		return nil, err
+	}
+
+	log.Printf("Rebuilt game projection: gameId=%v, events=%d", gameId, version)
+	return state, nil
+}
+
+// loads projections matching the filter together with their moves
+func (r *EventSourcedGameRepository) findGames(filter bson.M, opts ...*options.FindOptions) ([]*Game, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cursor, err := r.collection.Find(ctx, filter, opts...)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	games := []*Game{}
+	gamesById := map[string]*Game{}
+	for cursor.Next(ctx) {
+		var projection gameProjection
+		err := cursor.Decode(&projection)
+		if err != nil {
+			log.Printf("Error decoding game: %v", err)
+			continue
+		}
+
+		game := &projection.Game
+		game.Moves = []*Move{}
+		games = append(games, game)
+		gamesById[game.GameId] = game
+	}
+
+	if err := cursor.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(games) == 0 {
+		return games, nil
+	}
+
+	gameIds := make([]string, 0, len(games))
+	for _, game := range games {
+		gameIds = append(gameIds, game.GameId)
+	}
+
+	eventsCursor, err := r.events.Find(
+		ctx,
+		bson.M{"game_id": bson.M{"$in": gameIds}, "type": GameEventMove},
+		options.Find().SetSort(bson.D{{Key: "game_id", Value: 1}, {Key: "sequence", Value: 1}}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer eventsCursor.Close(ctx)
+
+	for eventsCursor.Next(ctx) {
+		var event GameEvent
+		err := eventsCursor.Decode(&event)
+		if err != nil {
+			log.Printf("Error decoding game event: %v", err)
+			continue
+		}
+
+		if game, ok := gamesById[event.GameId]; ok {
+			game.Moves = append(game.Moves, event.Move)
+		}
+	}
+
+	if err := eventsCursor.Err(); err != nil {
+		return nil, err
+	}
+
+	return games, nil
+}
@@ -0,0 +1,216 @@
+package game
+
+import (
+	"fmt"
+	"quoridor/internal/ratings"
+	"time"
+)
+
+/*
+returns the events that have to be appended to the game log to get from the stored projection to the given state.
+version, movesCount, status and rated describe the stored projection, version 0 means the game was never stored
+*/
+func newGameEvents(state *Game, version, movesCount int, status GameStatus, rated bool) []*GameEvent {
+	events := []*GameEvent{}
+	sequence := version
+
+	if version == 0 {
+		initial := initialGameState(state)
+		status = initial.GameStatus
+		movesCount = 0
+
+		sequence++
+		events = append(events, &GameEvent{
+			EventId:   gameEventId(state.GameId, sequence),
+			GameId:    state.GameId,
+			Sequence:  sequence,
+			Type:      GameEventCreated,
+			Game:      initial,
+			Timestamp: state.CreatedAt,
+		})
+	}
+
+	for i := movesCount; i < len(state.Moves); i++ {
+		move := state.Moves[i]
+
+		// the turn is not passed after the winning move, so the last move takes the turn from the state
+		turn := state.Turn
+		if i < len(state.Moves)-1 {
+			turn = nextTurn(state, move.UserId)
+		}
+
+		sequence++
+		events = append(events, &GameEvent{
+			EventId:   gameEventId(state.GameId, sequence),
+			GameId:    state.GameId,
+			Sequence:  sequence,
+			Type:      GameEventMove,
+			Move:      move,
+			Turn:      turn,
+			Timestamp: move.Timestamp,
+		})
+	}
+
+	if state.GameStatus != status {
+		timestamp := state.CompletedAt
+		if timestamp.IsZero() {
+			timestamp = state.UpdatedAt
+		}
+
+		sequence++
+		events = append(events, &GameEvent{
+			EventId:   gameEventId(state.GameId, sequence),
+			GameId:    state.GameId,
+			Sequence:  sequence,
+			Type:      GameEventStatusChanged,
+			Status:    state.GameStatus,
+			EndReason: state.EndReason,
+			Winner:    state.Winner,
+			Timestamp: timestamp,
+		})
+	}
+
+	// games are rated after they are saved as completed
+	if changes := ratingChanges(state); !rated && len(changes) > 0 {
+		sequence++
+		events = append(events, &GameEvent{
+			EventId:   gameEventId(state.GameId, sequence),
+			GameId:    state.GameId,
+			Sequence:  sequence,
+			Type:      GameEventRated,
+			Timestamp: state.UpdatedAt,
+
+			RatingChanges: changes,
+		})
+	}
+
+	return events
+}
+
+// rebuilds the game from its log, events have to be sorted by sequence
+func replayGameEvents(events []*GameEvent) (*Game, error) {
+	if len(events) == 0 || events[0].Type != GameEventCreated || events[0].Game == nil {
+		return nil, fmt.Errorf("game log has to start with the %v event", GameEventCreated)
+	}
+
+	state := copyGameState(events[0].Game)
+	state.Moves = []*Move{}
+
+	for i, event := range events {
+		if event.Sequence != i+1 {
+			return nil, fmt.Errorf("game log of game %v has a gap at sequence %d", state.GameId, i+1)
+		}
+
+		switch event.Type {
+		case GameEventCreated:
+			if i != 0 {
+				return nil, fmt.Errorf("game %v is created more than once", state.GameId)
+			}
+		case GameEventMove:
+			applyMove(state, event.Move)
+			state.Turn = event.Turn
+			state.UpdatedAt = event.Timestamp
+		case GameEventStatusChanged:
+			state.GameStatus = event.Status
+			state.EndReason = event.EndReason
+			state.Winner = event.Winner
+			if event.Status == GameStatusCompleted {
+				state.CompletedAt = event.Timestamp
+			}
+		case GameEventRated:
+			applyRatingChanges(state, event.RatingChanges)
+		default:
+			return nil, fmt.Errorf("unknown event type %v in game %v", event.Type, state.GameId)
+		}
+	}
+
+	return state, nil
+}
+
+// applies already validated move to the state, the turn is left unchanged
+func applyMove(state *Game, move *Move) {
+	player := state.Player2
+	if state.Player1.UserId == move.UserId {
+		player = state.Player1
+	}
+
+	switch move.Type {
+	case MoveTypeMove:
+		player.Position = move.Position
+	case MoveTypePlaceWall:
+		player.Walls--
+		state.Walls = append(state.Walls, move.Wall)
+	}
+
+	state.Moves = append(state.Moves, move)
+}
+
+func ratingChanges(state *Game) []*ratings.RatingChange {
+	var changes []*ratings.RatingChange
+	for _, player := range []*Player{state.Player1, state.Player2} {
+		if player != nil && player.RatingChange != nil {
+			changes = append(changes, player.RatingChange)
+		}
+	}
+	return changes
+}
+
+func applyRatingChanges(state *Game, changes []*ratings.RatingChange) {
+	for _, change := range changes {
+		switch change.UserId {
+		case state.Player1.UserId:
+			state.Player1.RatingChange = change
+		case state.Player2.UserId:
+			state.Player2.RatingChange = change
+		}
+	}
+}
+
+// state of the game before the first move
+func initialGameState(state *Game) *Game {
+	player1, player2 := newPlayers(state.Player1.UserId, state.Player2.UserId)
+
+	initial := copyGameState(state)
+	initial.Player1.Position = player1.Position
+	initial.Player1.Walls = player1.Walls
+	initial.Player2.Position = player2.Position
+	initial.Player2.Walls = player2.Walls
+	initial.Player1.RatingChange = nil
+	initial.Player2.RatingChange = nil
+	initial.GameStatus = GameStatusInProgress
+	initial.EndReason = ""
+	initial.Winner = ""
+	initial.Turn = state.Player1.UserId
+	initial.Walls = []*Wall{}
+	initial.Moves = nil
+	initial.UpdatedAt = state.CreatedAt
+	initial.CompletedAt = time.Time{}
+
+	return initial
+}
+
+// copies the game and its players, walls and moves themselves are shared since they are never modified
+func copyGameState(state *Game) *Game {
+	copied := *state
+
+	player1 := *state.Player1
+	player2 := *state.Player2
+	copied.Player1 = &player1
+	copied.Player2 = &player2
+
+	copied.Walls = append([]*Wall{}, state.Walls...)
+	copied.Moves = append([]*Move{}, state.Moves...)
+
+	return &copied
+}
+
+func nextTurn(state *Game, userId string) string {
+	if state.Player1.UserId == userId {
+		return state.Player2.UserId
+	}
+	return state.Player1.UserId
+}
+
+func gameEventId(gameId string, sequence int) string {
+	return fmt.Sprintf("%s:%d", gameId, sequence)
+}
@@ -0,0 +1,311 @@
+package game
+
+import (
+	"testing"
+	"time"
+
+	"quoridor/internal/ratings"
+	"quoridor/internal/users"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestNewGameEvents_givenNewGame_shouldStartLogWithCreatedEvent(t *testing.T) {
+	now := time.Now()
+	player1, player2 := newPlayers("player1", "player2")
+	state := &Game{
+		GameId:     "test-game-id",
+		GameStatus: GameStatusInProgress,
+		Player1:    player1,
+		Player2:    player2,
+		Turn:       "player1",
+		Walls:      []*Wall{},
+		Moves:      []*Move{},
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+
+	events := newGameEvents(state, 0, 0, "", false)
+
+	assert.Len(t, events, 1)
+	assert.Equal(t, GameEventCreated, events[0].Type)
+	assert.Equal(t, "test-game-id:1", events[0].EventId)
+	assert.Equal(t, 1, events[0].Sequence)
+	assert.Equal(t, GameStatusInProgress, events[0].Game.GameStatus)
+	assert.Nil(t, events[0].Game.Moves)
+}
+
+func TestNewGameEvents_givenStoredProjection_shouldAppendOnlyNewMoves(t *testing.T) {
+	now := time.Now()
+	player1, player2 := newPlayers("player1", "player2")
+	player1.Position = &Position{X: 4, Y: 1}
+	player2.Position = &Position{X: 4, Y: 7}
+	state := &Game{
+		GameId:     "test-game-id",
+		GameStatus: GameStatusInProgress,
+		Player1:    player1,
+		Player2:    player2,
+		Turn:       "player1",
+		Walls:      []*Wall{},
+		Moves: []*Move{
+			{UserId: "player1", Type: MoveTypeMove, Position: &Position{X: 4, Y: 1}, Timestamp: now},
+			{UserId: "player2", Type: MoveTypeMove, Position: &Position{X: 4, Y: 7}, Timestamp: now},
+		},
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	events := newGameEvents(state, 2, 1, GameStatusInProgress, false)
+
+	assert.Len(t, events, 1)
+	assert.Equal(t, GameEventMove, events[0].Type)
+	assert.Equal(t, 3, events[0].Sequence)
+	assert.Equal(t, state.Moves[1], events[0].Move)
+	assert.Equal(t, "player1", events[0].Turn)
+}
+
+func TestNewGameEvents_givenProjectionBehindLog_shouldContinueFromReplayedLog(t *testing.T) {
+	now := time.Now()
+	player1, player2 := newPlayers("player1", "player2")
+	state := &Game{
+		GameId:     "test-game-id",
+		GameStatus: GameStatusInProgress,
+		Player1:    player1,
+		Player2:    player2,
+		Turn:       "player1",
+		Walls:      []*Wall{},
+		Moves:      []*Move{},
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	logged := newGameEvents(state, 0, 0, "", false)
+
+	// the events of the first move were appended but the projection was not replaced
+	applyMove(state, &Move{UserId: "player1", Type: MoveTypeMove, Position: &Position{X: 4, Y: 1}, Timestamp: now})
+	state.Moves = append(state.Moves, &Move{UserId: "player1", Type: MoveTypeMove, Position: &Position{X: 4, Y: 1}, Timestamp: now})
+	state.Turn = "player2"
+	logged = append(logged, newGameEvents(state, 1, 0, GameStatusInProgress, false)...)
+
+	state.Moves = append(state.Moves, &Move{UserId: "player2", Type: MoveTypeMove, Position: &Position{X: 4, Y: 7}, Timestamp: now})
+	state.Turn = "player1"
+
+	replayed, err := replayGameEvents(logged)
+	assert.NoError(t, err)
+
+	events := newGameEvents(state, len(logged), len(replayed.Moves), replayed.GameStatus, false)
+
+	assert.Len(t, events, 1)
+	assert.Equal(t, GameEventMove, events[0].Type)
+	assert.Equal(t, len(logged)+1, events[0].Sequence)
+	assert.Equal(t, "player2", events[0].Move.UserId)
+}
+
+func TestNewGameEvents_givenNoChanges_shouldNotAppendEvents(t *testing.T) {
+	player1, player2 := newPlayers("player1", "player2")
+	state := &Game{
+		GameId:     "test-game-id",
+		GameStatus: GameStatusInProgress,
+		Player1:    player1,
+		Player2:    player2,
+		Turn:       "player1",
+		Walls:      []*Wall{},
+		Moves:      []*Move{},
+	}
+
+	events := newGameEvents(state, 1, 0, GameStatusInProgress, false)
+
+	assert.Len(t, events, 0)
+}
+
+func TestReplayGameEvents_shouldRebuildGameFromLog(t *testing.T) {
+	log := &inMemoryGameLog{}
+	repo := new(MockGameRepository)
+	engine := NewGameEngine()
+	userService := new(MockUserService)
+	ratingService := new(MockRatingService)
+	bot := new(MockGameBot)
+	service := NewGameService(engine, repo, userService, ratingService, bot)
+
+	userService.On("GetUsersByIds", mock.Anything).Return(map[string]*users.User{}, nil)
+	repo.On("SaveGame", mock.Anything).Run(func(args mock.Arguments) {
+		log.save(args.Get(0).(*Game))
+	}).Return(nil)
+
+	state, err := service.CreateGame("player1", "player2", &GameSettings{Variant: GameVariantStandard, TimeControl: TimeControlRapid})
+	assert.NoError(t, err)
+	repo.On("GetGameById", state.GameId).Return(state, nil)
+
+	_, err = service.MakeMove(state.GameId, "player1", &Position{X: 4, Y: 1})
+	assert.NoError(t, err)
+	_, err = service.PlaceWall(state.GameId, "player2", &Wall{
+		Direction: Horizontal,
+		Pos1:      &Position{X: 2, Y: 2},
+		Pos2:      &Position{X: 3, Y: 2},
+	})
+	assert.NoError(t, err)
+	_, err = service.MakeMove(state.GameId, "player1", &Position{X: 5, Y: 1})
+	assert.NoError(t, err)
+	_, err = service.Resign(state.GameId, "player2")
+	assert.NoError(t, err)
+
+	assert.Len(t, log.events, 5)
+
+	replayed, err := replayGameEvents(log.events)
+
+	assert.NoError(t, err)
+	assert.Equal(t, GameStatusCompleted, replayed.GameStatus)
+	assert.Equal(t, EndReasonResign, replayed.EndReason)
+	assert.Equal(t, "player1", replayed.Winner)
+	assert.Equal(t, state.Turn, replayed.Turn)
+	assert.Equal(t, &Position{X: 5, Y: 1}, replayed.Player1.Position)
+	assert.Equal(t, &Position{X: 4, Y: 8}, replayed.Player2.Position)
+	assert.Equal(t, 9, replayed.Player2.Walls)
+	assert.Equal(t, state.Walls, replayed.Walls)
+	assert.Equal(t, state.Moves, replayed.Moves)
+	assert.True(t, state.CompletedAt.Equal(replayed.CompletedAt))
+}
+
+func TestReplayGameEvents_givenWinningMove_shouldKeepTurnOfTheWinner(t *testing.T) {
+	player1, player2 := newPlayers("player1", "player2")
+	player1.Position = &Position{X: 4, Y: 7}
+	state := &Game{
+		GameId:     "test-game-id",
+		GameStatus: GameStatusInProgress,
+		Player1:    player1,
+		Player2:    player2,
+		Turn:       "player1",
+		Walls:      []*Wall{},
+		Moves:      []*Move{},
+	}
+	events := newGameEvents(state, 0, 0, "", false)
+
+	state.Moves = append(state.Moves, &Move{UserId: "player1", Type: MoveTypeMove, Position: &Position{X: 4, Y: 8}})
+	state.Player1.Position = &Position{X: 4, Y: 8}
+	state.GameStatus = GameStatusCompleted
+	state.EndReason = EndReasonWin
+	state.Winner = "player1"
+	state.CompletedAt = time.Now()
+	events = append(events, newGameEvents(state, 1, 0, GameStatusInProgress, false)...)
+
+	replayed, err := replayGameEvents(events)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "player1", replayed.Turn)
+	assert.Equal(t, "player1", replayed.Winner)
+	assert.Equal(t, EndReasonWin, replayed.EndReason)
+	assert.Equal(t, &Position{X: 4, Y: 8}, replayed.Player1.Position)
+}
+
+func TestReplayGameEvents_givenRatedGame_shouldRestoreRatingChanges(t *testing.T) {
+	player1, player2 := newPlayers("player1", "player2")
+	state := &Game{
+		GameId:     "test-game-id",
+		GameStatus: GameStatusInProgress,
+		Rated:      true,
+		Player1:    player1,
+		Player2:    player2,
+		Turn:       "player1",
+		Walls:      []*Wall{},
+		Moves:      []*Move{},
+	}
+	events := newGameEvents(state, 0, 0, "", false)
+
+	state.GameStatus = GameStatusCompleted
+	state.EndReason = EndReasonResign
+	state.Winner = "player2"
+	state.CompletedAt = time.Now()
+	state.Player1.RatingChange = &ratings.RatingChange{UserId: "player1", RatingBefore: 1500, RatingAfter: 1490, Change: -10}
+	state.Player2.RatingChange = &ratings.RatingChange{UserId: "player2", RatingBefore: 1500, RatingAfter: 1510, Change: 10}
+	events = append(events, newGameEvents(state, 1, 0, GameStatusInProgress, false)...)
+
+	assert.Nil(t, events[0].Game.Player1.RatingChange)
+
+	replayed, err := replayGameEvents(events)
+
+	assert.NoError(t, err)
+	assert.True(t, replayed.Rated)
+	assert.Equal(t, state.Player1.RatingChange, replayed.Player1.RatingChange)
+	assert.Equal(t, state.Player2.RatingChange, replayed.Player2.RatingChange)
+}
+
+func TestNewGameEvents_givenGameRatedAfterCompletion_shouldAppendRatedEventOnce(t *testing.T) {
+	player1, player2 := newPlayers("player1", "player2")
+	state := &Game{
+		GameId:     "test-game-id",
+		GameStatus: GameStatusInProgress,
+		Rated:      true,
+		Player1:    player1,
+		Player2:    player2,
+		Turn:       "player1",
+		Walls:      []*Wall{},
+		Moves:      []*Move{},
+	}
+	log := &inMemoryGameLog{}
+	log.save(state)
+
+	state.GameStatus = GameStatusCompleted
+	state.EndReason = EndReasonResign
+	state.Winner = "player2"
+	state.CompletedAt = time.Now()
+	log.save(state)
+	completed := len(log.events)
+
+	state.Player1.RatingChange = &ratings.RatingChange{UserId: "player1", Change: -10}
+	state.Player2.RatingChange = &ratings.RatingChange{UserId: "player2", Change: 10}
+	log.save(state)
+	log.save(state)
+
+	assert.Len(t, log.events, completed+1)
+	assert.Equal(t, GameEventRated, log.events[completed].Type)
+	assert.Nil(t, log.events[completed-1].RatingChanges)
+
+	replayed, err := replayGameEvents(log.events)
+
+	assert.NoError(t, err)
+	assert.Equal(t, GameStatusCompleted, replayed.GameStatus)
+	assert.Equal(t, state.Player1.RatingChange, replayed.Player1.RatingChange)
+	assert.Equal(t, state.Player2.RatingChange, replayed.Player2.RatingChange)
+}
+
+func TestReplayGameEvents_givenGapInLog_shouldReturnError(t *testing.T) {
+	player1, player2 := newPlayers("player1", "player2")
+	state := &Game{
+		GameId:     "test-game-id",
+		GameStatus: GameStatusInProgress,
+		Player1:    player1,
+		Player2:    player2,
+		Turn:       "player1",
+		Walls:      []*Wall{},
+		Moves:      []*Move{{UserId: "player1", Type: MoveTypeMove, Position: &Position{X: 4, Y: 1}}},
+	}
+	events := newGameEvents(state, 0, 0, "", false)
+	events[1].Sequence = 3
+
+	_, err := replayGameEvents(events)
+
+	assert.Error(t, err)
+}
+
+func TestReplayGameEvents_givenLogWithoutCreatedEvent_shouldReturnError(t *testing.T) {
+	events := []*GameEvent{{GameId: "test-game-id", Sequence: 1, Type: GameEventMove}}
+
+	_, err := replayGameEvents(events)
+
+	assert.Error(t, err)
+}
+
+// keeps the log the same way as the event sourced repository does
+type inMemoryGameLog struct {
+	events     []*GameEvent
+	movesCount int
+	status     GameStatus
+	rated      bool
+}
+
+func (l *inMemoryGameLog) save(state *Game) {
+	l.events = append(l.events, newGameEvents(state, len(l.events), l.movesCount, l.status, l.rated)...)
+	l.movesCount = len(state.Moves)
+	l.status = state.GameStatus
+	l.rated = len(ratingChanges(state)) > 0
+}
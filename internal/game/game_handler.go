@@ -0,0 +1,76 @@
+package game
+
+import (
+	"log"
+	"net/http"
+	"quoridor/internal/errors"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type GameHandler interface {
+	HandleGetUserGames(c *gin.Context)
+}
+
+type GameHandlerImpl struct {
+	service GameService
+}
+
+func NewGameHandler(service GameService) *GameHandlerImpl {
+	return &GameHandlerImpl{
+		service: service,
+	}
+}
+
+// https://quoridory.domain.io/v1/users/1234/games?status=completed&result=win&opponent_id=5678&variant=standard&from=2024-01-01T00:00:00Z&to=2024-02-01T00:00:00Z&sort=desc&limit=20&cursor=...
+func (handler *GameHandlerImpl) HandleGetUserGames(c *gin.Context) {
+	filter, err := handler.parseHistoryFilter(c)
+	if err != nil {
+		log.Printf("Invalid game history request: err=%v", err)
+		c.JSON(http.StatusBadRequest, errors.ErrorResponse{ErrorType: errors.ErrBadRequest.Error()})
+		return
+	}
+
+	page, err := handler.service.GetGameHistory(filter)
+	if err != nil {
+		c.JSON(errors.HttpStatus(err), errors.ErrorResponse{ErrorType: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+func (handler *GameHandlerImpl) parseHistoryFilter(c *gin.Context) (*GameHistoryFilter, error) {
+	filter := &GameHistoryFilter{
+		UserId:     c.Param("user_id"),
+		Status:     GameStatus(c.Query("status")),
+		Result:     GameResult(c.Query("result")),
+		OpponentId: c.Query("opponent_id"),
+		Variant:    GameVariant(c.Query("variant")),
+		Sort:       SortOrder(c.Query("sort")),
+		Cursor:     c.Query("cursor"),
+	}
+
+	var err error
+	if value := c.Query("limit"); value != "" {
+		if filter.Limit, err = strconv.Atoi(value); err != nil {
+			return nil, err
+		}
+	}
+
+	if value := c.Query("from"); value != "" {
+		if filter.From, err = time.Parse(time.RFC3339, value); err != nil {
+			return nil, err
+		}
+	}
+
+	if value := c.Query("to"); value != "" {
+		if filter.To, err = time.Parse(time.RFC3339, value); err != nil {
+			return nil, err
+		}
+	}
+
+	return filter, nil
+}
@@ -15,6 +15,9 @@ type GameRepository interface {
 	GetGameById(gameID string) (*Game, error)
 	GetGamesByStatus(status GameStatus) ([]*Game, error)
 	GetGamesByUserIdAndStatus(userId string, status GameStatus) ([]*Game, error)
+	GetGameSummariesByUserId(filter *GameHistoryFilter, after *GameHistoryCursor, limit int) ([]*GameSummary, error)
+	GetGamesByStatusUpdatedBefore(status GameStatus, before time.Time, limit int) ([]*Game, error)
+	DeleteGame(gameId string) error
 }
 
 type MongoGameRepository struct {
@@ -136,3 +139,169 @@ func (r *MongoGameRepository) GetGamesByUserIdAndStatus(userId string, status Ga
 
 	return games, nil
 }
+
+func (r *MongoGameRepository) GetGamesByStatusUpdatedBefore(status GameStatus, before time.Time, limit int) ([]*Game, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{"status": status, "updated_at": bson.M{"$lt": before}}
+	cursor, err := r.collection.Find(ctx, filter, options.Find().SetLimit(int64(limit)))
+	if err != nil {
+		log.Printf("Error loading games by status: %v, updated before: %v: %v", status, before, err)
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	games := []*Game{}
+	for cursor.Next(ctx) {
+		var game Game
+		err := cursor.Decode(&game)
+		if err != nil {
+			log.Printf("Error decoding game: %v", err)
+			continue
+		}
+		games = append(games, &game)
+	}
+
+	if err := cursor.Err(); err != nil {
+		log.Printf("Cursor error: %v", err)
+		return nil, err
+	}
+
+	return games, nil
+}
+
+func (r *MongoGameRepository) DeleteGame(gameId string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := r.collection.DeleteOne(ctx, bson.M{"_id": gameId})
+	if err != nil {
+		log.Printf("Error deleting game: %v", err)
+		return err
+	}
+	return nil
+}
+
+func (r *MongoGameRepository) GetGameSummariesByUserId(filter *GameHistoryFilter, after *GameHistoryCursor, limit int) ([]*GameSummary, error) {
+	movesCount := bson.M{"$size": bson.M{"$ifNull": bson.A{"$moves", bson.A{}}}}
+	return findGameSummaries(r.collection, filter, after, limit, movesCount)
+}
+
+// movesCount is the aggregation expression used to compute the number of moves of the game
+func findGameSummaries(collection *mongo.Collection, filter *GameHistoryFilter, after *GameHistoryCursor, limit int, movesCount interface{}) ([]*GameSummary, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	sortDirection := -1
+	if filter.Sort == SortOrderAsc {
+		sortDirection = 1
+	}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: buildGameHistoryQuery(filter, after, sortDirection)}},
+		{{Key: "$sort", Value: bson.D{{Key: "created_at", Value: sortDirection}, {Key: "_id", Value: sortDirection}}}},
+		{{Key: "$limit", Value: limit}},
+		{{Key: "$project", Value: bson.M{
+			"status":                1,
+			"variant":               1,
+			"end_reason":            1,
+			"winner":                1,
+			"player_1.user_id":      1,
+			"player_1.display_name": 1,
+			"player_2.user_id":      1,
+			"player_2.display_name": 1,
+			"created_at":            1,
+			"completed_at":          1,
+			"moves_count":           movesCount,
+		}}},
+	}
+
+	cursor, err := collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		log.Printf("Error loading game history for user: %v: %v", filter.UserId, err)
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	summaries := []*GameSummary{}
+	for cursor.Next(ctx) {
+		var summary GameSummary
+		err := cursor.Decode(&summary)
+		if err != nil {
+			log.Printf("Error decoding game summary: %v", err)
+			continue
+		}
+		if summary.Variant == "" {
+			summary.Variant = GameVariantStandard
+		}
+		summaries = append(summaries, &summary)
+	}
+
+	if err := cursor.Err(); err != nil {
+		log.Printf("Cursor error: %v", err)
+		return nil, err
+	}
+
+	return summaries, nil
+}
+
+func buildGameHistoryQuery(filter *GameHistoryFilter, after *GameHistoryCursor, sortDirection int) bson.M {
+	conditions := []bson.M{}
+
+	if filter.OpponentId != "" {
+		conditions = append(conditions, bson.M{"$or": []bson.M{
+			{"player_1.user_id": filter.UserId, "player_2.user_id": filter.OpponentId},
+			{"player_1.user_id": filter.OpponentId, "player_2.user_id": filter.UserId},
+		}})
+	} else {
+		conditions = append(conditions, bson.M{"$or": []bson.M{
+			{"player_1.user_id": filter.UserId},
+			{"player_2.user_id": filter.UserId},
+		}})
+	}
+
+	if filter.Status != "" {
+		conditions = append(conditions, bson.M{"status": filter.Status})
+	}
+
+	switch filter.Result {
+	case GameResultWin:
+		conditions = append(conditions, bson.M{"winner": filter.UserId})
+	case GameResultLoss:
+		conditions = append(conditions, bson.M{"status": GameStatusCompleted, "winner": bson.M{"$ne": filter.UserId}})
+	}
+
+	// games created before the variants have no variant and were played on the standard board
+	if filter.Variant == GameVariantStandard {
+		conditions = append(conditions, bson.M{"variant": bson.M{"$in": bson.A{GameVariantStandard, nil}}})
+	} else if filter.Variant != "" {
+		conditions = append(conditions, bson.M{"variant": filter.Variant})
+	}
+
+	createdAt := bson.M{}
+	if !filter.From.IsZero() {
+		createdAt["$gte"] = filter.From
+	}
+	if !filter.To.IsZero() {
+		createdAt["$lt"] = filter.To
+	}
+	if len(createdAt) > 0 {
+		conditions = append(conditions, bson.M{"created_at": createdAt})
+	}
+
+	// keyset pagination: continue strictly after the (created_at, _id) of the last returned game
+	if after != nil {
+		operator := "$lt"
+		if sortDirection > 0 {
+			operator = "$gt"
+		}
+
+		conditions = append(conditions, bson.M{"$or": []bson.M{
+			{"created_at": bson.M{operator: after.CreatedAt}},
+			{"created_at": after.CreatedAt, "_id": bson.M{operator: after.GameId}},
+		}})
+	}
+
+	return bson.M{"$and": conditions}
+}
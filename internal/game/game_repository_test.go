@@ -0,0 +1,20 @@
+package game
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestBuildGameHistoryQuery_givenStandardVariant_shouldMatchGamesWithoutVariant(t *testing.T) {
+	query := buildGameHistoryQuery(&GameHistoryFilter{UserId: "player1", Variant: GameVariantStandard}, nil, -1)
+
+	assert.Contains(t, query["$and"], bson.M{"variant": bson.M{"$in": bson.A{GameVariantStandard, nil}}})
+}
+
+func TestBuildGameHistoryQuery_givenOtherVariant_shouldMatchItOnly(t *testing.T) {
+	query := buildGameHistoryQuery(&GameHistoryFilter{UserId: "player1", Variant: GameVariant("small")}, nil, -1)
+
+	assert.Contains(t, query["$and"], bson.M{"variant": GameVariant("small")})
+}
@@ -1,8 +1,12 @@
 package game
 
 import (
+	"encoding/base64"
+	"encoding/json"
 	"log"
 	"quoridor/internal/errors"
+	"quoridor/internal/ratings"
+	"quoridor/internal/users"
 	"time"
 
 	"github.com/google/uuid"
@@ -11,22 +15,36 @@ import (
 type GameService interface {
 	GetGameById(gameId string) (*Game, error)
 	GetActiveGameByUserId(userId string) (*Game, error)
-	CreateGame(user1Id, user2Id string) (*Game, error)
+	GetGameHistory(filter *GameHistoryFilter) (*GameHistoryPage, error)
+	GetGamesInProgress() ([]*Game, error)
+	CreateGame(user1Id, user2Id string, settings *GameSettings) (*Game, error)
+	CreateBotGame(userId string, botRating float64, settings *GameSettings) (*Game, error)
 	MakeMove(gameId, userId string, newPos *Position) (*Game, error)
 	PlaceWall(gameId, userId string, wall *Wall) (*Game, error)
 	Resign(gameId, userId string) (*Game, error)
 	Reconnect(gameId, userId string) (*Game, error)
 }
 
+const (
+	DEFAULT_HISTORY_PAGE_SIZE = 20
+	MAX_HISTORY_PAGE_SIZE     = 100
+)
+
 type GameServiceImpl struct {
-	engine     GameEngine
-	repository GameRepository
+	engine        GameEngine
+	repository    GameRepository
+	userService   users.UserService
+	ratingService ratings.RatingService
+	bot           GameBot
 }
 
-func NewGameService(engine GameEngine, repository GameRepository) GameService {
+func NewGameService(engine GameEngine, repository GameRepository, userService users.UserService, ratingService ratings.RatingService, bot GameBot) GameService {
 	return &GameServiceImpl{
-		engine:     engine,
-		repository: repository,
+		engine:        engine,
+		repository:    repository,
+		userService:   userService,
+		ratingService: ratingService,
+		bot:           bot,
 	}
 }
 
@@ -49,7 +67,7 @@ func (service *GameServiceImpl) GetGameById(gameId string) (*Game, error) {
 
 func (service *GameServiceImpl) GetActiveGameByUserId(userId string) (*Game, error) {
 	log.Printf("Fetching active game for user: userId=%v", userId)
-	
+
 	activeGames, err := service.repository.GetGamesByUserIdAndStatus(userId, GameStatusInProgress)
 	if err != nil {
 		log.Printf("Error while fetching active games for user: userId=%v, err=%v", userId, err)
@@ -63,35 +81,99 @@ func (service *GameServiceImpl) GetActiveGameByUserId(userId string) (*Game, err
 	return activeGames[0], nil
 }
 
-func (service *GameServiceImpl) CreateGame(user1Id, user2Id string) (*Game, error) {
-	log.Printf("Creating new game: user1Id=%v, user2Id=%v", user1Id, user2Id)
+func (service *GameServiceImpl) GetGamesInProgress() ([]*Game, error) {
+	log.Println("Fetching games in progress")
+
+	games, err := service.repository.GetGamesByStatus(GameStatusInProgress)
+	if err != nil {
+		log.Printf("Error while fetching games in progress: err=%v", err)
+		return nil, errors.ErrInternalError
+	}
+
+	return games, nil
+}
+
+func (service *GameServiceImpl) GetGameHistory(filter *GameHistoryFilter) (*GameHistoryPage, error) {
+	log.Printf("Fetching game history: userId=%v, filter=%+v", filter.UserId, *filter)
+
+	if !service.isHistoryFilterValid(filter) {
+		log.Printf("Invalid game history filter: %+v", *filter)
+		return nil, errors.ErrBadRequest
+	}
+
+	var after *GameHistoryCursor
+	if filter.Cursor != "" {
+		cursor, err := decodeHistoryCursor(filter.Cursor)
+		if err != nil {
+			log.Printf("Invalid game history cursor: cursor=%v, err=%v", filter.Cursor, err)
+			return nil, errors.ErrBadRequest
+		}
+		after = cursor
+	}
+
+	limit := filter.Limit
+	if limit == 0 {
+		limit = DEFAULT_HISTORY_PAGE_SIZE
+	}
+
+	// fetch one extra game to know whether there is a next page
+	summaries, err := service.repository.GetGameSummariesByUserId(filter, after, limit+1)
+	if err != nil {
+		log.Printf("Error while fetching game history: userId=%v, err=%v", filter.UserId, err)
+		return nil, errors.ErrInternalError
+	}
+
+	page := &GameHistoryPage{Games: summaries}
+	if len(summaries) > limit {
+		page.Games = summaries[:limit]
+		page.NextCursor = encodeHistoryCursor(page.Games[limit-1])
+	}
+
+	return page, nil
+}
+
+func (service *GameServiceImpl) CreateGame(user1Id, user2Id string, settings *GameSettings) (*Game, error) {
+	log.Printf("Creating new game: user1Id=%v, user2Id=%v, settings=%+v", user1Id, user2Id, *settings)
+
+	if !IsVariantSupported(settings.Variant) || !IsTimeControlSupported(settings.TimeControl) {
+		log.Printf("Unsupported game settings: %+v", *settings)
+		return nil, errors.ErrBadRequest
+	}
+
+	profiles, err := service.userService.GetUsersByIds([]string{user1Id, user2Id})
+	if err != nil {
+		log.Printf("Error while fetching players profiles. err=%v", err)
+		return nil, errors.ErrInternalError
+	}
 
 	gameId := uuid.NewString()
 	now := time.Now()
 
+	player1, player2 := newPlayers(user1Id, user2Id)
+	profile1, found1 := profiles[user1Id]
+	if found1 {
+		player1.DisplayName = profile1.DisplayName
+	}
+	profile2, found2 := profiles[user2Id]
+	if found2 {
+		player2.DisplayName = profile2.DisplayName
+	}
 	state := &Game{
-		GameId:     gameId,
-		GameStatus: GameStatusInProgress,
-		Player1: &Player{
-			UserId:   user1Id,
-			Position: &Position{X: 4, Y: 0},
-			Goal:     8,
-			Walls:    10,
-		},
-		Player2: &Player{
-			UserId:   user2Id,
-			Position: &Position{X: 4, Y: 8},
-			Goal:     0,
-			Walls:    10,
-		},
-		Turn:      user1Id,
-		Walls:     []*Wall{},
-		Moves:     []*Move{},
-		CreatedAt: now,
-		UpdatedAt: now,
-	}
-
-	err := service.repository.SaveGame(state)
+		GameId:      gameId,
+		GameStatus:  GameStatusInProgress,
+		Player1:     player1,
+		Player2:     player2,
+		Variant:     settings.Variant,
+		TimeControl: settings.TimeControl,
+		Rated:       settings.Ranked && found1 && found2 && !profile1.Guest && !profile2.Guest,
+		Turn:        user1Id,
+		Walls:       []*Wall{},
+		Moves:       []*Move{},
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+
+	err = service.repository.SaveGame(state)
 	if err != nil {
 		log.Printf("Error while saving the game. err=%v", err)
 		return nil, errors.ErrInternalError
@@ -101,6 +183,58 @@ func (service *GameServiceImpl) CreateGame(user1Id, user2Id string) (*Game, erro
 	return state, nil
 }
 
+// the user always makes the first move, games against a bot are never rated
+func (service *GameServiceImpl) CreateBotGame(userId string, botRating float64, settings *GameSettings) (*Game, error) {
+	log.Printf("Creating new bot game: userId=%v, botRating=%v, settings=%+v", userId, botRating, *settings)
+
+	if !IsVariantSupported(settings.Variant) || !IsTimeControlSupported(settings.TimeControl) {
+		log.Printf("Unsupported game settings: %+v", *settings)
+		return nil, errors.ErrBadRequest
+	}
+
+	profiles, err := service.userService.GetUsersByIds([]string{userId})
+	if err != nil {
+		log.Printf("Error while fetching player profile. err=%v", err)
+		return nil, errors.ErrInternalError
+	}
+
+	gameId := uuid.NewString()
+	now := time.Now()
+
+	player, bot := newPlayers(userId, BOT_USER_ID_PREFIX+uuid.NewString())
+	if profile, found := profiles[userId]; found {
+		player.DisplayName = profile.DisplayName
+	}
+	bot.DisplayName = BOT_DISPLAY_NAME
+	bot.Bot = true
+	bot.BotRating = botRating
+
+	state := &Game{
+		GameId:      gameId,
+		GameStatus:  GameStatusInProgress,
+		Player1:     player,
+		Player2:     bot,
+		Variant:     settings.Variant,
+		TimeControl: settings.TimeControl,
+		Rated:       false,
+		Bot:         true,
+		Turn:        userId,
+		Walls:       []*Wall{},
+		Moves:       []*Move{},
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+
+	err = service.repository.SaveGame(state)
+	if err != nil {
+		log.Printf("Error while saving the game. err=%v", err)
+		return nil, errors.ErrInternalError
+	}
+
+	log.Printf("Created bot game with id=%s for user with id %s", gameId, userId)
+	return state, nil
+}
+
 func (service *GameServiceImpl) MakeMove(gameId, userId string, newPos *Position) (*Game, error) {
 	log.Printf("Making move: gameId=%v, userId=%v, new position=%+v", gameId, userId, *newPos)
 
@@ -150,6 +284,7 @@ func (service *GameServiceImpl) MakeMove(gameId, userId string, newPos *Position
 	} else {
 		state.Turn = service.getNextTurn(state)
 		log.Printf("User with id=%s moved to position=%+v in game with id=%s", userId, *newPos, gameId)
+		service.playBotTurn(state)
 	}
 
 	err = service.repository.SaveGame(state)
@@ -158,6 +293,7 @@ func (service *GameServiceImpl) MakeMove(gameId, userId string, newPos *Position
 		return nil, errors.ErrInternalError
 	}
 
+	service.rateGame(state)
 	return state, nil
 }
 
@@ -208,6 +344,7 @@ func (service *GameServiceImpl) PlaceWall(gameId, userId string, wall *Wall) (*G
 
 	state.Walls = append(state.Walls, wall)
 	state.Turn = service.getNextTurn(state)
+	service.playBotTurn(state)
 
 	err = service.repository.SaveGame(state)
 	if err != nil {
@@ -237,17 +374,24 @@ func (service *GameServiceImpl) Resign(gameId, userId string) (*Game, error) {
 		return nil, errors.ErrGameNotInProgress
 	}
 
+	if state.Player1.UserId != userId && state.Player2.UserId != userId {
+		log.Printf("User with id=%s is not part of game with id=%s", userId, gameId)
+		return nil, errors.ErrNotAPlayer
+	}
+
 	state.GameStatus = GameStatusCompleted
 	state.EndReason = EndReasonResign
 	opponent := service.getOpponent(state, userId)
 	state.Winner = opponent.UserId
 	state.CompletedAt = time.Now()
+	state.UpdatedAt = state.CompletedAt
 
 	err = service.repository.SaveGame(state)
 	if err != nil {
 		log.Printf("Error while saving the game state. gameId=%v, err=%v", gameId, err)
 		return nil, errors.ErrInternalError
 	}
+	service.rateGame(state)
 
 	log.Printf("User with id=%s resigned from game with id=%s. Winner is user with id=%s", userId, gameId, opponent.UserId)
 	return state, nil
@@ -280,6 +424,91 @@ func (service *GameServiceImpl) Reconnect(gameId, userId string) (*Game, error)
 	return state, nil
 }
 
+/*
+makes the move of the bot right after the move of the user, so both moves are saved and sent together.
+the validity of the move is ensured by the bot itself
+*/
+func (service *GameServiceImpl) playBotTurn(state *Game) {
+	if !state.Bot || !IsBotId(state.Turn) {
+		return
+	}
+
+	move := service.bot.NextMove(state, state.Turn)
+	if move == nil {
+		log.Printf("Bot has no valid move in game with id=%s", state.GameId)
+		return
+	}
+
+	bot := service.getPlayer(state, move.UserId)
+	move.Timestamp = time.Now()
+	state.Moves = append(state.Moves, move)
+	state.UpdatedAt = move.Timestamp
+
+	switch move.Type {
+	case MoveTypeMove:
+		bot.Position = move.Position
+	case MoveTypePlaceWall:
+		bot.Walls--
+		state.Walls = append(state.Walls, move.Wall)
+	}
+
+	if move.Type == MoveTypeMove && service.engine.CheckWin(state, bot) {
+		state.GameStatus = GameStatusCompleted
+		state.EndReason = EndReasonWin
+		state.Winner = bot.UserId
+		state.CompletedAt = move.Timestamp
+		log.Printf("Bot has won the game with id=%s", state.GameId)
+		return
+	}
+
+	state.Turn = service.getNextTurn(state)
+}
+
+/*
+rates the game after it is saved as completed, so a game that failed to save is never rated.
+rating is idempotent per game, rating the same game again returns the same changes, which are then saved with the game.
+the game stays completed even when ratings can't be updated
+*/
+func (service *GameServiceImpl) rateGame(state *Game) {
+	if !state.Rated || state.GameStatus != GameStatusCompleted {
+		return
+	}
+
+	winner := service.getPlayer(state, state.Winner)
+	loser := service.getOpponent(state, state.Winner)
+
+	changes, err := service.ratingService.RateGame(state.GameId, string(state.Variant), winner.UserId, loser.UserId)
+	if err != nil {
+		log.Printf("Error while rating the game. gameId=%v, err=%v", state.GameId, err)
+		return
+	}
+
+	winner.RatingChange = changes[0]
+	loser.RatingChange = changes[1]
+
+	if err := service.repository.SaveGame(state); err != nil {
+		log.Printf("Error while saving the rating changes of the game. gameId=%v, err=%v", state.GameId, err)
+	}
+}
+
+// players in their starting positions, the first player starts at the bottom row
+func newPlayers(user1Id, user2Id string) (*Player, *Player) {
+	player1 := &Player{
+		UserId:   user1Id,
+		Position: &Position{X: 4, Y: 0},
+		Goal:     8,
+		Walls:    10,
+	}
+	player2 := &Player{
+		UserId:   user2Id,
+		Position: &Position{X: 4, Y: 8},
+		Goal:     0,
+		Walls:    10,
+	}
+
+	return player1, player2
+}
+
 func (service *GameServiceImpl) getOpponent(state *Game, playerId string) *Player {
 	if state.Player1.UserId == playerId {
 		return state.Player2
@@ -300,3 +529,72 @@ func (service *GameServiceImpl) getNextTurn(state *Game) string {
 	}
 	return state.Player1.UserId
 }
+
+func (service *GameServiceImpl) isHistoryFilterValid(filter *GameHistoryFilter) bool {
+	if filter.UserId == "" || filter.Limit < 0 || filter.Limit > MAX_HISTORY_PAGE_SIZE {
+		return false
+	}
+
+	switch filter.Status {
+	case "", GameStatusPending, GameStatusAborted, GameStatusInProgress, GameStatusCompleted:
+	default:
+		return false
+	}
+
+	switch filter.Result {
+	case "", GameResultWin, GameResultLoss:
+	default:
+		return false
+	}
+
+	switch filter.Sort {
+	case "", SortOrderAsc, SortOrderDesc:
+	default:
+		return false
+	}
+
+	return filter.From.IsZero() || filter.To.IsZero() || filter.From.Before(filter.To)
+}
+
+func encodeHistoryCursor(summary *GameSummary) string {
+	cursor := GameHistoryCursor{CreatedAt: summary.CreatedAt, GameId: summary.GameId}
+
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeHistoryCursor(value string) (*GameHistoryCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+
+	cursor := &GameHistoryCursor{}
+	if err := json.Unmarshal(data, cursor); err != nil {
+		return nil, err
+	}
+
+	return cursor, nil
+}
+
+func IsVariantSupported(variant GameVariant) bool {
+	return variant == GameVariantStandard
+}
+
+func IsTimeControlSupported(timeControl TimeControl) bool {
+	switch timeControl {
+	case TimeControlBlitz, TimeControlRapid, TimeControlClassical:
+		return true
+	default:
+		return false
+	}
+}
+
+// settings of the game when the player didn't choose any
+func DefaultGameSettings() GameSettings {
+	return GameSettings{
+		Ranked:      false,
+		Variant:     GameVariantStandard,
+		TimeControl: TimeControlRapid,
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"time"
 
 	"quoridor/internal/errors"
+	"quoridor/internal/ratings"
+	"quoridor/internal/users"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -34,10 +36,90 @@ func (m *MockGameRepository) GetGamesByUserIdAndStatus(userId string, status Gam
 	return args.Get(0).([]*Game), args.Error(1)
 }
 
+func (m *MockGameRepository) GetGameSummariesByUserId(filter *GameHistoryFilter, after *GameHistoryCursor, limit int) ([]*GameSummary, error) {
+	args := m.Called(filter, after, limit)
+	return args.Get(0).([]*GameSummary), args.Error(1)
+}
+
+func (m *MockGameRepository) GetGamesByStatusUpdatedBefore(status GameStatus, before time.Time, limit int) ([]*Game, error) {
+	args := m.Called(status, before, limit)
+	return args.Get(0).([]*Game), args.Error(1)
+}
+
+func (m *MockGameRepository) DeleteGame(gameId string) error {
+	args := m.Called(gameId)
+	return args.Error(0)
+}
+
+type MockUserService struct {
+	mock.Mock
+}
+
+func (m *MockUserService) RegisterUser(request *users.RegisterUserRequest) (*users.User, error) {
+	args := m.Called(request)
+	return args.Get(0).(*users.User), args.Error(1)
+}
+
+func (m *MockUserService) RegisterGuest() (*users.User, error) {
+	args := m.Called()
+	return args.Get(0).(*users.User), args.Error(1)
+}
+
+func (m *MockUserService) UpgradeGuest(userId string, request *users.RegisterUserRequest) (*users.User, error) {
+	args := m.Called(userId, request)
+	return args.Get(0).(*users.User), args.Error(1)
+}
+
+func (m *MockUserService) Authenticate(username, password string) (*users.User, error) {
+	args := m.Called(username, password)
+	return args.Get(0).(*users.User), args.Error(1)
+}
+
+func (m *MockUserService) GetUserById(userId string) (*users.User, error) {
+	args := m.Called(userId)
+	return args.Get(0).(*users.User), args.Error(1)
+}
+
+func (m *MockUserService) GetUsersByIds(userIds []string) (map[string]*users.User, error) {
+	args := m.Called(userIds)
+	return args.Get(0).(map[string]*users.User), args.Error(1)
+}
+
+func (m *MockUserService) UpdateUser(userId string, request *users.UpdateUserRequest) (*users.User, error) {
+	args := m.Called(userId, request)
+	return args.Get(0).(*users.User), args.Error(1)
+}
+
+type MockRatingService struct {
+	mock.Mock
+}
+
+func (m *MockRatingService) GetRatings(userIds []string, variant string) (map[string]*ratings.Rating, error) {
+	args := m.Called(userIds, variant)
+	return args.Get(0).(map[string]*ratings.Rating), args.Error(1)
+}
+
+func (m *MockRatingService) RateGame(gameId, variant, winnerId, loserId string) ([]*ratings.RatingChange, error) {
+	args := m.Called(gameId, variant, winnerId, loserId)
+	return args.Get(0).([]*ratings.RatingChange), args.Error(1)
+}
+
+type MockGameBot struct {
+	mock.Mock
+}
+
+func (m *MockGameBot) NextMove(state *Game, botId string) *Move {
+	args := m.Called(state, botId)
+	return args.Get(0).(*Move)
+}
+
 func TestGetGameById(t *testing.T) {
 	repo := new(MockGameRepository)
 	engine := NewGameEngine()
-	service := NewGameService(engine, repo)
+	userService := new(MockUserService)
+	ratingService := new(MockRatingService)
+	bot := new(MockGameBot)
+	service := NewGameService(engine, repo, userService, ratingService, bot)
 
 	state := &Game{
 		GameId:     "test-game-id",
@@ -62,7 +144,10 @@ func TestGetGameById(t *testing.T) {
 func TestGetGameById_givenNonExistentGameId_shouldReturnError(t *testing.T) {
 	repo := new(MockGameRepository)
 	engine := NewGameEngine()
-	service := NewGameService(engine, repo)
+	userService := new(MockUserService)
+	ratingService := new(MockRatingService)
+	bot := new(MockGameBot)
+	service := NewGameService(engine, repo, userService, ratingService, bot)
 
 	repo.On("GetGameById", "non-existent-game-id").Return((*Game)(nil), errors.ErrInternalError)
 
@@ -76,15 +161,27 @@ func TestGetGameById_givenNonExistentGameId_shouldReturnError(t *testing.T) {
 func TestCreateGame(t *testing.T) {
 	repo := new(MockGameRepository)
 	engine := NewGameEngine()
-	service := NewGameService(engine, repo)
+	userService := new(MockUserService)
+	ratingService := new(MockRatingService)
+	bot := new(MockGameBot)
+	service := NewGameService(engine, repo, userService, ratingService, bot)
 
 	repo.On("SaveGame", mock.Anything).Return(nil)
+	userService.On("GetUsersByIds", []string{"player1", "player2"}).Return(map[string]*users.User{
+		"player1": {UserId: "player1", DisplayName: "Player One"},
+	}, nil)
 
-	state, err := service.CreateGame("player1", "player2")
+	settings := DefaultGameSettings()
+	state, err := service.CreateGame("player1", "player2", &settings)
 	assert.NoError(t, err)
 	assert.Equal(t, "player1", state.Player1.UserId)
 	assert.Equal(t, "player2", state.Player2.UserId)
+	assert.Equal(t, "Player One", state.Player1.DisplayName)
+	assert.Empty(t, state.Player2.DisplayName)
 	assert.Equal(t, GameStatusInProgress, state.GameStatus)
+	assert.Equal(t, GameVariantStandard, state.Variant)
+	assert.Equal(t, TimeControlRapid, state.TimeControl)
+	assert.False(t, state.Rated)
 	assert.Equal(t, &Position{X: 4, Y: 0}, state.Player1.Position)
 	assert.Equal(t, &Position{X: 4, Y: 8}, state.Player2.Position)
 	assert.Equal(t, 10, state.Player1.Walls)
@@ -95,10 +192,89 @@ func TestCreateGame(t *testing.T) {
 	repo.AssertCalled(t, "SaveGame", mock.Anything)
 }
 
+func TestCreateGame_givenRankedSettings_shouldCreateRatedGameForRegisteredUsers(t *testing.T) {
+	repo := new(MockGameRepository)
+	engine := NewGameEngine()
+	userService := new(MockUserService)
+	ratingService := new(MockRatingService)
+	bot := new(MockGameBot)
+	service := NewGameService(engine, repo, userService, ratingService, bot)
+
+	repo.On("SaveGame", mock.Anything).Return(nil)
+	userService.On("GetUsersByIds", []string{"player1", "player2"}).Return(map[string]*users.User{
+		"player1": {UserId: "player1", DisplayName: "Player One"},
+		"player2": {UserId: "player2", DisplayName: "Player Two"},
+	}, nil)
+	userService.On("GetUsersByIds", []string{"player1", "guest1"}).Return(map[string]*users.User{
+		"player1": {UserId: "player1", DisplayName: "Player One"},
+		"guest1":  {UserId: "guest1", DisplayName: "Guest-1234abcd", Guest: true},
+	}, nil)
+
+	ranked := &GameSettings{Ranked: true, Variant: GameVariantStandard, TimeControl: TimeControlBlitz}
+	state, err := service.CreateGame("player1", "player2", ranked)
+	assert.NoError(t, err)
+	assert.True(t, state.Rated)
+	assert.Equal(t, TimeControlBlitz, state.TimeControl)
+
+	state, err = service.CreateGame("player1", "guest1", ranked)
+	assert.NoError(t, err)
+	assert.False(t, state.Rated)
+
+	casual := &GameSettings{Ranked: false, Variant: GameVariantStandard, TimeControl: TimeControlBlitz}
+	state, err = service.CreateGame("player1", "player2", casual)
+	assert.NoError(t, err)
+	assert.False(t, state.Rated)
+}
+
+func TestCreateGame_givenUnsupportedSettings_shouldReturnError(t *testing.T) {
+	repo := new(MockGameRepository)
+	engine := NewGameEngine()
+	userService := new(MockUserService)
+	ratingService := new(MockRatingService)
+	bot := new(MockGameBot)
+	service := NewGameService(engine, repo, userService, ratingService, bot)
+
+	settings := []*GameSettings{
+		{Variant: "hexagonal", TimeControl: TimeControlRapid},
+		{Variant: GameVariantStandard, TimeControl: "bullet"},
+		{},
+	}
+
+	for _, settings := range settings {
+		state, err := service.CreateGame("player1", "player2", settings)
+		assert.ErrorIs(t, err, errors.ErrBadRequest)
+		assert.Nil(t, state)
+	}
+
+	userService.AssertNotCalled(t, "GetUsersByIds", mock.Anything)
+	repo.AssertNotCalled(t, "SaveGame", mock.Anything)
+}
+
+func TestCreateGame_givenProfilesError_shouldReturnError(t *testing.T) {
+	repo := new(MockGameRepository)
+	engine := NewGameEngine()
+	userService := new(MockUserService)
+	ratingService := new(MockRatingService)
+	bot := new(MockGameBot)
+	service := NewGameService(engine, repo, userService, ratingService, bot)
+
+	userService.On("GetUsersByIds", []string{"player1", "player2"}).Return((map[string]*users.User)(nil), errors.ErrInternalError)
+
+	settings := DefaultGameSettings()
+	state, err := service.CreateGame("player1", "player2", &settings)
+
+	assert.ErrorIs(t, err, errors.ErrInternalError)
+	assert.Nil(t, state)
+	repo.AssertNotCalled(t, "SaveGame", mock.Anything)
+}
+
 func TestGetActiveGameByUserId(t *testing.T) {
 	repo := new(MockGameRepository)
 	engine := NewGameEngine()
-	service := NewGameService(engine, repo)
+	userService := new(MockUserService)
+	ratingService := new(MockRatingService)
+	bot := new(MockGameBot)
+	service := NewGameService(engine, repo, userService, ratingService, bot)
 
 	state := &Game{
 		GameId:     "active-game-id",
@@ -125,7 +301,10 @@ func TestGetActiveGameByUserId(t *testing.T) {
 func TestGetActiveGameByUserId_NoActiveGame(t *testing.T) {
 	repo := new(MockGameRepository)
 	engine := NewGameEngine()
-	service := NewGameService(engine, repo)
+	userService := new(MockUserService)
+	ratingService := new(MockRatingService)
+	bot := new(MockGameBot)
+	service := NewGameService(engine, repo, userService, ratingService, bot)
 
 	repo.On("GetGamesByUserIdAndStatus", "player1", GameStatusInProgress).Return([]*Game{}, nil)
 
@@ -139,9 +318,12 @@ func TestGetActiveGameByUserId_NoActiveGame(t *testing.T) {
 func TestGetActiveGameByUserId_ErrorFetchingGames(t *testing.T) {
 	repo := new(MockGameRepository)
 	engine := NewGameEngine()
-	service := NewGameService(engine, repo)
+	userService := new(MockUserService)
+	ratingService := new(MockRatingService)
+	bot := new(MockGameBot)
+	service := NewGameService(engine, repo, userService, ratingService, bot)
 
-	repo.On("GetGamesByUserIdAndStatus", "player1", GameStatusInProgress).Return(nil, errors.ErrInternalError)
+	repo.On("GetGamesByUserIdAndStatus", "player1", GameStatusInProgress).Return(([]*Game)(nil), errors.ErrInternalError)
 
 	activeGame, err := service.GetActiveGameByUserId("player1")
 
@@ -150,10 +332,148 @@ func TestGetActiveGameByUserId_ErrorFetchingGames(t *testing.T) {
 	repo.AssertCalled(t, "GetGamesByUserIdAndStatus", "player1", GameStatusInProgress)
 }
 
+func TestGetGamesInProgress(t *testing.T) {
+	repo := new(MockGameRepository)
+	engine := NewGameEngine()
+	userService := new(MockUserService)
+	ratingService := new(MockRatingService)
+	bot := new(MockGameBot)
+	service := NewGameService(engine, repo, userService, ratingService, bot)
+
+	games := []*Game{
+		{GameId: "game1", GameStatus: GameStatusInProgress},
+		{GameId: "game2", GameStatus: GameStatusInProgress},
+	}
+	repo.On("GetGamesByStatus", GameStatusInProgress).Return(games, nil)
+
+	result, err := service.GetGamesInProgress()
+
+	assert.NoError(t, err)
+	assert.Equal(t, games, result)
+	repo.AssertCalled(t, "GetGamesByStatus", GameStatusInProgress)
+}
+
+func TestGetGamesInProgress_givenRepositoryError_shouldReturnError(t *testing.T) {
+	repo := new(MockGameRepository)
+	engine := NewGameEngine()
+	userService := new(MockUserService)
+	ratingService := new(MockRatingService)
+	bot := new(MockGameBot)
+	service := NewGameService(engine, repo, userService, ratingService, bot)
+
+	repo.On("GetGamesByStatus", GameStatusInProgress).Return(([]*Game)(nil), errors.ErrInternalError)
+
+	result, err := service.GetGamesInProgress()
+
+	assert.ErrorIs(t, err, errors.ErrInternalError)
+	assert.Nil(t, result)
+}
+
+func TestGetGameHistory(t *testing.T) {
+	repo := new(MockGameRepository)
+	engine := NewGameEngine()
+	userService := new(MockUserService)
+	ratingService := new(MockRatingService)
+	bot := new(MockGameBot)
+	service := NewGameService(engine, repo, userService, ratingService, bot)
+
+	now := time.Now()
+	summaries := []*GameSummary{
+		{GameId: "game3", CreatedAt: now},
+		{GameId: "game2", CreatedAt: now.Add(-time.Minute)},
+		{GameId: "game1", CreatedAt: now.Add(-2 * time.Minute)},
+	}
+
+	filter := &GameHistoryFilter{UserId: "player1", Limit: 2}
+	repo.On("GetGameSummariesByUserId", filter, (*GameHistoryCursor)(nil), 3).Return(summaries, nil)
+
+	page, err := service.GetGameHistory(filter)
+
+	assert.NoError(t, err)
+	assert.Len(t, page.Games, 2)
+	assert.Equal(t, "game3", page.Games[0].GameId)
+	assert.Equal(t, "game2", page.Games[1].GameId)
+	assert.NotEmpty(t, page.NextCursor)
+
+	cursor, err := decodeHistoryCursor(page.NextCursor)
+	assert.NoError(t, err)
+	assert.Equal(t, "game2", cursor.GameId)
+	assert.True(t, summaries[1].CreatedAt.Equal(cursor.CreatedAt))
+}
+
+func TestGetGameHistory_givenLastPage_shouldNotReturnCursor(t *testing.T) {
+	repo := new(MockGameRepository)
+	engine := NewGameEngine()
+	userService := new(MockUserService)
+	ratingService := new(MockRatingService)
+	bot := new(MockGameBot)
+	service := NewGameService(engine, repo, userService, ratingService, bot)
+
+	after := &GameHistoryCursor{CreatedAt: time.Now().UTC(), GameId: "game2"}
+	summaries := []*GameSummary{{GameId: "game1"}}
+
+	filter := &GameHistoryFilter{UserId: "player1", Cursor: encodeHistoryCursor(&GameSummary{GameId: after.GameId, CreatedAt: after.CreatedAt})}
+	repo.On("GetGameSummariesByUserId", filter, mock.AnythingOfType("*game.GameHistoryCursor"), DEFAULT_HISTORY_PAGE_SIZE+1).Return(summaries, nil)
+
+	page, err := service.GetGameHistory(filter)
+
+	assert.NoError(t, err)
+	assert.Len(t, page.Games, 1)
+	assert.Empty(t, page.NextCursor)
+	repo.AssertCalled(t, "GetGameSummariesByUserId", filter, mock.MatchedBy(func(cursor *GameHistoryCursor) bool {
+		return cursor.GameId == after.GameId && cursor.CreatedAt.Equal(after.CreatedAt)
+	}), DEFAULT_HISTORY_PAGE_SIZE+1)
+}
+
+func TestGetGameHistory_givenInvalidCursor_shouldReturnError(t *testing.T) {
+	repo := new(MockGameRepository)
+	engine := NewGameEngine()
+	userService := new(MockUserService)
+	ratingService := new(MockRatingService)
+	bot := new(MockGameBot)
+	service := NewGameService(engine, repo, userService, ratingService, bot)
+
+	filter := &GameHistoryFilter{UserId: "player1", Cursor: "not a cursor"}
+
+	page, err := service.GetGameHistory(filter)
+
+	assert.ErrorIs(t, err, errors.ErrBadRequest)
+	assert.Nil(t, page)
+	repo.AssertNotCalled(t, "GetGameSummariesByUserId", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestGetGameHistory_givenInvalidFilter_shouldReturnError(t *testing.T) {
+	repo := new(MockGameRepository)
+	engine := NewGameEngine()
+	userService := new(MockUserService)
+	ratingService := new(MockRatingService)
+	bot := new(MockGameBot)
+	service := NewGameService(engine, repo, userService, ratingService, bot)
+
+	filters := []*GameHistoryFilter{
+		{UserId: ""},
+		{UserId: "player1", Status: "unknown"},
+		{UserId: "player1", Result: "draw"},
+		{UserId: "player1", Sort: "sideways"},
+		{UserId: "player1", Limit: MAX_HISTORY_PAGE_SIZE + 1},
+		{UserId: "player1", From: time.Now(), To: time.Now().Add(-time.Hour)},
+	}
+
+	for _, filter := range filters {
+		_, err := service.GetGameHistory(filter)
+		assert.ErrorIs(t, err, errors.ErrBadRequest)
+	}
+
+	repo.AssertNotCalled(t, "GetGameSummariesByUserId", mock.Anything, mock.Anything, mock.Anything)
+}
+
 func TestMakeMove(t *testing.T) {
 	repo := new(MockGameRepository)
 	engine := NewGameEngine()
-	service := NewGameService(engine, repo)
+	userService := new(MockUserService)
+	ratingService := new(MockRatingService)
+	bot := new(MockGameBot)
+	service := NewGameService(engine, repo, userService, ratingService, bot)
 
 	state := &Game{
 		GameId:     "test-game-id",
@@ -194,7 +514,10 @@ func TestMakeMove(t *testing.T) {
 func TestMakeMove_givenGameNotFound_shouldReturnError(t *testing.T) {
 	repo := new(MockGameRepository)
 	engine := NewGameEngine()
-	service := NewGameService(engine, repo)
+	userService := new(MockUserService)
+	ratingService := new(MockRatingService)
+	bot := new(MockGameBot)
+	service := NewGameService(engine, repo, userService, ratingService, bot)
 
 	repo.On("GetGameById", "test-game-id").Return((*Game)(nil), errors.ErrInternalError)
 
@@ -212,7 +535,10 @@ func TestMakeMove_givenGameNotFound_shouldReturnError(t *testing.T) {
 func TestMakeMove_givenGameNotInProgress_shouldReturnError(t *testing.T) {
 	repo := new(MockGameRepository)
 	engine := NewGameEngine()
-	service := NewGameService(engine, repo)
+	userService := new(MockUserService)
+	ratingService := new(MockRatingService)
+	bot := new(MockGameBot)
+	service := NewGameService(engine, repo, userService, ratingService, bot)
 
 	state := &Game{
 		GameId:     "test-game-id",
@@ -249,7 +575,10 @@ func TestMakeMove_givenGameNotInProgress_shouldReturnError(t *testing.T) {
 func TestMakeMove_givenInvalidMove_shouldReturnError(t *testing.T) {
 	repo := new(MockGameRepository)
 	engine := NewGameEngine()
-	service := NewGameService(engine, repo)
+	userService := new(MockUserService)
+	ratingService := new(MockRatingService)
+	bot := new(MockGameBot)
+	service := NewGameService(engine, repo, userService, ratingService, bot)
 
 	state := &Game{
 		GameId:     "test-game-id",
@@ -283,7 +612,10 @@ func TestMakeMove_givenInvalidMove_shouldReturnError(t *testing.T) {
 func TestMakeMove_givenNotPlayersTurn_shouldReturnError(t *testing.T) {
 	repo := new(MockGameRepository)
 	engine := NewGameEngine()
-	service := NewGameService(engine, repo)
+	userService := new(MockUserService)
+	ratingService := new(MockRatingService)
+	bot := new(MockGameBot)
+	service := NewGameService(engine, repo, userService, ratingService, bot)
 
 	state := &Game{
 		GameId:     "test-game-id",
@@ -317,7 +649,10 @@ func TestMakeMove_givenNotPlayersTurn_shouldReturnError(t *testing.T) {
 func TestMakeMove_givenWinningMove_shouldCompleteGame(t *testing.T) {
 	repo := new(MockGameRepository)
 	engine := NewGameEngine()
-	service := NewGameService(engine, repo)
+	userService := new(MockUserService)
+	ratingService := new(MockRatingService)
+	bot := new(MockGameBot)
+	service := NewGameService(engine, repo, userService, ratingService, bot)
 
 	state := &Game{
 		GameId:     "test-game-id",
@@ -355,7 +690,10 @@ func TestMakeMove_givenWinningMove_shouldCompleteGame(t *testing.T) {
 func TestPlaceWall(t *testing.T) {
 	repo := new(MockGameRepository)
 	engine := NewGameEngine()
-	service := NewGameService(engine, repo)
+	userService := new(MockUserService)
+	ratingService := new(MockRatingService)
+	bot := new(MockGameBot)
+	service := NewGameService(engine, repo, userService, ratingService, bot)
 
 	state := &Game{
 		GameId:     "test-game-id",
@@ -402,7 +740,10 @@ func TestPlaceWall(t *testing.T) {
 func TestPlaceWall_givenGameNotFound_shouldReturnError(t *testing.T) {
 	repo := new(MockGameRepository)
 	engine := NewGameEngine()
-	service := NewGameService(engine, repo)
+	userService := new(MockUserService)
+	ratingService := new(MockRatingService)
+	bot := new(MockGameBot)
+	service := NewGameService(engine, repo, userService, ratingService, bot)
 
 	repo.On("GetGameById", "test-game-id").Return((*Game)(nil), errors.ErrInternalError)
 
@@ -422,7 +763,10 @@ func TestPlaceWall_givenGameNotFound_shouldReturnError(t *testing.T) {
 func TestPlaceWall_givenGameNotInProgress_shouldReturnError(t *testing.T) {
 	repo := new(MockGameRepository)
 	engine := NewGameEngine()
-	service := NewGameService(engine, repo)
+	userService := new(MockUserService)
+	ratingService := new(MockRatingService)
+	bot := new(MockGameBot)
+	service := NewGameService(engine, repo, userService, ratingService, bot)
 
 	state := &Game{
 		GameId:     "test-game-id",
@@ -461,7 +805,10 @@ func TestPlaceWall_givenGameNotInProgress_shouldReturnError(t *testing.T) {
 func TestPlaceWall_givenInvalidPlacement_shouldReturnError(t *testing.T) {
 	repo := new(MockGameRepository)
 	engine := NewGameEngine()
-	service := NewGameService(engine, repo)
+	userService := new(MockUserService)
+	ratingService := new(MockRatingService)
+	bot := new(MockGameBot)
+	service := NewGameService(engine, repo, userService, ratingService, bot)
 
 	state := &Game{
 		GameId:     "test-game-id",
@@ -500,7 +847,10 @@ func TestPlaceWall_givenInvalidPlacement_shouldReturnError(t *testing.T) {
 func TestPlaceWall_givenNotPlayersTurn_shouldReturnError(t *testing.T) {
 	repo := new(MockGameRepository)
 	engine := NewGameEngine()
-	service := NewGameService(engine, repo)
+	userService := new(MockUserService)
+	ratingService := new(MockRatingService)
+	bot := new(MockGameBot)
+	service := NewGameService(engine, repo, userService, ratingService, bot)
 
 	state := &Game{
 		GameId:     "test-game-id",
@@ -539,7 +889,10 @@ func TestPlaceWall_givenNotPlayersTurn_shouldReturnError(t *testing.T) {
 func TestPlaceWall_givenNoWallsLeft_shouldReturnError(t *testing.T) {
 	repo := new(MockGameRepository)
 	engine := NewGameEngine()
-	service := NewGameService(engine, repo)
+	userService := new(MockUserService)
+	ratingService := new(MockRatingService)
+	bot := new(MockGameBot)
+	service := NewGameService(engine, repo, userService, ratingService, bot)
 
 	state := &Game{
 		GameId:     "test-game-id",
@@ -578,7 +931,10 @@ func TestPlaceWall_givenNoWallsLeft_shouldReturnError(t *testing.T) {
 func TestResign(t *testing.T) {
 	repo := new(MockGameRepository)
 	engine := NewGameEngine()
-	service := NewGameService(engine, repo)
+	userService := new(MockUserService)
+	ratingService := new(MockRatingService)
+	bot := new(MockGameBot)
+	service := NewGameService(engine, repo, userService, ratingService, bot)
 
 	state := &Game{
 		GameId:     "test-game-id",
@@ -613,10 +969,40 @@ func TestResign(t *testing.T) {
 	repo.AssertCalled(t, "SaveGame", mock.Anything)
 }
 
+func TestResign_givenUserNotAPlayer_shouldReturnError(t *testing.T) {
+	repo := new(MockGameRepository)
+	engine := NewGameEngine()
+	userService := new(MockUserService)
+	ratingService := new(MockRatingService)
+	bot := new(MockGameBot)
+	service := NewGameService(engine, repo, userService, ratingService, bot)
+
+	state := &Game{
+		GameId:     "test-game-id",
+		GameStatus: GameStatusInProgress,
+		Rated:      true,
+		Player1:    &Player{UserId: "player1", Position: &Position{X: 4, Y: 0}, Goal: 8, Walls: 10},
+		Player2:    &Player{UserId: "player2", Position: &Position{X: 4, Y: 8}, Goal: 0, Walls: 10},
+		Turn:       "player1",
+	}
+
+	repo.On("GetGameById", "test-game-id").Return(state, nil)
+
+	_, err := service.Resign("test-game-id", "stranger")
+	assert.ErrorIs(t, err, errors.ErrNotAPlayer)
+	assert.Equal(t, GameStatusInProgress, state.GameStatus)
+
+	repo.AssertNotCalled(t, "SaveGame", mock.Anything)
+	ratingService.AssertNotCalled(t, "RateGame", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
 func TestResign_givenGameNotFound_shouldReturnError(t *testing.T) {
 	repo := new(MockGameRepository)
 	engine := NewGameEngine()
-	service := NewGameService(engine, repo)
+	userService := new(MockUserService)
+	ratingService := new(MockRatingService)
+	bot := new(MockGameBot)
+	service := NewGameService(engine, repo, userService, ratingService, bot)
 
 	repo.On("GetGameById", "test-game-id").Return((*Game)(nil), errors.ErrInternalError)
 
@@ -630,7 +1016,10 @@ func TestResign_givenGameNotFound_shouldReturnError(t *testing.T) {
 func TestResign_givenGameNotInProgress_shouldReturnError(t *testing.T) {
 	repo := new(MockGameRepository)
 	engine := NewGameEngine()
-	service := NewGameService(engine, repo)
+	userService := new(MockUserService)
+	ratingService := new(MockRatingService)
+	bot := new(MockGameBot)
+	service := NewGameService(engine, repo, userService, ratingService, bot)
 
 	state := &Game{
 		GameId:     "test-game-id",
@@ -663,7 +1052,10 @@ func TestResign_givenGameNotInProgress_shouldReturnError(t *testing.T) {
 func TestReconnect(t *testing.T) {
 	repo := new(MockGameRepository)
 	engine := NewGameEngine()
-	service := NewGameService(engine, repo)
+	userService := new(MockUserService)
+	ratingService := new(MockRatingService)
+	bot := new(MockGameBot)
+	service := NewGameService(engine, repo, userService, ratingService, bot)
 
 	state := &Game{
 		GameId:     "test-game-id",
@@ -695,7 +1087,10 @@ func TestReconnect(t *testing.T) {
 func TestReconnect_givenNonExistentGameId_shouldReturnError(t *testing.T) {
 	repo := new(MockGameRepository)
 	engine := NewGameEngine()
-	service := NewGameService(engine, repo)
+	userService := new(MockUserService)
+	ratingService := new(MockRatingService)
+	bot := new(MockGameBot)
+	service := NewGameService(engine, repo, userService, ratingService, bot)
 
 	repo.On("GetGameById", "non-existent-game-id").Return((*Game)(nil), errors.ErrInternalError)
 
@@ -708,7 +1103,10 @@ func TestReconnect_givenNonExistentGameId_shouldReturnError(t *testing.T) {
 func TestReconnect_givenGameNotInProgress_shouldReturnError(t *testing.T) {
 	repo := new(MockGameRepository)
 	engine := NewGameEngine()
-	service := NewGameService(engine, repo)
+	userService := new(MockUserService)
+	ratingService := new(MockRatingService)
+	bot := new(MockGameBot)
+	service := NewGameService(engine, repo, userService, ratingService, bot)
 
 	state := &Game{
 		GameId:     "test-game-id",
@@ -740,7 +1138,10 @@ func TestReconnect_givenGameNotInProgress_shouldReturnError(t *testing.T) {
 func TestReconnect_givenUserNotInGame_shouldReturnError(t *testing.T) {
 	repo := new(MockGameRepository)
 	engine := NewGameEngine()
-	service := NewGameService(engine, repo)
+	userService := new(MockUserService)
+	ratingService := new(MockRatingService)
+	bot := new(MockGameBot)
+	service := NewGameService(engine, repo, userService, ratingService, bot)
 
 	state := &Game{
 		GameId:     "test-game-id",
@@ -768,3 +1169,250 @@ func TestReconnect_givenUserNotInGame_shouldReturnError(t *testing.T) {
 	assert.Nil(t, retrievedState)
 	repo.AssertCalled(t, "GetGameById", "test-game-id")
 }
+
+func TestMakeMove_givenWinningMoveInRatedGame_shouldUpdateRatings(t *testing.T) {
+	repo := new(MockGameRepository)
+	engine := NewGameEngine()
+	userService := new(MockUserService)
+	ratingService := new(MockRatingService)
+	bot := new(MockGameBot)
+	service := NewGameService(engine, repo, userService, ratingService, bot)
+
+	state := &Game{
+		GameId:     "test-game-id",
+		GameStatus: GameStatusInProgress,
+		Variant:    GameVariantStandard,
+		Rated:      true,
+		Player1: &Player{
+			UserId:   "player1",
+			Position: &Position{X: 4, Y: 7},
+			Goal:     8,
+			Walls:    10,
+		},
+		Player2: &Player{
+			UserId:   "player2",
+			Position: &Position{X: 4, Y: 0},
+			Goal:     0,
+			Walls:    10,
+		},
+		Turn:  "player1",
+		Walls: []*Wall{},
+	}
+
+	winnerChange := &ratings.RatingChange{UserId: "player1", RatingBefore: 1500, RatingAfter: 1662, Change: 162, Provisional: true}
+	loserChange := &ratings.RatingChange{UserId: "player2", RatingBefore: 1500, RatingAfter: 1338, Change: -162, Provisional: true}
+
+	repo.On("GetGameById", "test-game-id").Return(state, nil)
+	repo.On("SaveGame", mock.Anything).Return(nil)
+	ratingService.On("RateGame", "test-game-id", "standard", "player1", "player2").Return([]*ratings.RatingChange{winnerChange, loserChange}, nil)
+
+	updatedState, err := service.MakeMove("test-game-id", "player1", &Position{X: 4, Y: 8})
+
+	assert.NoError(t, err)
+	assert.Equal(t, GameStatusCompleted, updatedState.GameStatus)
+	assert.Equal(t, winnerChange, updatedState.Player1.RatingChange)
+	assert.Equal(t, loserChange, updatedState.Player2.RatingChange)
+	ratingService.AssertCalled(t, "RateGame", "test-game-id", "standard", "player1", "player2")
+}
+
+func TestResign_givenRatedGame_shouldUpdateRatings(t *testing.T) {
+	repo := new(MockGameRepository)
+	engine := NewGameEngine()
+	userService := new(MockUserService)
+	ratingService := new(MockRatingService)
+	bot := new(MockGameBot)
+	service := NewGameService(engine, repo, userService, ratingService, bot)
+
+	state := &Game{
+		GameId:     "test-game-id",
+		GameStatus: GameStatusInProgress,
+		Variant:    GameVariantStandard,
+		Rated:      true,
+		Player1:    &Player{UserId: "player1", Position: &Position{X: 4, Y: 4}, Goal: 8, Walls: 10},
+		Player2:    &Player{UserId: "player2", Position: &Position{X: 4, Y: 8}, Goal: 0, Walls: 10},
+		Turn:       "player1",
+		Walls:      []*Wall{},
+	}
+
+	winnerChange := &ratings.RatingChange{UserId: "player2", Change: 10}
+	loserChange := &ratings.RatingChange{UserId: "player1", Change: -10}
+
+	repo.On("GetGameById", "test-game-id").Return(state, nil)
+	repo.On("SaveGame", mock.Anything).Return(nil)
+	ratingService.On("RateGame", "test-game-id", "standard", "player2", "player1").Return([]*ratings.RatingChange{winnerChange, loserChange}, nil)
+
+	updatedState, err := service.Resign("test-game-id", "player1")
+
+	assert.NoError(t, err)
+	assert.Equal(t, loserChange, updatedState.Player1.RatingChange)
+	assert.Equal(t, winnerChange, updatedState.Player2.RatingChange)
+}
+
+func TestResign_givenRatingError_shouldCompleteGame(t *testing.T) {
+	repo := new(MockGameRepository)
+	engine := NewGameEngine()
+	userService := new(MockUserService)
+	ratingService := new(MockRatingService)
+	bot := new(MockGameBot)
+	service := NewGameService(engine, repo, userService, ratingService, bot)
+
+	state := &Game{
+		GameId:     "test-game-id",
+		GameStatus: GameStatusInProgress,
+		Variant:    GameVariantStandard,
+		Rated:      true,
+		Player1:    &Player{UserId: "player1", Position: &Position{X: 4, Y: 4}, Goal: 8, Walls: 10},
+		Player2:    &Player{UserId: "player2", Position: &Position{X: 4, Y: 8}, Goal: 0, Walls: 10},
+		Turn:       "player1",
+		Walls:      []*Wall{},
+	}
+
+	repo.On("GetGameById", "test-game-id").Return(state, nil)
+	repo.On("SaveGame", mock.Anything).Return(nil)
+	ratingService.On("RateGame", "test-game-id", "standard", "player2", "player1").Return(([]*ratings.RatingChange)(nil), errors.ErrInternalError)
+
+	updatedState, err := service.Resign("test-game-id", "player1")
+
+	assert.NoError(t, err)
+	assert.Equal(t, GameStatusCompleted, updatedState.GameStatus)
+	assert.Nil(t, updatedState.Player1.RatingChange)
+	repo.AssertCalled(t, "SaveGame", mock.Anything)
+}
+
+func TestResign_givenRatedGameSaveError_shouldNotRateGame(t *testing.T) {
+	repo := new(MockGameRepository)
+	engine := NewGameEngine()
+	userService := new(MockUserService)
+	ratingService := new(MockRatingService)
+	bot := new(MockGameBot)
+	service := NewGameService(engine, repo, userService, ratingService, bot)
+
+	state := &Game{
+		GameId:     "test-game-id",
+		GameStatus: GameStatusInProgress,
+		Variant:    GameVariantStandard,
+		Rated:      true,
+		Player1:    &Player{UserId: "player1", Position: &Position{X: 4, Y: 4}, Goal: 8, Walls: 10},
+		Player2:    &Player{UserId: "player2", Position: &Position{X: 4, Y: 8}, Goal: 0, Walls: 10},
+		Turn:       "player1",
+		Walls:      []*Wall{},
+	}
+
+	repo.On("GetGameById", "test-game-id").Return(state, nil)
+	repo.On("SaveGame", mock.Anything).Return(errors.ErrInternalError)
+
+	_, err := service.Resign("test-game-id", "player1")
+
+	assert.Equal(t, errors.ErrInternalError, err)
+	ratingService.AssertNotCalled(t, "RateGame", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestCreateBotGame(t *testing.T) {
+	repo := new(MockGameRepository)
+	engine := NewGameEngine()
+	userService := new(MockUserService)
+	ratingService := new(MockRatingService)
+	bot := new(MockGameBot)
+	service := NewGameService(engine, repo, userService, ratingService, bot)
+
+	userService.On("GetUsersByIds", []string{"player1"}).Return(map[string]*users.User{
+		"player1": {UserId: "player1", DisplayName: "Alice"},
+	}, nil)
+	repo.On("SaveGame", mock.Anything).Return(nil)
+
+	settings := &GameSettings{Ranked: true, Variant: GameVariantStandard, TimeControl: TimeControlBlitz}
+	state, err := service.CreateBotGame("player1", 1640, settings)
+
+	assert.NoError(t, err)
+	assert.True(t, state.Bot)
+	assert.False(t, state.Rated)
+	assert.Equal(t, "player1", state.Turn)
+	assert.Equal(t, "Alice", state.Player1.DisplayName)
+	assert.False(t, state.Player1.Bot)
+	assert.True(t, state.Player2.Bot)
+	assert.True(t, IsBotId(state.Player2.UserId))
+	assert.Equal(t, BOT_DISPLAY_NAME, state.Player2.DisplayName)
+	assert.Equal(t, 1640.0, state.Player2.BotRating)
+	assert.Equal(t, TimeControlBlitz, state.TimeControl)
+
+	repo.AssertCalled(t, "SaveGame", state)
+}
+
+func TestMakeMove_givenBotGame_shouldPlayBotTurn(t *testing.T) {
+	repo := new(MockGameRepository)
+	engine := NewGameEngine()
+	userService := new(MockUserService)
+	ratingService := new(MockRatingService)
+	bot := new(MockGameBot)
+	service := NewGameService(engine, repo, userService, ratingService, bot)
+
+	player, botPlayer := newPlayers("player1", BOT_USER_ID_PREFIX+"1")
+	botPlayer.Bot = true
+	state := &Game{
+		GameId:     "test-game-id",
+		GameStatus: GameStatusInProgress,
+		Bot:        true,
+		Player1:    player,
+		Player2:    botPlayer,
+		Turn:       "player1",
+		Walls:      []*Wall{},
+		Moves:      []*Move{},
+	}
+
+	botWall := &Wall{Direction: Horizontal, Pos1: &Position{X: 4, Y: 1}, Pos2: &Position{X: 4, Y: 2}}
+	repo.On("GetGameById", "test-game-id").Return(state, nil)
+	repo.On("SaveGame", mock.Anything).Return(nil)
+	bot.On("NextMove", state, botPlayer.UserId).Return(&Move{UserId: botPlayer.UserId, Type: MoveTypePlaceWall, Wall: botWall})
+
+	updatedState, err := service.MakeMove("test-game-id", "player1", &Position{X: 4, Y: 1})
+
+	assert.NoError(t, err)
+	assert.Len(t, updatedState.Moves, 2)
+	assert.Equal(t, "player1", updatedState.Moves[0].UserId)
+	assert.Equal(t, botPlayer.UserId, updatedState.Moves[1].UserId)
+	assert.NotEmpty(t, updatedState.Moves[1].Timestamp)
+	assert.Equal(t, []*Wall{botWall}, updatedState.Walls)
+	assert.Equal(t, 9, updatedState.Player2.Walls)
+	assert.Equal(t, "player1", updatedState.Turn)
+
+	repo.AssertNumberOfCalls(t, "SaveGame", 1)
+}
+
+func TestPlaceWall_givenBotMakesWinningMove_shouldCompleteGame(t *testing.T) {
+	repo := new(MockGameRepository)
+	engine := NewGameEngine()
+	userService := new(MockUserService)
+	ratingService := new(MockRatingService)
+	bot := new(MockGameBot)
+	service := NewGameService(engine, repo, userService, ratingService, bot)
+
+	player, botPlayer := newPlayers("player1", BOT_USER_ID_PREFIX+"1")
+	botPlayer.Bot = true
+	botPlayer.Position = &Position{X: 0, Y: 1}
+	state := &Game{
+		GameId:     "test-game-id",
+		GameStatus: GameStatusInProgress,
+		Bot:        true,
+		Player1:    player,
+		Player2:    botPlayer,
+		Turn:       "player1",
+		Walls:      []*Wall{},
+		Moves:      []*Move{},
+	}
+
+	repo.On("GetGameById", "test-game-id").Return(state, nil)
+	repo.On("SaveGame", mock.Anything).Return(nil)
+	bot.On("NextMove", state, botPlayer.UserId).Return(&Move{UserId: botPlayer.UserId, Type: MoveTypeMove, Position: &Position{X: 0, Y: 0}})
+
+	wall := &Wall{Direction: Horizontal, Pos1: &Position{X: 6, Y: 4}, Pos2: &Position{X: 6, Y: 5}}
+	updatedState, err := service.PlaceWall("test-game-id", "player1", wall)
+
+	assert.NoError(t, err)
+	assert.Equal(t, GameStatusCompleted, updatedState.GameStatus)
+	assert.Equal(t, EndReasonWin, updatedState.EndReason)
+	assert.Equal(t, botPlayer.UserId, updatedState.Winner)
+	assert.Equal(t, botPlayer.UserId, updatedState.Turn)
+	assert.NotEmpty(t, updatedState.CompletedAt)
+
+	ratingService.AssertNotCalled(t, "RateGame", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
@@ -1,6 +1,9 @@
 package game
 
-import "time"
+import (
+	"quoridor/internal/ratings"
+	"time"
+)
 
 type GameStatus string
 
@@ -25,6 +28,34 @@ const (
 	MoveTypePlaceWall MoveType = "place_wall"
 )
 
+type GameVariant string
+
+const (
+	GameVariantStandard GameVariant = "standard"
+)
+
+type TimeControl string
+
+const (
+	TimeControlBlitz     TimeControl = "blitz"
+	TimeControlRapid     TimeControl = "rapid"
+	TimeControlClassical TimeControl = "classical"
+)
+
+type GameResult string
+
+const (
+	GameResultWin  GameResult = "win"
+	GameResultLoss GameResult = "loss"
+)
+
+type SortOrder string
+
+const (
+	SortOrderAsc  SortOrder = "asc"
+	SortOrderDesc SortOrder = "desc"
+)
+
 type GameEndReason string
 
 const (
@@ -35,6 +66,10 @@ const (
 type Game struct {
 	GameId      string        `bson:"_id" json:"game_id"`
 	GameStatus  GameStatus    `bson:"status" json:"status"`
+	Variant     GameVariant   `bson:"variant" json:"variant"`
+	TimeControl TimeControl   `bson:"time_control,omitempty" json:"time_control,omitempty"`
+	Rated       bool          `bson:"rated" json:"rated"`                 // only ranked games between registered users change ratings
+	Bot         bool          `bson:"bot,omitempty" json:"bot,omitempty"` // one of the players is a server-side bot
 	EndReason   GameEndReason `bson:"end_reason,omitempty" json:"end_reason,omitempty"`
 	Winner      string        `bson:"winner,omitempty" json:"winner,omitempty"` // id of the winner
 	Turn        string        `bson:"turn" json:"turn"`                         // id of the player
@@ -47,11 +82,24 @@ type Game struct {
 	CompletedAt time.Time     `bson:"completed_at,omitempty" json:"completed_at,omitempty"`
 }
 
+// settings chosen by the players when they join the matchmaking, players are matched only with the same settings
+type GameSettings struct {
+	Ranked      bool        `json:"ranked"`
+	Variant     GameVariant `json:"variant"`
+	TimeControl TimeControl `json:"time_control"`
+}
+
 type Player struct {
-	UserId   string    `bson:"user_id" json:"user_id"`
-	Position *Position `bson:"position" json:"position"`
-	Goal     int       `bson:"goal" json:"goal"`   // row user needs to get to to win the game
-	Walls    int       `bson:"walls" json:"walls"` // number of walls available
+	UserId      string    `bson:"user_id" json:"user_id"`
+	DisplayName string    `bson:"display_name,omitempty" json:"display_name,omitempty"`
+	Position    *Position `bson:"position" json:"position"`
+	Goal        int       `bson:"goal" json:"goal"`   // row user needs to get to to win the game
+	Walls       int       `bson:"walls" json:"walls"` // number of walls available
+	Bot         bool      `bson:"bot,omitempty" json:"bot,omitempty"`
+	BotRating   float64   `bson:"bot_rating,omitempty" json:"bot_rating,omitempty"` // strength of the bot
+
+	// set when a rated game is completed
+	RatingChange *ratings.RatingChange `bson:"rating_change,omitempty" json:"rating_change,omitempty"`
 }
 
 type Move struct {
@@ -77,3 +125,108 @@ type Position struct {
 	X int `bson:"x" json:"x"`
 	Y int `bson:"y" json:"y"`
 }
+
+// lightweight view of the game used in the game history, doesn't include moves and walls
+type GameSummary struct {
+	GameId      string         `bson:"_id" json:"game_id"`
+	GameStatus  GameStatus     `bson:"status" json:"status"`
+	Variant     GameVariant    `bson:"variant" json:"variant"`
+	EndReason   GameEndReason  `bson:"end_reason,omitempty" json:"end_reason,omitempty"`
+	Winner      string         `bson:"winner,omitempty" json:"winner,omitempty"`
+	Player1     *PlayerSummary `bson:"player_1" json:"player_1"`
+	Player2     *PlayerSummary `bson:"player_2" json:"player_2"`
+	MovesCount  int            `bson:"moves_count" json:"moves_count"`
+	CreatedAt   time.Time      `bson:"created_at" json:"created_at"`
+	CompletedAt time.Time      `bson:"completed_at,omitempty" json:"completed_at,omitempty"`
+}
+
+type PlayerSummary struct {
+	UserId      string `bson:"user_id" json:"user_id"`
+	DisplayName string `bson:"display_name,omitempty" json:"display_name,omitempty"`
+}
+
+// all fields except UserId are optional
+type GameHistoryFilter struct {
+	UserId     string
+	Status     GameStatus
+	Result     GameResult
+	OpponentId string
+	Variant    GameVariant
+	From       time.Time // inclusive
+	To         time.Time // exclusive
+	Sort       SortOrder
+	Cursor     string
+	Limit      int
+}
+
+// position of the last returned game, games are ordered by creation time and id
+type GameHistoryCursor struct {
+	CreatedAt time.Time `json:"created_at"`
+	GameId    string    `json:"game_id"`
+}
+
+type GameHistoryPage struct {
+	Games      []*GameSummary `json:"games"`
+	NextCursor string         `json:"next_cursor,omitempty"`
+}
+
+type GameEventType string
+
+const (
+	GameEventCreated       GameEventType = "game_created"
+	GameEventMove          GameEventType = "move"
+	GameEventStatusChanged GameEventType = "status_changed"
+	GameEventRated         GameEventType = "game_rated"
+)
+
+/*
+immutable entry of the game log, the game is a projection of its events:
+  - game_created holds the initial state of the game without moves
+  - move holds a pawn move or a wall placement and the turn after it
+  - status_changed holds the new status and the outcome of the game
+  - game_rated holds the rating changes of the players
+*/
+type GameEvent struct {
+	EventId   string        `bson:"_id" json:"event_id"` // <game id>:<sequence>
+	GameId    string        `bson:"game_id" json:"game_id"`
+	Sequence  int           `bson:"sequence" json:"sequence"`
+	Type      GameEventType `bson:"type" json:"type"`
+	Game      *Game         `bson:"game,omitempty" json:"game,omitempty"`
+	Move      *Move         `bson:"move,omitempty" json:"move,omitempty"`
+	Turn      string        `bson:"turn,omitempty" json:"turn,omitempty"`
+	Status    GameStatus    `bson:"status,omitempty" json:"status,omitempty"`
+	EndReason GameEndReason `bson:"end_reason,omitempty" json:"end_reason,omitempty"`
+	Winner    string        `bson:"winner,omitempty" json:"winner,omitempty"`
+	Timestamp time.Time     `bson:"timestamp" json:"timestamp"`
+
+	RatingChanges []*ratings.RatingChange `bson:"rating_changes,omitempty" json:"rating_changes,omitempty"`
+}
+
+// finished game moved out of the games collection, keeps the fields used by the game history
+type ArchivedGame struct {
+	GameId      string         `bson:"_id"`
+	GameStatus  GameStatus     `bson:"status"`
+	Variant     GameVariant    `bson:"variant"`
+	EndReason   GameEndReason  `bson:"end_reason,omitempty"`
+	Winner      string         `bson:"winner,omitempty"`
+	Player1     *PlayerSummary `bson:"player_1"`
+	Player2     *PlayerSummary `bson:"player_2"`
+	MovesCount  int            `bson:"moves_count"`
+	CreatedAt   time.Time      `bson:"created_at"`
+	CompletedAt time.Time      `bson:"completed_at,omitempty"`
+	ArchivedAt  time.Time      `bson:"archived_at"`
+	Record      []byte         `bson:"record"` // gzip compressed GameRecord
+}
+
+/*
+the game in notation form:
+  - pawn moves are written as the target cell, e.g. "e2" for {X: 4, Y: 1}
+  - walls are written as both wall positions and the direction, e.g. "c3c4h"
+
+players move in turns, so the first player makes every even move
+*/
+type GameRecord struct {
+	Game     *Game    `json:"game"`      // the game without walls and moves
+	Moves    []string `json:"moves"`     // moves in notation
+	MoveTime []int64  `json:"move_time"` // milliseconds since the start of the game for each move
+}
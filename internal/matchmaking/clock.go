@@ -0,0 +1,18 @@
+package matchmaking
+
+import "time"
+
+// source of the current time, so waiting times can be controlled in tests
+type Clock interface {
+	Now() time.Time
+}
+
+type SystemClock struct{}
+
+func NewSystemClock() *SystemClock {
+	return &SystemClock{}
+}
+
+func (c *SystemClock) Now() time.Time {
+	return time.Now()
+}
@@ -0,0 +1,192 @@
+package matchmaking
+
+import (
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	MATCHMAKING_LOCK = "matchmaking"
+	// the lock is renewed on every matchmaking tick, so it outlives a few of them before another instance takes over
+	MATCHMAKING_LOCK_TTL = 5 * time.Second
+)
+
+/*
+keeps the queue in a store shared by all instances of the server.
+only the instance holding the matchmaking lock forms matches, and every match claims both requests in the store,
+so a user is never matched twice even if two instances believe they hold the lock.
+errors of the store are logged and treated as an empty queue
+*/
+type DistributedMatchmakingQueue struct {
+	instanceId string
+	store      MatchmakingStore
+	scorer     MatchScorer
+	clock      Clock
+}
+
+func NewDistributedMatchmakingQueue(instanceId string, store MatchmakingStore, scorer MatchScorer, clock Clock) *DistributedMatchmakingQueue {
+	return &DistributedMatchmakingQueue{
+		instanceId: instanceId,
+		store:      store,
+		scorer:     scorer,
+		clock:      clock,
+	}
+}
+
+// same as in the in memory queue, a waiting user keeps the place unless they move to another pool
+func (mq *DistributedMatchmakingQueue) AddUserToQueue(request *MatchRequest) {
+	request.JoinTime = mq.clock.Now()
+
+	existing, err := mq.store.GetRequest(request.UserId)
+	if err == nil && existing != nil && existing.Settings == request.Settings {
+		request.JoinTime = existing.JoinTime
+	}
+
+	if err := mq.store.SaveRequest(request); err != nil {
+		log.Printf("Failed to add user to the queue: %v", err)
+	}
+}
+
+func (mq *DistributedMatchmakingQueue) RemoveUserFromQueue(userId string) {
+	if err := mq.store.DeleteRequest(userId); err != nil {
+		log.Printf("Failed to remove user from the queue: %v", err)
+	}
+}
+
+// every instance may call it, a request is returned only by the instance that claimed it
+func (mq *DistributedMatchmakingQueue) RemoveUsersWaitingLongerThan(maxWait time.Duration) []*MatchRequest {
+	removed := []*MatchRequest{}
+
+	requests, err := mq.store.GetRequests()
+	if err != nil {
+		return removed
+	}
+
+	now := mq.clock.Now()
+	for _, request := range requests {
+		if now.Sub(request.JoinTime) <= maxWait {
+			continue
+		}
+
+		if claimed, err := mq.store.ClaimRequests(uuid.NewString(), request.UserId); err == nil && claimed {
+			removed = append(removed, request)
+		}
+	}
+
+	return removed
+}
+
+// returns nil when the user is not in the queue
+func (mq *DistributedMatchmakingQueue) GetQueueStatus(userId string) *QueueStatus {
+	request, err := mq.store.GetRequest(userId)
+	if err != nil || request == nil {
+		return nil
+	}
+
+	requests, err := mq.store.GetRequests()
+	if err != nil {
+		return nil
+	}
+
+	waitTimes, err := mq.store.GetWaitTimes()
+	if err != nil {
+		return nil
+	}
+
+	return newQueueStatus(request, poolSizes(requests), waitTimes, mq.clock.Now())
+}
+
+func (mq *DistributedMatchmakingQueue) GetQueueStatuses() []*QueueStatus {
+	statuses := []*QueueStatus{}
+
+	requests, err := mq.store.GetRequests()
+	if err != nil {
+		return statuses
+	}
+
+	waitTimes, err := mq.store.GetWaitTimes()
+	if err != nil {
+		return statuses
+	}
+
+	now := mq.clock.Now()
+	sizes := poolSizes(requests)
+	for _, request := range requests {
+		statuses = append(statuses, newQueueStatus(request, sizes, waitTimes, now))
+	}
+
+	return statuses
+}
+
+// returns no matches on the instances that don't hold the matchmaking lock
+func (mq *DistributedMatchmakingQueue) FindMatches() []*Match {
+	matches := []*Match{}
+
+	now := mq.clock.Now()
+	acquired, err := mq.store.AcquireLock(MATCHMAKING_LOCK, mq.instanceId, now, MATCHMAKING_LOCK_TTL)
+	if err != nil || !acquired {
+		return matches
+	}
+
+	requests, err := mq.store.GetRequests()
+	if err != nil || len(requests) < 2 {
+		return matches
+	}
+
+	opponents, err := mq.recentOpponents(now)
+	if err != nil {
+		return matches
+	}
+
+	waitTimes, err := mq.store.GetWaitTimes()
+	if err != nil {
+		return matches
+	}
+
+	for settings, pool := range groupByPool(requests) {
+		joinTimes := map[string]time.Time{}
+		for _, request := range pool {
+			joinTimes[request.UserId] = request.JoinTime
+		}
+
+		poolMatches := matchPool(settings, pool, now, mq.scorer, opponents)
+		for _, match := range poolMatches {
+			// the request may have been cancelled or claimed by another instance since it was loaded
+			claimed, err := mq.store.ClaimRequests(uuid.NewString(), match.User1Id, match.User2Id)
+			if err != nil || !claimed {
+				continue
+			}
+
+			if err := mq.store.RememberOpponents(match.User1Id, match.User2Id, now, RECENT_OPPONENT_COOLDOWN); err != nil {
+				log.Printf("Failed to remember opponents: %v", err)
+			}
+			waitTimes[settings] = averageWaitTime(waitTimes, settings, now.Sub(joinTimes[match.User1Id]))
+			waitTimes[settings] = averageWaitTime(waitTimes, settings, now.Sub(joinTimes[match.User2Id]))
+
+			matches = append(matches, match)
+		}
+
+		if average, ok := waitTimes[settings]; ok && len(poolMatches) > 0 {
+			if err := mq.store.SaveWaitTime(settings, average); err != nil {
+				log.Printf("Failed to save wait time: %v", err)
+			}
+		}
+	}
+
+	return matches
+}
+
+func (mq *DistributedMatchmakingQueue) recentOpponents(now time.Time) (*RecentOpponents, error) {
+	pairs, err := mq.store.GetRecentOpponents(now)
+	if err != nil {
+		return nil, err
+	}
+
+	opponents := NewRecentOpponents(RECENT_OPPONENT_COOLDOWN)
+	for pair, matchedAt := range pairs {
+		opponents.Remember(pair[0], pair[1], matchedAt)
+	}
+	return opponents, nil
+}
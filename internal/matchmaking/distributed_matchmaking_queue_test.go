@@ -0,0 +1,380 @@
+package matchmaking
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"quoridor/internal/game"
+
+	"github.com/stretchr/testify/assert"
+)
+
+/*
+store shared by the queues of several instances, every operation is atomic like in mongo.
+claims take the same steps as in mongo, each of them is atomic on its own
+*/
+type FakeMatchmakingStore struct {
+	mu        sync.Mutex
+	requests  map[string]*MatchRequest
+	claims    map[string]string // claim ids of the claimed requests by user id
+	lockOwner string
+	lockUntil time.Time
+	opponents map[[2]string]time.Time
+	waitTimes map[game.GameSettings]time.Duration
+
+	// every instance gets the lock, as if the lock expired while the owner was still matching
+	splitBrain bool
+	// called after the requests of a claim are marked and before they are deleted
+	onClaimed func()
+}
+
+func NewFakeMatchmakingStore() *FakeMatchmakingStore {
+	return &FakeMatchmakingStore{
+		requests:  make(map[string]*MatchRequest),
+		claims:    make(map[string]string),
+		opponents: make(map[[2]string]time.Time),
+		waitTimes: make(map[game.GameSettings]time.Duration),
+	}
+}
+
+func (s *FakeMatchmakingStore) SaveRequest(request *MatchRequest) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, claimed := s.claims[request.UserId]; claimed {
+		return fmt.Errorf("match request of user %v is claimed", request.UserId)
+	}
+	saved := *request
+	s.requests[request.UserId] = &saved
+	return nil
+}
+
+func (s *FakeMatchmakingStore) DeleteRequest(userId string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.requests, userId)
+	delete(s.claims, userId)
+	return nil
+}
+
+func (s *FakeMatchmakingStore) GetRequest(userId string) (*MatchRequest, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	request, exists := s.requests[userId]
+	if _, claimed := s.claims[userId]; !exists || claimed {
+		return nil, nil
+	}
+	loaded := *request
+	return &loaded, nil
+}
+
+func (s *FakeMatchmakingStore) GetRequests() ([]*MatchRequest, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	requests := []*MatchRequest{}
+	for userId, request := range s.requests {
+		if _, claimed := s.claims[userId]; claimed {
+			continue
+		}
+		loaded := *request
+		requests = append(requests, &loaded)
+	}
+	return requests, nil
+}
+
+func (s *FakeMatchmakingStore) ClaimRequests(claimId string, userIds ...string) (bool, error) {
+	for _, userId := range userIds {
+		if !s.markRequest(claimId, userId) {
+			s.releaseRequests(claimId)
+			return false, nil
+		}
+	}
+
+	if s.onClaimed != nil {
+		s.onClaimed()
+	}
+
+	deleted := []*MatchRequest{}
+	for _, userId := range userIds {
+		request := s.deleteClaimedRequest(claimId, userId)
+		if request == nil {
+			s.restoreRequests(deleted)
+			s.releaseRequests(claimId)
+			return false, nil
+		}
+		deleted = append(deleted, request)
+	}
+	return true, nil
+}
+
+func (s *FakeMatchmakingStore) markRequest(claimId, userId string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, exists := s.requests[userId]
+	if _, claimed := s.claims[userId]; !exists || claimed {
+		return false
+	}
+	s.claims[userId] = claimId
+	return true
+}
+
+func (s *FakeMatchmakingStore) deleteClaimedRequest(claimId, userId string) *MatchRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	request, exists := s.requests[userId]
+	if !exists || s.claims[userId] != claimId {
+		return nil
+	}
+	delete(s.requests, userId)
+	delete(s.claims, userId)
+	return request
+}
+
+func (s *FakeMatchmakingStore) restoreRequests(requests []*MatchRequest) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, request := range requests {
+		if _, exists := s.requests[request.UserId]; !exists {
+			s.requests[request.UserId] = request
+		}
+	}
+}
+
+func (s *FakeMatchmakingStore) releaseRequests(claimId string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for userId, claim := range s.claims {
+		if claim == claimId {
+			delete(s.claims, userId)
+		}
+	}
+}
+
+func (s *FakeMatchmakingStore) AcquireLock(name, owner string, now time.Time, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.splitBrain && s.lockOwner != owner && now.Before(s.lockUntil) {
+		return false, nil
+	}
+
+	s.lockOwner = owner
+	s.lockUntil = now.Add(ttl)
+	return true, nil
+}
+
+func (s *FakeMatchmakingStore) RememberOpponents(user1Id, user2Id string, now time.Time, cooldown time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.opponents[opponentsPair(user1Id, user2Id)] = now
+	return nil
+}
+
+func (s *FakeMatchmakingStore) GetRecentOpponents(now time.Time) (map[[2]string]time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	opponents := map[[2]string]time.Time{}
+	for pair, matchedAt := range s.opponents {
+		if now.Sub(matchedAt) < RECENT_OPPONENT_COOLDOWN {
+			opponents[pair] = matchedAt
+		}
+	}
+	return opponents, nil
+}
+
+func (s *FakeMatchmakingStore) GetWaitTimes() (map[game.GameSettings]time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	waitTimes := map[game.GameSettings]time.Duration{}
+	for settings, average := range s.waitTimes {
+		waitTimes[settings] = average
+	}
+	return waitTimes, nil
+}
+
+func (s *FakeMatchmakingStore) SaveWaitTime(settings game.GameSettings, average time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.waitTimes[settings] = average
+	return nil
+}
+
+// every instance adds its own users and looks for matches at the same time
+func findMatchesOnInstances(store *FakeMatchmakingStore, instances, usersPerInstance int) []*Match {
+	clock := NewFakeClock()
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	matches := []*Match{}
+
+	for i := 0; i < instances; i++ {
+		mq := NewDistributedMatchmakingQueue(fmt.Sprintf("instance%d", i), store, NewDefaultRatingWindowScorer(), clock)
+
+		wg.Add(1)
+		go func(instance int) {
+			defer wg.Done()
+
+			for j := 0; j < usersPerInstance; j++ {
+				mq.AddUserToQueue(&MatchRequest{UserId: fmt.Sprintf("user%d-%d", instance, j), Rating: 1500})
+
+				found := mq.FindMatches()
+				mu.Lock()
+				matches = append(matches, found...)
+				mu.Unlock()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	return matches
+}
+
+func assertNoUserMatchedTwice(t *testing.T, matches []*Match) {
+	matched := map[string]bool{}
+	for _, match := range matches {
+		assert.NotEqual(t, match.User1Id, match.User2Id)
+		assert.False(t, matched[match.User1Id], "%s matched twice", match.User1Id)
+		assert.False(t, matched[match.User2Id], "%s matched twice", match.User2Id)
+		matched[match.User1Id] = true
+		matched[match.User2Id] = true
+	}
+}
+
+func TestDistributedFindMatches_givenSeveralInstances_shouldMatchEveryUserOnce(t *testing.T) {
+	store := NewFakeMatchmakingStore()
+
+	matches := findMatchesOnInstances(store, 4, 25)
+
+	assert.NotEmpty(t, matches)
+	assertNoUserMatchedTwice(t, matches)
+}
+
+func TestDistributedFindMatches_givenEveryInstanceHoldsLock_shouldMatchEveryUserOnce(t *testing.T) {
+	store := NewFakeMatchmakingStore()
+	store.splitBrain = true
+
+	matches := findMatchesOnInstances(store, 4, 25)
+
+	assert.NotEmpty(t, matches)
+	assertNoUserMatchedTwice(t, matches)
+}
+
+func TestDistributedFindMatches_givenLockHeldByAnotherInstance_shouldReturnNoMatches(t *testing.T) {
+	store := NewFakeMatchmakingStore()
+	clock := NewFakeClock()
+	leader := NewDistributedMatchmakingQueue("instance1", store, NewDefaultRatingWindowScorer(), clock)
+	follower := NewDistributedMatchmakingQueue("instance2", store, NewDefaultRatingWindowScorer(), clock)
+
+	leader.FindMatches()
+	follower.AddUserToQueue(&MatchRequest{UserId: "user1", Rating: 1500})
+	follower.AddUserToQueue(&MatchRequest{UserId: "user2", Rating: 1500})
+
+	assert.Empty(t, follower.FindMatches())
+
+	matches := leader.FindMatches()
+	assert.Len(t, matches, 1)
+	assert.Nil(t, follower.GetQueueStatus("user1"))
+}
+
+func TestDistributedFindMatches_givenLockExpired_shouldLetAnotherInstanceMatch(t *testing.T) {
+	store := NewFakeMatchmakingStore()
+	clock := NewFakeClock()
+	leader := NewDistributedMatchmakingQueue("instance1", store, NewDefaultRatingWindowScorer(), clock)
+	follower := NewDistributedMatchmakingQueue("instance2", store, NewDefaultRatingWindowScorer(), clock)
+
+	leader.FindMatches()
+	follower.AddUserToQueue(&MatchRequest{UserId: "user1", Rating: 1500})
+	follower.AddUserToQueue(&MatchRequest{UserId: "user2", Rating: 1500})
+	clock.Advance(MATCHMAKING_LOCK_TTL)
+
+	matches := follower.FindMatches()
+
+	assert.Len(t, matches, 1)
+}
+
+func TestDistributedFindMatches_givenRecentOpponents_shouldNotMatchAgain(t *testing.T) {
+	store := NewFakeMatchmakingStore()
+	clock := NewFakeClock()
+	mq := NewDistributedMatchmakingQueue("instance1", store, NewDefaultRatingWindowScorer(), clock)
+
+	mq.AddUserToQueue(&MatchRequest{UserId: "user1", Rating: 1500})
+	mq.AddUserToQueue(&MatchRequest{UserId: "user2", Rating: 1500})
+	assert.Len(t, mq.FindMatches(), 1)
+
+	mq.AddUserToQueue(&MatchRequest{UserId: "user1", Rating: 1500})
+	mq.AddUserToQueue(&MatchRequest{UserId: "user2", Rating: 1500})
+
+	assert.Empty(t, mq.FindMatches())
+	assert.NotNil(t, mq.GetQueueStatus("user1").EstimatedWait)
+}
+
+func TestDistributedFindMatches_givenRequestSavedDuringClaim_shouldKeepClaim(t *testing.T) {
+	store := NewFakeMatchmakingStore()
+	clock := NewFakeClock()
+	mq1 := NewDistributedMatchmakingQueue("instance1", store, NewDefaultRatingWindowScorer(), clock)
+	mq2 := NewDistributedMatchmakingQueue("instance2", store, NewDefaultRatingWindowScorer(), clock)
+
+	mq1.AddUserToQueue(&MatchRequest{UserId: "user1", Rating: 1500})
+	mq1.AddUserToQueue(&MatchRequest{UserId: "user2", Rating: 1500})
+	store.onClaimed = func() {
+		store.onClaimed = nil
+		mq2.AddUserToQueue(&MatchRequest{UserId: "user1", Rating: 1500})
+	}
+
+	matches := mq1.FindMatches()
+
+	assert.Len(t, matches, 1)
+	assert.Nil(t, mq2.GetQueueStatus("user1"))
+	assert.Nil(t, mq2.GetQueueStatus("user2"))
+	assert.Empty(t, store.requests)
+}
+
+func TestDistributedFindMatches_givenUserLeftDuringClaim_shouldKeepOpponentInQueue(t *testing.T) {
+	store := NewFakeMatchmakingStore()
+	clock := NewFakeClock()
+	mq1 := NewDistributedMatchmakingQueue("instance1", store, NewDefaultRatingWindowScorer(), clock)
+	mq2 := NewDistributedMatchmakingQueue("instance2", store, NewDefaultRatingWindowScorer(), clock)
+
+	mq1.AddUserToQueue(&MatchRequest{UserId: "user1", Rating: 1500})
+	mq1.AddUserToQueue(&MatchRequest{UserId: "user2", Rating: 1500})
+	store.onClaimed = func() {
+		store.onClaimed = nil
+		mq2.RemoveUserFromQueue("user2")
+	}
+
+	matches := mq1.FindMatches()
+
+	assert.Empty(t, matches)
+	assert.NotNil(t, mq2.GetQueueStatus("user1"))
+	assert.Nil(t, mq2.GetQueueStatus("user2"))
+	assert.Empty(t, store.claims)
+}
+
+func TestDistributedRemoveUsersWaitingLongerThan_givenSeveralInstances_shouldReturnEveryUserOnce(t *testing.T) {
+	store := NewFakeMatchmakingStore()
+	clock := NewFakeClock()
+	mq1 := NewDistributedMatchmakingQueue("instance1", store, NewDefaultRatingWindowScorer(), clock)
+	mq2 := NewDistributedMatchmakingQueue("instance2", store, NewDefaultRatingWindowScorer(), clock)
+
+	mq1.AddUserToQueue(&MatchRequest{UserId: "user1", Rating: 1500})
+	mq2.AddUserToQueue(&MatchRequest{UserId: "user2", Rating: 1500, Settings: game.GameSettings{Ranked: true}})
+	clock.Advance(time.Minute)
+
+	removed := append(mq1.RemoveUsersWaitingLongerThan(30*time.Second), mq2.RemoveUsersWaitingLongerThan(30*time.Second)...)
+
+	assert.Len(t, removed, 2)
+	assert.Empty(t, mq1.GetQueueStatuses())
+}
@@ -0,0 +1,56 @@
+package matchmaking
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	DEFAULT_INITIAL_RATING_WINDOW = 100.0
+	DEFAULT_RATING_WINDOW_GROWTH  = 10.0 // rating points per second of waiting
+	DEFAULT_MAX_RATING_WINDOW     = 1000.0
+)
+
+type MatchScorer interface {
+	// returns the score of the pair, lower is better, and whether the pair can be matched at all
+	Score(req1, req2 *MatchRequest, now time.Time) (float64, bool)
+}
+
+/*
+pairs players whose rating difference fits into the search window.
+the window starts at initialWindow and widens by windowGrowth every second the player waits, up to maxWindow.
+the window of the player who waited longer is used, so long waiting players accept wider range of opponents
+*/
+type RatingWindowScorer struct {
+	initialWindow float64
+	windowGrowth  float64
+	maxWindow     float64
+}
+
+func NewRatingWindowScorer(initialWindow, windowGrowth, maxWindow float64) *RatingWindowScorer {
+	return &RatingWindowScorer{
+		initialWindow: initialWindow,
+		windowGrowth:  windowGrowth,
+		maxWindow:     maxWindow,
+	}
+}
+
+func NewDefaultRatingWindowScorer() *RatingWindowScorer {
+	return NewRatingWindowScorer(DEFAULT_INITIAL_RATING_WINDOW, DEFAULT_RATING_WINDOW_GROWTH, DEFAULT_MAX_RATING_WINDOW)
+}
+
+// the score is the rating difference relative to the window, from 0 for equal ratings to 1 at the edge of the window
+func (scorer *RatingWindowScorer) Score(req1, req2 *MatchRequest, now time.Time) (float64, bool) {
+	window := math.Max(scorer.Window(req1, now), scorer.Window(req2, now))
+	ratingDiff := math.Abs(req1.Rating - req2.Rating)
+
+	if ratingDiff > window {
+		return 0, false
+	}
+	return ratingDiff / window, true
+}
+
+func (scorer *RatingWindowScorer) Window(req *MatchRequest, now time.Time) float64 {
+	waited := now.Sub(req.JoinTime).Seconds()
+	return math.Min(scorer.initialWindow+scorer.windowGrowth*waited, scorer.maxWindow)
+}
@@ -0,0 +1,48 @@
+package matchmaking
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRatingWindowScorer_Window(t *testing.T) {
+	scorer := NewRatingWindowScorer(100, 10, 300)
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, 100.0, scorer.Window(&MatchRequest{JoinTime: now}, now))
+	assert.Equal(t, 150.0, scorer.Window(&MatchRequest{JoinTime: now.Add(-5 * time.Second)}, now))
+	assert.Equal(t, 300.0, scorer.Window(&MatchRequest{JoinTime: now.Add(-time.Minute)}, now))
+}
+
+func TestRatingWindowScorer_Score(t *testing.T) {
+	scorer := NewRatingWindowScorer(100, 10, 1000)
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	req1 := &MatchRequest{UserId: "user1", Rating: 1500, JoinTime: now}
+	req2 := &MatchRequest{UserId: "user2", Rating: 1550, JoinTime: now}
+
+	score, ok := scorer.Score(req1, req2, now)
+	assert.True(t, ok)
+	assert.Equal(t, 0.5, score)
+
+	score, ok = scorer.Score(req1, &MatchRequest{UserId: "user3", Rating: 1500, JoinTime: now}, now)
+	assert.True(t, ok)
+	assert.Equal(t, 0.0, score)
+}
+
+func TestRatingWindowScorer_Score_shouldUseWindowOfLongerWaitingPlayer(t *testing.T) {
+	scorer := NewRatingWindowScorer(100, 10, 1000)
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	waiting := &MatchRequest{UserId: "user1", Rating: 1500, JoinTime: now.Add(-20 * time.Second)}
+	joined := &MatchRequest{UserId: "user2", Rating: 1800, JoinTime: now}
+
+	score, ok := scorer.Score(waiting, joined, now)
+	assert.True(t, ok)
+	assert.Equal(t, 1.0, score)
+
+	_, ok = scorer.Score(joined, &MatchRequest{UserId: "user3", Rating: 1500, JoinTime: now}, now)
+	assert.False(t, ok)
+}
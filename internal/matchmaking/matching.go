@@ -0,0 +1,131 @@
+package matchmaking
+
+import (
+	"math"
+	"quoridor/internal/game"
+	"slices"
+	"strings"
+	"time"
+)
+
+// weight of the latest wait time in the average wait time of the pool
+const WAIT_TIME_SMOOTHING = 0.2
+
+// matching rules shared by the queue implementations, the queues only differ in where the requests are kept
+
+func groupByPool(requests []*MatchRequest) map[game.GameSettings][]*MatchRequest {
+	pools := map[game.GameSettings][]*MatchRequest{}
+	for _, request := range requests {
+		pools[request.Settings] = append(pools[request.Settings], request)
+	}
+	return pools
+}
+
+// pairs the users of one pool, the pool is reordered in place
+func matchPool(settings game.GameSettings, queue []*MatchRequest, now time.Time, scorer MatchScorer, opponents *RecentOpponents) []*Match {
+	matches := []*Match{}
+
+	// users who wait longer choose first, users who joined at the same time are ordered by id
+	slices.SortFunc(queue, func(req1, req2 *MatchRequest) int {
+		if order := req1.JoinTime.Compare(req2.JoinTime); order != 0 {
+			return order
+		}
+		return strings.Compare(req1.UserId, req2.UserId)
+	})
+
+	for i := 0; i < len(queue); i++ {
+		req1 := queue[i]
+		bestMatch := -1
+		bestScore := math.MaxFloat64
+
+		for j := i + 1; j < len(queue); j++ {
+			req2 := queue[j]
+			if !canBeMatched(req1, req2, len(queue), now, opponents) {
+				continue
+			}
+
+			// a mutual rematch goes before any other match
+			score, ok := -1.0, true
+			if !isRematch(req1, req2) {
+				score, ok = scorer.Score(req1, req2, now)
+			}
+			if ok && score < bestScore {
+				bestMatch = j
+				bestScore = score
+			}
+		}
+
+		if bestMatch != -1 {
+			match := &Match{
+				User1Id:  req1.UserId,
+				User2Id:  queue[bestMatch].UserId,
+				Settings: settings,
+			}
+			matches = append(matches, match)
+			queue = append(queue[:i], queue[i+1:]...)
+			if bestMatch > i {
+				bestMatch--
+			}
+			queue = append(queue[:bestMatch], queue[bestMatch+1:]...)
+			i--
+		}
+	}
+
+	return matches
+}
+
+/*
+blocked users are never matched.
+recent opponents are matched only on a mutual rematch, or when nobody else is left in the pool and both of them waited long enough
+*/
+func canBeMatched(req1, req2 *MatchRequest, poolSize int, now time.Time, opponents *RecentOpponents) bool {
+	if slices.Contains(req1.BlockedUserIds, req2.UserId) || slices.Contains(req2.BlockedUserIds, req1.UserId) {
+		return false
+	}
+
+	if !opponents.PlayedRecently(req1.UserId, req2.UserId, now) || isRematch(req1, req2) {
+		return true
+	}
+
+	waited := min(now.Sub(req1.JoinTime), now.Sub(req2.JoinTime))
+	return poolSize == 2 && waited >= RECENT_OPPONENT_FALLBACK_AFTER
+}
+
+func isRematch(req1, req2 *MatchRequest) bool {
+	return req1.RematchUserId == req2.UserId && req2.RematchUserId == req1.UserId
+}
+
+func newQueueStatus(request *MatchRequest, poolSizes map[game.GameSettings]int, waitTimes map[game.GameSettings]time.Duration, now time.Time) *QueueStatus {
+	status := &QueueStatus{
+		UserId:        request.UserId,
+		Settings:      request.Settings,
+		JoinTime:      request.JoinTime,
+		Waited:        now.Sub(request.JoinTime),
+		PlayersInPool: poolSizes[request.Settings],
+	}
+
+	if average, ok := waitTimes[request.Settings]; ok {
+		estimate := max(average-status.Waited, 0)
+		status.EstimatedWait = &estimate
+	}
+
+	return status
+}
+
+func poolSizes(requests []*MatchRequest) map[game.GameSettings]int {
+	sizes := map[game.GameSettings]int{}
+	for _, request := range requests {
+		sizes[request.Settings]++
+	}
+	return sizes
+}
+
+// moving average of the wait times of the matched users in the pool
+func averageWaitTime(waitTimes map[game.GameSettings]time.Duration, settings game.GameSettings, waited time.Duration) time.Duration {
+	average, ok := waitTimes[settings]
+	if !ok {
+		return waited
+	}
+
+	return average + time.Duration(WAIT_TIME_SMOOTHING*float64(waited-average))
+}
@@ -1,40 +1,53 @@
 package matchmaking
 
 import (
-	"math"
-	"quoridor/internal/utils"
-	"slices"
+	"quoridor/internal/game"
 	"sync"
 	"time"
 )
 
 type MatchmakingQueue interface {
 	FindMatches() []*Match
-	AddUserToQueue(userId string)
+	AddUserToQueue(request *MatchRequest)
 	RemoveUserFromQueue(userId string)
+	GetQueueStatus(userId string) *QueueStatus
+	GetQueueStatuses() []*QueueStatus
+	RemoveUsersWaitingLongerThan(maxWait time.Duration) []*MatchRequest
 }
 
 type InMemoryMatchmakingQueue struct {
-	mu    sync.Mutex
-	queue map[string]*MatchRequest
+	mu     sync.Mutex
+	queue  map[string]*MatchRequest
+	scorer MatchScorer
+	clock  Clock
+
+	waitTimes map[game.GameSettings]time.Duration // average wait time of the matched users per pool
+	opponents *RecentOpponents
 }
 
-func NewInMemoryMatchmakingQueue() *InMemoryMatchmakingQueue {
+func NewInMemoryMatchmakingQueue(scorer MatchScorer, clock Clock) *InMemoryMatchmakingQueue {
 	return &InMemoryMatchmakingQueue{
-		queue: make(map[string]*MatchRequest),
+		queue:     make(map[string]*MatchRequest),
+		scorer:    scorer,
+		clock:     clock,
+		waitTimes: make(map[game.GameSettings]time.Duration),
+		opponents: NewRecentOpponents(RECENT_OPPONENT_COOLDOWN),
 	}
 }
 
-func (mq *InMemoryMatchmakingQueue) AddUserToQueue(userId string) {
+/*
+the join time of the request is set by the queue.
+a user who is already waiting keeps the place, unless they choose different settings and so move to another pool
+*/
+func (mq *InMemoryMatchmakingQueue) AddUserToQueue(request *MatchRequest) {
 	mq.mu.Lock()
 	defer mq.mu.Unlock()
 
-	if _, exists := mq.queue[userId]; !exists {
-		mq.queue[userId] = &MatchRequest{
-			UserId:   userId,
-			JoinTime: time.Now(),
-		}
+	request.JoinTime = mq.clock.Now()
+	if existing, exists := mq.queue[request.UserId]; exists && existing.Settings == request.Settings {
+		request.JoinTime = existing.JoinTime
 	}
+	mq.queue[request.UserId] = request
 }
 
 func (mq *InMemoryMatchmakingQueue) RemoveUserFromQueue(userId string) {
@@ -44,6 +57,53 @@ func (mq *InMemoryMatchmakingQueue) RemoveUserFromQueue(userId string) {
 	delete(mq.queue, userId)
 }
 
+// removes and returns the requests of the users who waited longer than maxWait without being matched
+func (mq *InMemoryMatchmakingQueue) RemoveUsersWaitingLongerThan(maxWait time.Duration) []*MatchRequest {
+	mq.mu.Lock()
+	defer mq.mu.Unlock()
+
+	now := mq.clock.Now()
+	removed := []*MatchRequest{}
+	for userId, request := range mq.queue {
+		if now.Sub(request.JoinTime) > maxWait {
+			removed = append(removed, request)
+			delete(mq.queue, userId)
+		}
+	}
+
+	return removed
+}
+
+// returns nil when the user is not in the queue
+func (mq *InMemoryMatchmakingQueue) GetQueueStatus(userId string) *QueueStatus {
+	mq.mu.Lock()
+	defer mq.mu.Unlock()
+
+	request, exists := mq.queue[userId]
+	if !exists {
+		return nil
+	}
+
+	return newQueueStatus(request, poolSizes(mq.requests()), mq.waitTimes, mq.clock.Now())
+}
+
+func (mq *InMemoryMatchmakingQueue) GetQueueStatuses() []*QueueStatus {
+	mq.mu.Lock()
+	defer mq.mu.Unlock()
+
+	now := mq.clock.Now()
+	requests := mq.requests()
+	sizes := poolSizes(requests)
+
+	statuses := make([]*QueueStatus, 0, len(requests))
+	for _, request := range requests {
+		statuses = append(statuses, newQueueStatus(request, sizes, mq.waitTimes, now))
+	}
+
+	return statuses
+}
+
+// users are matched only with users from the same pool, i.e. with the same game settings
 func (mq *InMemoryMatchmakingQueue) FindMatches() []*Match {
 	mq.mu.Lock()
 	defer mq.mu.Unlock()
@@ -53,50 +113,27 @@ func (mq *InMemoryMatchmakingQueue) FindMatches() []*Match {
 		return matches
 	}
 
-	queue := utils.MapToValuesSlice(mq.queue)
-	slices.SortFunc(queue, func(req1, req2 *MatchRequest) int {
-		if req1.JoinTime.Before(req2.JoinTime) {
-			return -1
-		}
-		return 1
-	})
-
-	for i := 0; i < len(queue); i++ {
-		req1 := queue[i]
-		bestMatch := -1
-		bestScore := math.MaxFloat64
-
-		for j := i + 1; j < len(queue); j++ {
-			req2 := queue[j]
-			score := mq.calculateScore(req1, req2)
-			if score < bestScore {
-				bestMatch = j
-				bestScore = score
-			}
-		}
+	now := mq.clock.Now()
+	for settings, pool := range groupByPool(mq.requests()) {
+		for _, match := range matchPool(settings, pool, now, mq.scorer, mq.opponents) {
+			user1, user2 := mq.queue[match.User1Id], mq.queue[match.User2Id]
+			mq.opponents.Remember(match.User1Id, match.User2Id, now)
+			mq.waitTimes[settings] = averageWaitTime(mq.waitTimes, settings, now.Sub(user1.JoinTime))
+			mq.waitTimes[settings] = averageWaitTime(mq.waitTimes, settings, now.Sub(user2.JoinTime))
 
-		if bestMatch != -1 {
-			match := &Match{
-				User1Id: req1.UserId,
-				User2Id: queue[bestMatch].UserId,
-			}
-			matches = append(matches, match)
-			queue = append(queue[:i], queue[i+1:]...)
-			if bestMatch > i {
-				bestMatch--
-			}
-			queue = append(queue[:bestMatch], queue[bestMatch+1:]...)
-			i--
-			
 			delete(mq.queue, match.User1Id)
 			delete(mq.queue, match.User2Id)
+			matches = append(matches, match)
 		}
 	}
 
 	return matches
 }
 
-func (mq *InMemoryMatchmakingQueue) calculateScore(req1, req2 *MatchRequest) float64 {
-	waitTimeDiff := math.Abs(time.Since(req1.JoinTime).Seconds() - time.Since(req2.JoinTime).Seconds())
-	return waitTimeDiff
+func (mq *InMemoryMatchmakingQueue) requests() []*MatchRequest {
+	requests := make([]*MatchRequest, 0, len(mq.queue))
+	for _, request := range mq.queue {
+		requests = append(requests, request)
+	}
+	return requests
 }
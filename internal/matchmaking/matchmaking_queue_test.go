@@ -4,13 +4,32 @@ import (
 	"testing"
 	"time"
 
+	"quoridor/internal/game"
+
 	"github.com/stretchr/testify/assert"
 )
 
+type FakeClock struct {
+	now time.Time
+}
+
+func NewFakeClock() *FakeClock {
+	return &FakeClock{now: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)}
+}
+
+func (c *FakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *FakeClock) Advance(duration time.Duration) {
+	c.now = c.now.Add(duration)
+}
+
 func TestAddUserToQueue(t *testing.T) {
-	mq := NewInMemoryMatchmakingQueue()
+	clock := NewFakeClock()
+	mq := NewInMemoryMatchmakingQueue(NewDefaultRatingWindowScorer(), clock)
 
-	mq.AddUserToQueue("user1")
+	mq.AddUserToQueue(&MatchRequest{UserId: "user1", Rating: 1500})
 
 	mq.mu.Lock()
 	defer mq.mu.Unlock()
@@ -20,10 +39,11 @@ func TestAddUserToQueue(t *testing.T) {
 }
 
 func TestAddUserToQueue_UserAlreadyInQueue(t *testing.T) {
-	mq := NewInMemoryMatchmakingQueue()
+	clock := NewFakeClock()
+	mq := NewInMemoryMatchmakingQueue(NewDefaultRatingWindowScorer(), clock)
 
-	mq.AddUserToQueue("user1")
-	mq.AddUserToQueue("user1")
+	mq.AddUserToQueue(&MatchRequest{UserId: "user1", Rating: 1500})
+	mq.AddUserToQueue(&MatchRequest{UserId: "user1", Rating: 1500})
 
 	mq.mu.Lock()
 	defer mq.mu.Unlock()
@@ -33,10 +53,11 @@ func TestAddUserToQueue_UserAlreadyInQueue(t *testing.T) {
 }
 
 func TestRemoveUserFromQueue(t *testing.T) {
-	mq := NewInMemoryMatchmakingQueue()
+	clock := NewFakeClock()
+	mq := NewInMemoryMatchmakingQueue(NewDefaultRatingWindowScorer(), clock)
 
-	mq.AddUserToQueue("user1")
-	mq.AddUserToQueue("user2")
+	mq.AddUserToQueue(&MatchRequest{UserId: "user1", Rating: 1500})
+	mq.AddUserToQueue(&MatchRequest{UserId: "user2", Rating: 1500})
 
 	mq.RemoveUserFromQueue("user1")
 
@@ -49,12 +70,13 @@ func TestRemoveUserFromQueue(t *testing.T) {
 }
 
 func TestFindMatches(t *testing.T) {
-	mq := NewInMemoryMatchmakingQueue()
+	clock := NewFakeClock()
+	mq := NewInMemoryMatchmakingQueue(NewDefaultRatingWindowScorer(), clock)
 
-	mq.AddUserToQueue("user1")
-	time.Sleep(200 * time.Millisecond)
-	mq.AddUserToQueue("user2")
-	mq.AddUserToQueue("user3")
+	mq.AddUserToQueue(&MatchRequest{UserId: "user1", Rating: 1500})
+	clock.Advance(200 * time.Millisecond)
+	mq.AddUserToQueue(&MatchRequest{UserId: "user2", Rating: 1500})
+	mq.AddUserToQueue(&MatchRequest{UserId: "user3", Rating: 1500})
 
 	matches := mq.FindMatches()
 
@@ -71,9 +93,10 @@ func TestFindMatches(t *testing.T) {
 }
 
 func TestFindMatches_notEnoughUsers(t *testing.T) {
-	mq := NewInMemoryMatchmakingQueue()
+	clock := NewFakeClock()
+	mq := NewInMemoryMatchmakingQueue(NewDefaultRatingWindowScorer(), clock)
 
-	mq.AddUserToQueue("user1")
+	mq.AddUserToQueue(&MatchRequest{UserId: "user1", Rating: 1500})
 
 	matches := mq.FindMatches()
 
@@ -88,12 +111,13 @@ func TestFindMatches_notEnoughUsers(t *testing.T) {
 }
 
 func TestFindMatches_multipleMatches(t *testing.T) {
-	mq := NewInMemoryMatchmakingQueue()
+	clock := NewFakeClock()
+	mq := NewInMemoryMatchmakingQueue(NewDefaultRatingWindowScorer(), clock)
 
-	mq.AddUserToQueue("user1")
-	mq.AddUserToQueue("user2")
-	mq.AddUserToQueue("user3")
-	mq.AddUserToQueue("user4")
+	mq.AddUserToQueue(&MatchRequest{UserId: "user1", Rating: 1500})
+	mq.AddUserToQueue(&MatchRequest{UserId: "user2", Rating: 1500})
+	mq.AddUserToQueue(&MatchRequest{UserId: "user3", Rating: 1500})
+	mq.AddUserToQueue(&MatchRequest{UserId: "user4", Rating: 1500})
 
 	matches := mq.FindMatches()
 
@@ -107,4 +131,240 @@ func TestFindMatches_multipleMatches(t *testing.T) {
 	defer mq.mu.Unlock()
 
 	assert.Len(t, mq.queue, 0)
-}
\ No newline at end of file
+}
+func TestFindMatches_givenDistantRatings_shouldWaitUntilWindowWidens(t *testing.T) {
+	clock := NewFakeClock()
+	mq := NewInMemoryMatchmakingQueue(NewRatingWindowScorer(100, 10, 1000), clock)
+
+	mq.AddUserToQueue(&MatchRequest{UserId: "beginner", Rating: 1000})
+	mq.AddUserToQueue(&MatchRequest{UserId: "expert", Rating: 1500})
+
+	assert.Len(t, mq.FindMatches(), 0)
+
+	// the window is 100 + 10 * 39 = 490 points
+	clock.Advance(39 * time.Second)
+	assert.Len(t, mq.FindMatches(), 0)
+
+	clock.Advance(1 * time.Second)
+	matches := mq.FindMatches()
+
+	assert.Len(t, matches, 1)
+	assert.Equal(t, "beginner", matches[0].User1Id)
+	assert.Equal(t, "expert", matches[0].User2Id)
+}
+
+func TestFindMatches_shouldPreferCloserRating(t *testing.T) {
+	clock := NewFakeClock()
+	mq := NewInMemoryMatchmakingQueue(NewDefaultRatingWindowScorer(), clock)
+
+	mq.AddUserToQueue(&MatchRequest{UserId: "user1", Rating: 1500})
+	clock.Advance(time.Second)
+	mq.AddUserToQueue(&MatchRequest{UserId: "user2", Rating: 1580})
+	mq.AddUserToQueue(&MatchRequest{UserId: "user3", Rating: 1520})
+
+	matches := mq.FindMatches()
+
+	assert.Len(t, matches, 1)
+	assert.Equal(t, "user1", matches[0].User1Id)
+	assert.Equal(t, "user3", matches[0].User2Id)
+}
+
+func TestFindMatches_givenCustomScorer_shouldUseIt(t *testing.T) {
+	clock := NewFakeClock()
+	mq := NewInMemoryMatchmakingQueue(&rejectAllScorer{}, clock)
+
+	mq.AddUserToQueue(&MatchRequest{UserId: "user1", Rating: 1500})
+	mq.AddUserToQueue(&MatchRequest{UserId: "user2", Rating: 1500})
+
+	assert.Len(t, mq.FindMatches(), 0)
+}
+
+type rejectAllScorer struct{}
+
+func (s *rejectAllScorer) Score(req1, req2 *MatchRequest, now time.Time) (float64, bool) {
+	return 0, false
+}
+
+func TestFindMatches_givenDifferentSettings_shouldMatchOnlyWithinPool(t *testing.T) {
+	clock := NewFakeClock()
+	mq := NewInMemoryMatchmakingQueue(NewDefaultRatingWindowScorer(), clock)
+
+	ranked := game.GameSettings{Ranked: true, Variant: game.GameVariantStandard, TimeControl: game.TimeControlRapid}
+	casual := game.GameSettings{Ranked: false, Variant: game.GameVariantStandard, TimeControl: game.TimeControlRapid}
+	blitz := game.GameSettings{Ranked: true, Variant: game.GameVariantStandard, TimeControl: game.TimeControlBlitz}
+
+	mq.AddUserToQueue(&MatchRequest{UserId: "user1", Rating: 1500, Settings: ranked})
+	mq.AddUserToQueue(&MatchRequest{UserId: "user2", Rating: 1500, Settings: casual})
+	mq.AddUserToQueue(&MatchRequest{UserId: "user3", Rating: 1500, Settings: blitz})
+
+	assert.Len(t, mq.FindMatches(), 0)
+
+	mq.AddUserToQueue(&MatchRequest{UserId: "user4", Rating: 1500, Settings: casual})
+	matches := mq.FindMatches()
+
+	assert.Len(t, matches, 1)
+	assert.Equal(t, "user2", matches[0].User1Id)
+	assert.Equal(t, "user4", matches[0].User2Id)
+	assert.Equal(t, casual, matches[0].Settings)
+
+	mq.mu.Lock()
+	defer mq.mu.Unlock()
+
+	assert.Len(t, mq.queue, 2)
+}
+
+func TestAddUserToQueue_givenDifferentSettings_shouldMoveUserToAnotherPool(t *testing.T) {
+	clock := NewFakeClock()
+	mq := NewInMemoryMatchmakingQueue(NewDefaultRatingWindowScorer(), clock)
+
+	ranked := game.GameSettings{Ranked: true, Variant: game.GameVariantStandard, TimeControl: game.TimeControlRapid}
+	casual := game.GameSettings{Ranked: false, Variant: game.GameVariantStandard, TimeControl: game.TimeControlRapid}
+
+	mq.AddUserToQueue(&MatchRequest{UserId: "user1", Rating: 1500, Settings: ranked})
+	clock.Advance(time.Minute)
+	mq.AddUserToQueue(&MatchRequest{UserId: "user1", Rating: 1500, Settings: casual})
+
+	mq.mu.Lock()
+	defer mq.mu.Unlock()
+
+	assert.Len(t, mq.queue, 1)
+	assert.Equal(t, casual, mq.queue["user1"].Settings)
+	assert.Equal(t, clock.Now(), mq.queue["user1"].JoinTime)
+}
+
+func TestGetQueueStatus(t *testing.T) {
+	clock := NewFakeClock()
+	mq := NewInMemoryMatchmakingQueue(NewDefaultRatingWindowScorer(), clock)
+
+	casual := game.DefaultGameSettings()
+	ranked := game.GameSettings{Ranked: true, Variant: game.GameVariantStandard, TimeControl: game.TimeControlRapid}
+
+	mq.AddUserToQueue(&MatchRequest{UserId: "user1", Rating: 1500, Settings: casual})
+	clock.Advance(10 * time.Second)
+	mq.AddUserToQueue(&MatchRequest{UserId: "user2", Rating: 2500, Settings: casual})
+	mq.AddUserToQueue(&MatchRequest{UserId: "user3", Rating: 1500, Settings: ranked})
+
+	status := mq.GetQueueStatus("user1")
+
+	assert.Equal(t, "user1", status.UserId)
+	assert.Equal(t, casual, status.Settings)
+	assert.Equal(t, 10*time.Second, status.Waited)
+	assert.Equal(t, 2, status.PlayersInPool)
+	assert.Nil(t, status.EstimatedWait)
+
+	assert.Nil(t, mq.GetQueueStatus("user4"))
+	assert.Len(t, mq.GetQueueStatuses(), 3)
+}
+
+func TestGetQueueStatus_givenMatchesInPool_shouldEstimateWaitTime(t *testing.T) {
+	clock := NewFakeClock()
+	mq := NewInMemoryMatchmakingQueue(NewDefaultRatingWindowScorer(), clock)
+
+	mq.AddUserToQueue(&MatchRequest{UserId: "user1", Rating: 1500})
+	clock.Advance(30 * time.Second)
+	mq.AddUserToQueue(&MatchRequest{UserId: "user2", Rating: 1500})
+	clock.Advance(10 * time.Second)
+
+	// user1 waited 40 seconds and user2 waited 10 seconds
+	assert.Len(t, mq.FindMatches(), 1)
+
+	mq.AddUserToQueue(&MatchRequest{UserId: "user3", Rating: 1500})
+	clock.Advance(5 * time.Second)
+
+	status := mq.GetQueueStatus("user3")
+
+	assert.Equal(t, 1, status.PlayersInPool)
+	assert.NotNil(t, status.EstimatedWait)
+	assert.Equal(t, 29*time.Second, *status.EstimatedWait)
+
+	clock.Advance(time.Minute)
+	status = mq.GetQueueStatus("user3")
+
+	assert.Equal(t, time.Duration(0), *status.EstimatedWait)
+}
+
+func TestRemoveUsersWaitingLongerThan(t *testing.T) {
+	clock := NewFakeClock()
+	mq := NewInMemoryMatchmakingQueue(NewDefaultRatingWindowScorer(), clock)
+
+	mq.AddUserToQueue(&MatchRequest{UserId: "user1", Rating: 1500})
+	clock.Advance(90 * time.Second)
+	mq.AddUserToQueue(&MatchRequest{UserId: "user2", Rating: 1500})
+	clock.Advance(31 * time.Second)
+
+	removed := mq.RemoveUsersWaitingLongerThan(2 * time.Minute)
+
+	assert.Len(t, removed, 1)
+	assert.Equal(t, "user1", removed[0].UserId)
+	assert.Nil(t, mq.GetQueueStatus("user1"))
+	assert.NotNil(t, mq.GetQueueStatus("user2"))
+}
+
+func TestFindMatches_givenRecentOpponents_shouldNotMatchThemAgain(t *testing.T) {
+	clock := NewFakeClock()
+	mq := NewInMemoryMatchmakingQueue(NewDefaultRatingWindowScorer(), clock)
+
+	mq.AddUserToQueue(&MatchRequest{UserId: "user1", Rating: 1500})
+	mq.AddUserToQueue(&MatchRequest{UserId: "user2", Rating: 1500})
+	assert.Len(t, mq.FindMatches(), 1)
+
+	clock.Advance(5 * time.Minute)
+	mq.AddUserToQueue(&MatchRequest{UserId: "user1", Rating: 1500})
+	mq.AddUserToQueue(&MatchRequest{UserId: "user2", Rating: 1500})
+	mq.AddUserToQueue(&MatchRequest{UserId: "user3", Rating: 1600})
+
+	matches := mq.FindMatches()
+
+	assert.Len(t, matches, 1)
+	assert.Equal(t, "user1", matches[0].User1Id)
+	assert.Equal(t, "user3", matches[0].User2Id)
+}
+
+func TestFindMatches_givenOnlyRecentOpponentsInPool_shouldMatchThemAfterTimeout(t *testing.T) {
+	clock := NewFakeClock()
+	mq := NewInMemoryMatchmakingQueue(NewDefaultRatingWindowScorer(), clock)
+
+	mq.AddUserToQueue(&MatchRequest{UserId: "user1", Rating: 1500})
+	mq.AddUserToQueue(&MatchRequest{UserId: "user2", Rating: 1500})
+	assert.Len(t, mq.FindMatches(), 1)
+
+	clock.Advance(5 * time.Minute)
+	mq.AddUserToQueue(&MatchRequest{UserId: "user1", Rating: 1500})
+	mq.AddUserToQueue(&MatchRequest{UserId: "user2", Rating: 1500})
+	assert.Len(t, mq.FindMatches(), 0)
+
+	clock.Advance(RECENT_OPPONENT_FALLBACK_AFTER)
+	assert.Len(t, mq.FindMatches(), 1)
+}
+
+func TestFindMatches_givenMutualRematch_shouldMatchRecentOpponentsRightAway(t *testing.T) {
+	clock := NewFakeClock()
+	mq := NewInMemoryMatchmakingQueue(NewDefaultRatingWindowScorer(), clock)
+
+	mq.AddUserToQueue(&MatchRequest{UserId: "user1", Rating: 1500})
+	mq.AddUserToQueue(&MatchRequest{UserId: "user2", Rating: 1500})
+	assert.Len(t, mq.FindMatches(), 1)
+
+	mq.AddUserToQueue(&MatchRequest{UserId: "user1", Rating: 1500, RematchUserId: "user2"})
+	mq.AddUserToQueue(&MatchRequest{UserId: "user2", Rating: 1500})
+	assert.Len(t, mq.FindMatches(), 0)
+
+	mq.AddUserToQueue(&MatchRequest{UserId: "user2", Rating: 1500, RematchUserId: "user1"})
+	matches := mq.FindMatches()
+
+	assert.Len(t, matches, 1)
+	assert.ElementsMatch(t, []string{"user1", "user2"}, []string{matches[0].User1Id, matches[0].User2Id})
+}
+
+func TestFindMatches_givenBlockedUser_shouldNeverMatchThem(t *testing.T) {
+	clock := NewFakeClock()
+	mq := NewInMemoryMatchmakingQueue(NewDefaultRatingWindowScorer(), clock)
+
+	mq.AddUserToQueue(&MatchRequest{UserId: "user1", Rating: 1500})
+	mq.AddUserToQueue(&MatchRequest{UserId: "user2", Rating: 1500, BlockedUserIds: []string{"user1"}})
+
+	assert.Len(t, mq.FindMatches(), 0)
+
+	clock.Advance(time.Hour)
+	assert.Len(t, mq.FindMatches(), 0)
+}
@@ -3,30 +3,59 @@ package matchmaking
 import (
 	"log"
 	"quoridor/internal/events"
+	"quoridor/internal/game"
+	"quoridor/internal/ratings"
+	"quoridor/internal/users"
+	"strconv"
 	"time"
 )
 
 type MatchmakingService interface {
-	AddUser(userId string)
+	AddUser(userId string, settings game.GameSettings, rematchUserId string) *QueueStatus
 	RemoveUser(userId string)
 	StartMatchmaking()
+	StopMatchmaking()
 }
 
+const (
+	MATCHMAKING_INTERVAL  = 1 * time.Second
+	QUEUE_STATUS_INTERVAL = 5 * time.Second
+)
+
 type MatchmakingServiceImpl struct {
-	queue        MatchmakingQueue
-	eventService events.EventService
+	queue         MatchmakingQueue
+	eventService  events.EventService
+	ratingService ratings.RatingService
+	userService   users.UserService
+	botMatchAfter time.Duration // 0 disables games against bots
+	stop          chan struct{}
+	stopped       chan struct{}
 }
 
-func NewMatchmakingService(queue MatchmakingQueue, eventService events.EventService) *MatchmakingServiceImpl {
+func NewMatchmakingService(queue MatchmakingQueue, eventService events.EventService, ratingService ratings.RatingService, userService users.UserService, botMatchAfter time.Duration) *MatchmakingServiceImpl {
 	return &MatchmakingServiceImpl{
-		queue:        queue,
-		eventService: eventService,
+		queue:         queue,
+		eventService:  eventService,
+		ratingService: ratingService,
+		userService:   userService,
+		botMatchAfter: botMatchAfter,
 	}
 }
 
-func (service *MatchmakingServiceImpl) AddUser(userId string) {
-	log.Printf("Adding user to the matchmaking queue: userId=%v", userId)
-	service.queue.AddUserToQueue(userId)
+// rematchUserId is optional, it is the id of the recent opponent the user wants to play again
+func (service *MatchmakingServiceImpl) AddUser(userId string, settings game.GameSettings, rematchUserId string) *QueueStatus {
+	log.Printf("Adding user to the matchmaking queue: userId=%v, settings=%+v, rematchUserId=%v", userId, settings, rematchUserId)
+
+	request := &MatchRequest{
+		UserId:         userId,
+		Rating:         service.getRating(userId, settings.Variant),
+		Settings:       settings,
+		BlockedUserIds: service.getBlockedUserIds(userId),
+		RematchUserId:  rematchUserId,
+	}
+	service.queue.AddUserToQueue(request)
+
+	return service.queue.GetQueueStatus(userId)
 }
 
 func (service *MatchmakingServiceImpl) RemoveUser(userId string) {
@@ -36,19 +65,53 @@ func (service *MatchmakingServiceImpl) RemoveUser(userId string) {
 
 func (service *MatchmakingServiceImpl) StartMatchmaking() {
 	log.Println("Starting matchmaking goroutine...")
+	service.stop = make(chan struct{})
+	service.stopped = make(chan struct{})
+
 	go func() {
-		ticker := time.NewTicker(1 * time.Second)
-		for range ticker.C {
-			service.matchUsers()
+		defer close(service.stopped)
+
+		matchTicker := time.NewTicker(MATCHMAKING_INTERVAL)
+		defer matchTicker.Stop()
+		statusTicker := time.NewTicker(QUEUE_STATUS_INTERVAL)
+		defer statusTicker.Stop()
+
+		for {
+			select {
+			case <-matchTicker.C:
+				service.matchUsers()
+			case <-statusTicker.C:
+				service.notifyAboutQueueStatus()
+			case <-service.stop:
+				return
+			}
 		}
 	}()
 }
 
+// returns after the matchmaking goroutine finished the round it was running
+func (service *MatchmakingServiceImpl) StopMatchmaking() {
+	if service.stop == nil {
+		return
+	}
+
+	log.Println("Stopping matchmaking goroutine...")
+	close(service.stop)
+	<-service.stopped
+	service.stop = nil
+}
+
 func (service *MatchmakingServiceImpl) matchUsers() {
 	matches := service.queue.FindMatches()
 	for _, match := range matches {
 		service.notifyAboutMatch(match)
 	}
+
+	if service.botMatchAfter > 0 {
+		for _, request := range service.queue.RemoveUsersWaitingLongerThan(service.botMatchAfter) {
+			service.notifyAboutBotMatch(request)
+		}
+	}
 }
 
 func (service *MatchmakingServiceImpl) notifyAboutMatch(match *Match) {
@@ -57,9 +120,70 @@ func (service *MatchmakingServiceImpl) notifyAboutMatch(match *Match) {
 	matchEvent := &events.Event{
 		Type: events.EventTypeMatchFound,
 		Data: map[string]string{
-			"user1Id": match.User1Id,
-			"user2Id": match.User2Id,
+			"user1Id":     match.User1Id,
+			"user2Id":     match.User2Id,
+			"ranked":      strconv.FormatBool(match.Settings.Ranked),
+			"variant":     string(match.Settings.Variant),
+			"timeControl": string(match.Settings.TimeControl),
 		},
 	}
 	service.eventService.Publish(matchEvent)
 }
+
+// the user who waited too long plays against a bot of the same rating instead
+func (service *MatchmakingServiceImpl) notifyAboutBotMatch(request *MatchRequest) {
+	log.Printf("Matching user with a bot: userId=%v, rating=%v", request.UserId, request.Rating)
+
+	matchEvent := &events.Event{
+		Type: events.EventTypeBotMatchFound,
+		Data: map[string]string{
+			"userId":      request.UserId,
+			"botRating":   strconv.FormatFloat(request.Rating, 'f', -1, 64),
+			"ranked":      strconv.FormatBool(request.Settings.Ranked),
+			"variant":     string(request.Settings.Variant),
+			"timeControl": string(request.Settings.TimeControl),
+		},
+	}
+	service.eventService.Publish(matchEvent)
+}
+
+// lets the users who are still waiting know how their search is going
+func (service *MatchmakingServiceImpl) notifyAboutQueueStatus() {
+	for _, status := range service.queue.GetQueueStatuses() {
+		data := map[string]string{
+			"userId":        status.UserId,
+			"ranked":        strconv.FormatBool(status.Settings.Ranked),
+			"variant":       string(status.Settings.Variant),
+			"timeControl":   string(status.Settings.TimeControl),
+			"waited":        strconv.Itoa(int(status.Waited.Seconds())),
+			"playersInPool": strconv.Itoa(status.PlayersInPool),
+		}
+		if status.EstimatedWait != nil {
+			data["estimatedWait"] = strconv.Itoa(int(status.EstimatedWait.Seconds()))
+		}
+
+		service.eventService.Publish(&events.Event{Type: events.EventTypeQueueStatus, Data: data})
+	}
+}
+
+// the user is still matched with the default rating when the rating can't be loaded
+func (service *MatchmakingServiceImpl) getRating(userId string, variant game.GameVariant) float64 {
+	userRatings, err := service.ratingService.GetRatings([]string{userId}, string(variant))
+	if err != nil {
+		log.Printf("Error while fetching the rating, using the default one: userId=%v, err=%v", userId, err)
+		return ratings.DEFAULT_RATING
+	}
+
+	return userRatings[userId].Rating
+}
+
+// the user is matched without the blocked users list when the profile can't be loaded
+func (service *MatchmakingServiceImpl) getBlockedUserIds(userId string) []string {
+	user, err := service.userService.GetUserById(userId)
+	if err != nil {
+		log.Printf("Error while fetching the user, ignoring blocked users: userId=%v, err=%v", userId, err)
+		return nil
+	}
+
+	return user.BlockedUserIds
+}
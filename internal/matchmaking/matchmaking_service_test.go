@@ -1,19 +1,25 @@
 package matchmaking
 
 import (
+	"slices"
 	"testing"
 	"time"
 
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"quoridor/internal/errors"
 	"quoridor/internal/events"
+	"quoridor/internal/game"
+	"quoridor/internal/ratings"
+	"quoridor/internal/users"
 )
 
 type MockMatchmakingQueue struct {
 	mock.Mock
 }
 
-func (m *MockMatchmakingQueue) AddUserToQueue(userId string) {
-	m.Called(userId)
+func (m *MockMatchmakingQueue) AddUserToQueue(request *MatchRequest) {
+	m.Called(request)
 }
 
 func (m *MockMatchmakingQueue) RemoveUserFromQueue(userId string) {
@@ -25,6 +31,74 @@ func (m *MockMatchmakingQueue) FindMatches() []*Match {
 	return args.Get(0).([]*Match)
 }
 
+func (m *MockMatchmakingQueue) GetQueueStatus(userId string) *QueueStatus {
+	args := m.Called(userId)
+	return args.Get(0).(*QueueStatus)
+}
+
+func (m *MockMatchmakingQueue) GetQueueStatuses() []*QueueStatus {
+	args := m.Called()
+	return args.Get(0).([]*QueueStatus)
+}
+
+func (m *MockMatchmakingQueue) RemoveUsersWaitingLongerThan(maxWait time.Duration) []*MatchRequest {
+	args := m.Called(maxWait)
+	return args.Get(0).([]*MatchRequest)
+}
+
+type MockRatingService struct {
+	mock.Mock
+}
+
+func (m *MockRatingService) GetRatings(userIds []string, variant string) (map[string]*ratings.Rating, error) {
+	args := m.Called(userIds, variant)
+	return args.Get(0).(map[string]*ratings.Rating), args.Error(1)
+}
+
+func (m *MockRatingService) RateGame(gameId, variant, winnerId, loserId string) ([]*ratings.RatingChange, error) {
+	args := m.Called(gameId, variant, winnerId, loserId)
+	return args.Get(0).([]*ratings.RatingChange), args.Error(1)
+}
+
+type MockUserService struct {
+	mock.Mock
+}
+
+func (m *MockUserService) RegisterUser(request *users.RegisterUserRequest) (*users.User, error) {
+	args := m.Called(request)
+	return args.Get(0).(*users.User), args.Error(1)
+}
+
+func (m *MockUserService) RegisterGuest() (*users.User, error) {
+	args := m.Called()
+	return args.Get(0).(*users.User), args.Error(1)
+}
+
+func (m *MockUserService) UpgradeGuest(userId string, request *users.RegisterUserRequest) (*users.User, error) {
+	args := m.Called(userId, request)
+	return args.Get(0).(*users.User), args.Error(1)
+}
+
+func (m *MockUserService) Authenticate(username, password string) (*users.User, error) {
+	args := m.Called(username, password)
+	return args.Get(0).(*users.User), args.Error(1)
+}
+
+func (m *MockUserService) GetUserById(userId string) (*users.User, error) {
+	args := m.Called(userId)
+	return args.Get(0).(*users.User), args.Error(1)
+}
+
+func (m *MockUserService) GetUsersByIds(userIds []string) (map[string]*users.User, error) {
+	args := m.Called(userIds)
+	return args.Get(0).(map[string]*users.User), args.Error(1)
+}
+
+func (m *MockUserService) UpdateUser(userId string, request *users.UpdateUserRequest) (*users.User, error) {
+	args := m.Called(userId, request)
+	return args.Get(0).(*users.User), args.Error(1)
+}
+
 type MockEventService struct {
 	mock.Mock
 }
@@ -40,19 +114,52 @@ func (m *MockEventService) RegisterHandler(eventType events.EventType, handler e
 func TestMatchmakingService_AddUser(t *testing.T) {
 	mockQueue := new(MockMatchmakingQueue)
 	mockEventService := new(MockEventService)
-	service := NewMatchmakingService(mockQueue, mockEventService)
+	mockRatingService := new(MockRatingService)
+	mockUserService := new(MockUserService)
+	service := NewMatchmakingService(mockQueue, mockEventService, mockRatingService, mockUserService, 0)
 
-	mockQueue.On("AddUserToQueue", "user1").Return()
+	mockRatingService.On("GetRatings", []string{"user1"}, "standard").Return(map[string]*ratings.Rating{
+		"user1": {UserId: "user1", Rating: 1720},
+	}, nil)
+	mockQueue.On("AddUserToQueue", mock.Anything).Return()
+	mockUserService.On("GetUserById", "user1").Return(&users.User{UserId: "user1", BlockedUserIds: []string{"user3"}}, nil)
+	mockQueue.On("GetQueueStatus", "user1").Return(&QueueStatus{UserId: "user1", PlayersInPool: 3})
 
-	service.AddUser("user1")
+	status := service.AddUser("user1", game.DefaultGameSettings(), "user2")
 
-	mockQueue.AssertCalled(t, "AddUserToQueue", "user1")
+	mockQueue.AssertCalled(t, "AddUserToQueue", mock.MatchedBy(func(request *MatchRequest) bool {
+		return request.UserId == "user1" && request.Rating == 1720 && request.Settings == game.DefaultGameSettings() &&
+			request.RematchUserId == "user2" && slices.Equal(request.BlockedUserIds, []string{"user3"})
+	}))
+	assert.Equal(t, "user1", status.UserId)
+	assert.Equal(t, 3, status.PlayersInPool)
+}
+
+func TestMatchmakingService_AddUser_givenRatingError_shouldUseDefaultRating(t *testing.T) {
+	mockQueue := new(MockMatchmakingQueue)
+	mockEventService := new(MockEventService)
+	mockRatingService := new(MockRatingService)
+	mockUserService := new(MockUserService)
+	service := NewMatchmakingService(mockQueue, mockEventService, mockRatingService, mockUserService, 0)
+
+	mockRatingService.On("GetRatings", []string{"user1"}, "standard").Return((map[string]*ratings.Rating)(nil), errors.ErrInternalError)
+	mockQueue.On("AddUserToQueue", mock.Anything).Return()
+	mockUserService.On("GetUserById", mock.Anything).Return(&users.User{}, nil)
+	mockQueue.On("GetQueueStatus", mock.Anything).Return(&QueueStatus{})
+
+	service.AddUser("user1", game.DefaultGameSettings(), "")
+
+	mockQueue.AssertCalled(t, "AddUserToQueue", mock.MatchedBy(func(request *MatchRequest) bool {
+		return request.UserId == "user1" && request.Rating == ratings.DEFAULT_RATING
+	}))
 }
 
 func TestMatchmakingService_RemoveUser(t *testing.T) {
 	mockQueue := new(MockMatchmakingQueue)
 	mockEventService := new(MockEventService)
-	service := NewMatchmakingService(mockQueue, mockEventService)
+	mockRatingService := new(MockRatingService)
+	mockUserService := new(MockUserService)
+	service := NewMatchmakingService(mockQueue, mockEventService, mockRatingService, mockUserService, 0)
 
 	mockQueue.On("RemoveUserFromQueue", "user1").Return()
 
@@ -64,20 +171,26 @@ func TestMatchmakingService_RemoveUser(t *testing.T) {
 func TestMatchmakingService_StartMatchmaking(t *testing.T) {
 	mockQueue := new(MockMatchmakingQueue)
 	mockEventService := new(MockEventService)
-	service := NewMatchmakingService(mockQueue, mockEventService)
+	mockRatingService := new(MockRatingService)
+	mockUserService := new(MockUserService)
+	service := NewMatchmakingService(mockQueue, mockEventService, mockRatingService, mockUserService, 0)
 
 	mockQueue.On("FindMatches").Return([]*Match{
 		{User1Id: "user1", User2Id: "user2"},
 	}).Once()
+	mockQueue.On("FindMatches").Return([]*Match{})
 
-	mockQueue.On("AddUserToQueue", "user1").Return()
-	mockQueue.On("AddUserToQueue", "user2").Return()
+	mockRatingService.On("GetRatings", mock.Anything, "standard").Return((map[string]*ratings.Rating)(nil), errors.ErrInternalError)
+	mockQueue.On("AddUserToQueue", mock.Anything).Return()
+	mockUserService.On("GetUserById", mock.Anything).Return(&users.User{}, nil)
+	mockQueue.On("GetQueueStatus", mock.Anything).Return(&QueueStatus{})
 
 	mockEventService.On("Publish", mock.AnythingOfType("*events.Event")).Return()
 
 	service.StartMatchmaking()
-	service.AddUser("user1")
-	service.AddUser("user2")
+	defer service.StopMatchmaking()
+	service.AddUser("user1", game.DefaultGameSettings(), "")
+	service.AddUser("user2", game.DefaultGameSettings(), "")
 
 	time.Sleep(1500 * time.Millisecond)
 
@@ -92,17 +205,97 @@ func TestMatchmakingService_StartMatchmaking(t *testing.T) {
 func TestMatchmakingService_notifyAboutMatch(t *testing.T) {
 	mockQueue := new(MockMatchmakingQueue)
 	mockEventService := new(MockEventService)
-	service := NewMatchmakingService(mockQueue, mockEventService)
+	mockRatingService := new(MockRatingService)
+	mockUserService := new(MockUserService)
+	service := NewMatchmakingService(mockQueue, mockEventService, mockRatingService, mockUserService, 0)
 
-	match := &Match{User1Id: "user1", User2Id: "user2"}
+	settings := game.GameSettings{Ranked: true, Variant: game.GameVariantStandard, TimeControl: game.TimeControlBlitz}
+	match := &Match{User1Id: "user1", User2Id: "user2", Settings: settings}
 
 	mockEventService.On("Publish", mock.AnythingOfType("*events.Event")).Return()
 
 	service.notifyAboutMatch(match)
 
 	mockEventService.AssertCalled(t, "Publish", mock.MatchedBy(func(event *events.Event) bool {
+		data := event.Data.(map[string]string)
 		return event.Type == events.EventTypeMatchFound &&
-			event.Data.(map[string]string)["user1Id"] == "user1" &&
-			event.Data.(map[string]string)["user2Id"] == "user2"
+			data["user1Id"] == "user1" &&
+			data["user2Id"] == "user2" &&
+			data["ranked"] == "true" &&
+			data["variant"] == "standard" &&
+			data["timeControl"] == "blitz"
+	}))
+}
+
+func TestMatchmakingService_notifyAboutQueueStatus(t *testing.T) {
+	mockQueue := new(MockMatchmakingQueue)
+	mockEventService := new(MockEventService)
+	mockRatingService := new(MockRatingService)
+	mockUserService := new(MockUserService)
+	service := NewMatchmakingService(mockQueue, mockEventService, mockRatingService, mockUserService, 0)
+
+	estimatedWait := 20 * time.Second
+	mockQueue.On("GetQueueStatuses").Return([]*QueueStatus{
+		{UserId: "user1", Settings: game.DefaultGameSettings(), Waited: 12 * time.Second, PlayersInPool: 2, EstimatedWait: &estimatedWait},
+		{UserId: "user2", Settings: game.DefaultGameSettings(), Waited: 3 * time.Second, PlayersInPool: 2},
+	})
+	mockEventService.On("Publish", mock.AnythingOfType("*events.Event")).Return()
+
+	service.notifyAboutQueueStatus()
+
+	mockEventService.AssertNumberOfCalls(t, "Publish", 2)
+	mockEventService.AssertCalled(t, "Publish", mock.MatchedBy(func(event *events.Event) bool {
+		data := event.Data.(map[string]string)
+		return event.Type == events.EventTypeQueueStatus &&
+			data["userId"] == "user1" &&
+			data["waited"] == "12" &&
+			data["playersInPool"] == "2" &&
+			data["estimatedWait"] == "20" &&
+			data["variant"] == "standard"
+	}))
+	mockEventService.AssertCalled(t, "Publish", mock.MatchedBy(func(event *events.Event) bool {
+		data := event.Data.(map[string]string)
+		_, hasEstimate := data["estimatedWait"]
+		return data["userId"] == "user2" && data["waited"] == "3" && !hasEstimate
+	}))
+}
+
+func TestMatchmakingService_matchUsers_givenUserWaitedTooLong_shouldMatchWithBot(t *testing.T) {
+	mockQueue := new(MockMatchmakingQueue)
+	mockEventService := new(MockEventService)
+	mockRatingService := new(MockRatingService)
+	mockUserService := new(MockUserService)
+	service := NewMatchmakingService(mockQueue, mockEventService, mockRatingService, mockUserService, 2*time.Minute)
+
+	mockQueue.On("FindMatches").Return([]*Match{})
+	mockQueue.On("RemoveUsersWaitingLongerThan", 2*time.Minute).Return([]*MatchRequest{
+		{UserId: "user1", Rating: 1720.5, Settings: game.DefaultGameSettings()},
+	})
+	mockEventService.On("Publish", mock.AnythingOfType("*events.Event")).Return()
+
+	service.matchUsers()
+
+	mockEventService.AssertCalled(t, "Publish", mock.MatchedBy(func(event *events.Event) bool {
+		data := event.Data.(map[string]string)
+		return event.Type == events.EventTypeBotMatchFound &&
+			data["userId"] == "user1" &&
+			data["botRating"] == "1720.5" &&
+			data["variant"] == "standard" &&
+			data["timeControl"] == "rapid"
 	}))
 }
+
+func TestMatchmakingService_matchUsers_givenBotsDisabled_shouldKeepWaiting(t *testing.T) {
+	mockQueue := new(MockMatchmakingQueue)
+	mockEventService := new(MockEventService)
+	mockRatingService := new(MockRatingService)
+	mockUserService := new(MockUserService)
+	service := NewMatchmakingService(mockQueue, mockEventService, mockRatingService, mockUserService, 0)
+
+	mockQueue.On("FindMatches").Return([]*Match{})
+
+	service.matchUsers()
+
+	mockQueue.AssertNotCalled(t, "RemoveUsersWaitingLongerThan", mock.Anything)
+	mockEventService.AssertNotCalled(t, "Publish", mock.Anything)
+}
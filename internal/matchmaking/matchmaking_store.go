@@ -0,0 +1,402 @@
+package matchmaking
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"quoridor/internal/game"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+/*
+shared state of the distributed queue, every operation has to be atomic on its own.
+claimed requests are not returned by GetRequests, so a claimed user can't be matched by another instance
+*/
+type MatchmakingStore interface {
+	SaveRequest(request *MatchRequest) error
+	DeleteRequest(userId string) error
+	GetRequest(userId string) (*MatchRequest, error)
+	GetRequests() ([]*MatchRequest, error)
+	// removes either all of the requests or none of them, returns false when any of them is already gone
+	ClaimRequests(claimId string, userIds ...string) (bool, error)
+
+	// lease lock, the owner keeps it by acquiring it again before it expires
+	AcquireLock(name, owner string, now time.Time, ttl time.Duration) (bool, error)
+
+	RememberOpponents(user1Id, user2Id string, now time.Time, cooldown time.Duration) error
+	GetRecentOpponents(now time.Time) (map[[2]string]time.Time, error)
+
+	GetWaitTimes() (map[game.GameSettings]time.Duration, error)
+	SaveWaitTime(settings game.GameSettings, average time.Duration) error
+}
+
+type matchRequestDocument struct {
+	UserId         string            `bson:"_id"`
+	Rating         float64           `bson:"rating"`
+	Settings       game.GameSettings `bson:"settings"`
+	JoinTime       time.Time         `bson:"join_time"`
+	BlockedUserIds []string          `bson:"blocked_user_ids,omitempty"`
+	RematchUserId  string            `bson:"rematch_user_id,omitempty"`
+	ClaimedBy      string            `bson:"claimed_by,omitempty"`
+}
+
+type lockDocument struct {
+	Name      string    `bson:"_id"`
+	Owner     string    `bson:"owner"`
+	ExpiresAt time.Time `bson:"expires_at"`
+}
+
+type opponentsDocument struct {
+	PairId    string    `bson:"_id"`
+	User1Id   string    `bson:"user_1_id"`
+	User2Id   string    `bson:"user_2_id"`
+	MatchedAt time.Time `bson:"matched_at"`
+	ExpiresAt time.Time `bson:"expires_at"`
+}
+
+type waitTimeDocument struct {
+	PoolId   string            `bson:"_id"`
+	Settings game.GameSettings `bson:"settings"`
+	Average  time.Duration     `bson:"average"`
+}
+
+type MongoMatchmakingStore struct {
+	database  *mongo.Database
+	requests  *mongo.Collection
+	locks     *mongo.Collection
+	opponents *mongo.Collection
+	waitTimes *mongo.Collection
+}
+
+/*
+uses the collection for the requests and collections with the same prefix for the rest of the state.
+recent opponents are removed by a ttl index once the cooldown is over
+*/
+func NewMongoMatchmakingStore(database *mongo.Database, collectionName string) *MongoMatchmakingStore {
+	opponents := database.Collection(collectionName + "_opponents")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := opponents.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expires_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	if err != nil {
+		log.Printf("Error creating recent opponents index: %v", err)
+	}
+
+	return &MongoMatchmakingStore{
+		database:  database,
+		requests:  database.Collection(collectionName),
+		locks:     database.Collection(collectionName + "_locks"),
+		opponents: opponents,
+		waitTimes: database.Collection(collectionName + "_wait_times"),
+	}
+}
+
+/*
+a claimed request belongs to the instance that is matching the user, so it isn't replaced.
+the upsert fails on the duplicate id then, the claim either removes the request or releases it unchanged
+*/
+func (s *MongoMatchmakingStore) SaveRequest(request *MatchRequest) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	document := matchRequestDocument{
+		UserId:         request.UserId,
+		Rating:         request.Rating,
+		Settings:       request.Settings,
+		JoinTime:       request.JoinTime,
+		BlockedUserIds: request.BlockedUserIds,
+		RematchUserId:  request.RematchUserId,
+	}
+
+	_, err := s.requests.ReplaceOne(
+		ctx,
+		bson.M{"_id": request.UserId, "claimed_by": bson.M{"$exists": false}},
+		document,
+		options.Replace().SetUpsert(true),
+	)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return fmt.Errorf("match request of user %v is claimed", request.UserId)
+		}
+		log.Printf("Error saving match request: %v", err)
+		return err
+	}
+	return nil
+}
+
+func (s *MongoMatchmakingStore) DeleteRequest(userId string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := s.requests.DeleteOne(ctx, bson.M{"_id": userId})
+	if err != nil {
+		log.Printf("Error deleting match request: %v", err)
+		return err
+	}
+	return nil
+}
+
+func (s *MongoMatchmakingStore) GetRequest(userId string) (*MatchRequest, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var document matchRequestDocument
+	err := s.requests.FindOne(ctx, bson.M{"_id": userId, "claimed_by": bson.M{"$exists": false}}).Decode(&document)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		log.Printf("Error loading match request: %v", err)
+		return nil, err
+	}
+
+	return document.toMatchRequest(), nil
+}
+
+func (s *MongoMatchmakingStore) GetRequests() ([]*MatchRequest, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cursor, err := s.requests.Find(ctx, bson.M{"claimed_by": bson.M{"$exists": false}})
+	if err != nil {
+		log.Printf("Error loading match requests: %v", err)
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	requests := []*MatchRequest{}
+	for cursor.Next(ctx) {
+		var document matchRequestDocument
+		err := cursor.Decode(&document)
+		if err != nil {
+			log.Printf("Error decoding match request: %v", err)
+			continue
+		}
+		requests = append(requests, document.toMatchRequest())
+	}
+
+	if err := cursor.Err(); err != nil {
+		log.Printf("Cursor error: %v", err)
+		return nil, err
+	}
+
+	return requests, nil
+}
+
+/*
+the requests are marked with the claim id one by one, only unclaimed requests can be marked.
+when any of them is already claimed or removed, the marked ones are released, otherwise they are deleted one by one.
+a user can still leave the queue before their request is deleted, the deleted requests are restored then
+*/
+func (s *MongoMatchmakingStore) ClaimRequests(claimId string, userIds ...string) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	for _, userId := range userIds {
+		result, err := s.requests.UpdateOne(
+			ctx,
+			bson.M{"_id": userId, "claimed_by": bson.M{"$exists": false}},
+			bson.M{"$set": bson.M{"claimed_by": claimId}},
+		)
+		if err != nil || result.MatchedCount == 0 {
+			if err != nil {
+				log.Printf("Error claiming match request: %v", err)
+			}
+			s.releaseRequests(ctx, claimId)
+			return false, err
+		}
+	}
+
+	deleted := []*matchRequestDocument{}
+	for _, userId := range userIds {
+		var document matchRequestDocument
+		err := s.requests.FindOneAndDelete(ctx, bson.M{"_id": userId, "claimed_by": claimId}).Decode(&document)
+		if err != nil {
+			if err == mongo.ErrNoDocuments {
+				err = nil
+			} else {
+				log.Printf("Error deleting claimed match request: %v", err)
+			}
+			s.restoreRequests(ctx, deleted)
+			s.releaseRequests(ctx, claimId)
+			return false, err
+		}
+		deleted = append(deleted, &document)
+	}
+	return true, nil
+}
+
+// the user may have joined the queue again in the meantime, their new request is kept then
+func (s *MongoMatchmakingStore) restoreRequests(ctx context.Context, documents []*matchRequestDocument) {
+	for _, document := range documents {
+		document.ClaimedBy = ""
+		_, err := s.requests.InsertOne(ctx, document)
+		if err != nil && !mongo.IsDuplicateKeyError(err) {
+			log.Printf("Error restoring match request: %v", err)
+		}
+	}
+}
+
+func (s *MongoMatchmakingStore) releaseRequests(ctx context.Context, claimId string) {
+	_, err := s.requests.UpdateMany(ctx, bson.M{"claimed_by": claimId}, bson.M{"$unset": bson.M{"claimed_by": ""}})
+	if err != nil {
+		log.Printf("Error releasing claimed match requests: %v", err)
+	}
+}
+
+/*
+the lock document is updated only when it is expired or already owned by the owner.
+when another instance holds the lock the filter doesn't match and the upsert fails on the duplicate id
+*/
+func (s *MongoMatchmakingStore) AcquireLock(name, owner string, now time.Time, ttl time.Duration) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{
+		"_id": name,
+		"$or": []bson.M{
+			{"expires_at": bson.M{"$lte": now}},
+			{"owner": owner},
+		},
+	}
+	lock := lockDocument{Name: name, Owner: owner, ExpiresAt: now.Add(ttl)}
+
+	_, err := s.locks.ReplaceOne(ctx, filter, lock, options.Replace().SetUpsert(true))
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return false, nil
+		}
+		log.Printf("Error acquiring lock: %v", err)
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *MongoMatchmakingStore) RememberOpponents(user1Id, user2Id string, now time.Time, cooldown time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	pair := opponentsPair(user1Id, user2Id)
+	document := opponentsDocument{
+		PairId:    pair[0] + ":" + pair[1],
+		User1Id:   pair[0],
+		User2Id:   pair[1],
+		MatchedAt: now,
+		ExpiresAt: now.Add(cooldown),
+	}
+
+	_, err := s.opponents.ReplaceOne(
+		ctx,
+		bson.M{"_id": document.PairId},
+		document,
+		options.Replace().SetUpsert(true),
+	)
+	if err != nil {
+		log.Printf("Error saving recent opponents: %v", err)
+		return err
+	}
+	return nil
+}
+
+// the ttl monitor runs only once a minute, so expired pairs are filtered out here as well
+func (s *MongoMatchmakingStore) GetRecentOpponents(now time.Time) (map[[2]string]time.Time, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cursor, err := s.opponents.Find(ctx, bson.M{"expires_at": bson.M{"$gt": now}})
+	if err != nil {
+		log.Printf("Error loading recent opponents: %v", err)
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	opponents := map[[2]string]time.Time{}
+	for cursor.Next(ctx) {
+		var document opponentsDocument
+		err := cursor.Decode(&document)
+		if err != nil {
+			log.Printf("Error decoding recent opponents: %v", err)
+			continue
+		}
+		opponents[opponentsPair(document.User1Id, document.User2Id)] = document.MatchedAt
+	}
+
+	if err := cursor.Err(); err != nil {
+		log.Printf("Cursor error: %v", err)
+		return nil, err
+	}
+
+	return opponents, nil
+}
+
+func (s *MongoMatchmakingStore) GetWaitTimes() (map[game.GameSettings]time.Duration, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cursor, err := s.waitTimes.Find(ctx, bson.M{})
+	if err != nil {
+		log.Printf("Error loading wait times: %v", err)
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	waitTimes := map[game.GameSettings]time.Duration{}
+	for cursor.Next(ctx) {
+		var document waitTimeDocument
+		err := cursor.Decode(&document)
+		if err != nil {
+			log.Printf("Error decoding wait time: %v", err)
+			continue
+		}
+		waitTimes[document.Settings] = document.Average
+	}
+
+	if err := cursor.Err(); err != nil {
+		log.Printf("Cursor error: %v", err)
+		return nil, err
+	}
+
+	return waitTimes, nil
+}
+
+func (s *MongoMatchmakingStore) SaveWaitTime(settings game.GameSettings, average time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	document := waitTimeDocument{
+		PoolId:   fmt.Sprintf("%t:%s:%s", settings.Ranked, settings.Variant, settings.TimeControl),
+		Settings: settings,
+		Average:  average,
+	}
+
+	_, err := s.waitTimes.ReplaceOne(
+		ctx,
+		bson.M{"_id": document.PoolId},
+		document,
+		options.Replace().SetUpsert(true),
+	)
+	if err != nil {
+		log.Printf("Error saving wait time: %v", err)
+		return err
+	}
+	return nil
+}
+
+func (d *matchRequestDocument) toMatchRequest() *MatchRequest {
+	return &MatchRequest{
+		UserId:         d.UserId,
+		Rating:         d.Rating,
+		Settings:       d.Settings,
+		JoinTime:       d.JoinTime,
+		BlockedUserIds: d.BlockedUserIds,
+		RematchUserId:  d.RematchUserId,
+	}
+}
@@ -1,13 +1,32 @@
 package matchmaking
 
-import "time"
+import (
+	"quoridor/internal/game"
+	"time"
+)
 
 type Match struct {
-	User1Id string
-	User2Id string
+	User1Id  string
+	User2Id  string
+	Settings game.GameSettings
 }
 
 type MatchRequest struct {
-	UserId   string
-	JoinTime time.Time
+	UserId         string
+	Rating         float64
+	Settings       game.GameSettings
+	JoinTime       time.Time
+	BlockedUserIds []string
+	RematchUserId  string // recent opponents are matched right away when both of them ask for a rematch
+}
+
+// snapshot of the position of a user in the queue
+type QueueStatus struct {
+	UserId        string
+	Settings      game.GameSettings
+	JoinTime      time.Time
+	Waited        time.Duration
+	PlayersInPool int
+	// nil until somebody is matched in the pool, so there is nothing to estimate from
+	EstimatedWait *time.Duration
 }
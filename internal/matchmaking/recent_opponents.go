@@ -0,0 +1,56 @@
+package matchmaking
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// users who were matched with each other are not matched again for this time
+	RECENT_OPPONENT_COOLDOWN = 15 * time.Minute
+	// recent opponents are matched anyway when they both waited this long and nobody else is in the pool
+	RECENT_OPPONENT_FALLBACK_AFTER = 1 * time.Minute
+)
+
+// remembers who was matched with whom, pairs are forgotten after the cooldown
+type RecentOpponents struct {
+	mu       sync.Mutex
+	cooldown time.Duration
+	matches  map[[2]string]time.Time
+}
+
+func NewRecentOpponents(cooldown time.Duration) *RecentOpponents {
+	return &RecentOpponents{
+		cooldown: cooldown,
+		matches:  make(map[[2]string]time.Time),
+	}
+}
+
+func (ro *RecentOpponents) Remember(user1Id, user2Id string, now time.Time) {
+	ro.mu.Lock()
+	defer ro.mu.Unlock()
+
+	for pair, matchedAt := range ro.matches {
+		if now.Sub(matchedAt) >= ro.cooldown {
+			delete(ro.matches, pair)
+		}
+	}
+
+	ro.matches[opponentsPair(user1Id, user2Id)] = now
+}
+
+func (ro *RecentOpponents) PlayedRecently(user1Id, user2Id string, now time.Time) bool {
+	ro.mu.Lock()
+	defer ro.mu.Unlock()
+
+	matchedAt, ok := ro.matches[opponentsPair(user1Id, user2Id)]
+	return ok && now.Sub(matchedAt) < ro.cooldown
+}
+
+// the same pair regardless of the order of the users
+func opponentsPair(user1Id, user2Id string) [2]string {
+	if user1Id > user2Id {
+		return [2]string{user2Id, user1Id}
+	}
+	return [2]string{user1Id, user2Id}
+}
@@ -0,0 +1,31 @@
+package matchmaking
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecentOpponents(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	opponents := NewRecentOpponents(10 * time.Minute)
+
+	opponents.Remember("user1", "user2", now)
+
+	assert.True(t, opponents.PlayedRecently("user1", "user2", now))
+	assert.True(t, opponents.PlayedRecently("user2", "user1", now.Add(9*time.Minute)))
+	assert.False(t, opponents.PlayedRecently("user1", "user3", now))
+	assert.False(t, opponents.PlayedRecently("user1", "user2", now.Add(10*time.Minute)))
+}
+
+func TestRecentOpponents_Remember_shouldForgetExpiredPairs(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	opponents := NewRecentOpponents(10 * time.Minute)
+
+	opponents.Remember("user1", "user2", now)
+	opponents.Remember("user3", "user4", now.Add(15*time.Minute))
+
+	assert.Len(t, opponents.matches, 1)
+	assert.True(t, opponents.PlayedRecently("user3", "user4", now.Add(15*time.Minute)))
+}
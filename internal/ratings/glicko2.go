@@ -0,0 +1,100 @@
+package ratings
+
+import "math"
+
+const (
+	DEFAULT_RATING        = 1500.0
+	DEFAULT_DEVIATION     = 350.0
+	DEFAULT_VOLATILITY    = 0.06
+	MIN_DEVIATION         = 45.0
+	PROVISIONAL_DEVIATION = 110.0
+
+	GLICKO2_SCALE       = 173.7178
+	GLICKO2_TAU         = 0.5 // constrains the change of the volatility
+	GLICKO2_CONVERGENCE = 0.000001
+)
+
+/*
+calculates the new rating, deviation and volatility of the player after a rating period with the given results,
+see http://www.glicko.net/glicko/glicko2.pdf for the description of every step
+*/
+func calculateRating(rating, deviation, volatility float64, results []gameResult) (float64, float64, float64) {
+	mu := (rating - DEFAULT_RATING) / GLICKO2_SCALE
+	phi := deviation / GLICKO2_SCALE
+
+	if len(results) == 0 {
+		phi = math.Sqrt(phi*phi + volatility*volatility)
+		return rating, clampDeviation(phi * GLICKO2_SCALE), volatility
+	}
+
+	variance := 0.0
+	improvement := 0.0
+	for _, result := range results {
+		opponentMu := (result.opponentRating - DEFAULT_RATING) / GLICKO2_SCALE
+		opponentPhi := result.opponentDeviation / GLICKO2_SCALE
+
+		g := glickoG(opponentPhi)
+		expected := glickoExpected(mu, opponentMu, g)
+
+		variance += g * g * expected * (1 - expected)
+		improvement += g * (result.score - expected)
+	}
+	variance = 1 / variance
+	delta := variance * improvement
+
+	newVolatility := calculateVolatility(phi, volatility, variance, delta)
+
+	preRatingPhi := math.Sqrt(phi*phi + newVolatility*newVolatility)
+	newPhi := 1 / math.Sqrt(1/(preRatingPhi*preRatingPhi)+1/variance)
+	newMu := mu + newPhi*newPhi*improvement
+
+	return newMu*GLICKO2_SCALE + DEFAULT_RATING, clampDeviation(newPhi * GLICKO2_SCALE), newVolatility
+}
+
+// finds the new volatility with the Illinois algorithm
+func calculateVolatility(phi, volatility, variance, delta float64) float64 {
+	a := math.Log(volatility * volatility)
+	f := func(x float64) float64 {
+		ex := math.Exp(x)
+		d := phi*phi + variance + ex
+		return ex*(delta*delta-phi*phi-variance-ex)/(2*d*d) - (x-a)/(GLICKO2_TAU*GLICKO2_TAU)
+	}
+
+	A := a
+	var B float64
+	if delta*delta > phi*phi+variance {
+		B = math.Log(delta*delta - phi*phi - variance)
+	} else {
+		k := 1.0
+		for f(a-k*GLICKO2_TAU) < 0 {
+			k++
+		}
+		B = a - k*GLICKO2_TAU
+	}
+
+	fA, fB := f(A), f(B)
+	for math.Abs(B-A) > GLICKO2_CONVERGENCE {
+		C := A + (A-B)*fA/(fB-fA)
+		fC := f(C)
+		if fC*fB <= 0 {
+			A, fA = B, fB
+		} else {
+			fA /= 2
+		}
+		B, fB = C, fC
+	}
+
+	return math.Exp(A / 2)
+}
+
+func glickoG(phi float64) float64 {
+	return 1 / math.Sqrt(1+3*phi*phi/(math.Pi*math.Pi))
+}
+
+func glickoExpected(mu, opponentMu, g float64) float64 {
+	return 1 / (1 + math.Exp(-g*(mu-opponentMu)))
+}
+
+func clampDeviation(deviation float64) float64 {
+	return math.Max(MIN_DEVIATION, math.Min(DEFAULT_DEVIATION, deviation))
+}
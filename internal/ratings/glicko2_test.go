@@ -0,0 +1,40 @@
+package ratings
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// example from http://www.glicko.net/glicko/glicko2.pdf
+func TestCalculateRating(t *testing.T) {
+	results := []gameResult{
+		{opponentRating: 1400, opponentDeviation: 30, score: 1},
+		{opponentRating: 1550, opponentDeviation: 100, score: 0},
+		{opponentRating: 1700, opponentDeviation: 300, score: 0},
+	}
+
+	rating, deviation, volatility := calculateRating(1500, 200, 0.06, results)
+
+	assert.InDelta(t, 1464.06, rating, 0.01)
+	assert.InDelta(t, 151.52, deviation, 0.01)
+	assert.InDelta(t, 0.05999, volatility, 0.00001)
+}
+
+func TestCalculateRating_givenNoGames_shouldIncreaseDeviation(t *testing.T) {
+	rating, deviation, volatility := calculateRating(1500, 200, 0.06, []gameResult{})
+
+	assert.Equal(t, 1500.0, rating)
+	assert.InDelta(t, 200.27, deviation, 0.01)
+	assert.Equal(t, 0.06, volatility)
+}
+
+func TestCalculateRating_shouldKeepDeviationInBounds(t *testing.T) {
+	_, deviation, _ := calculateRating(1500, DEFAULT_DEVIATION, DEFAULT_VOLATILITY, []gameResult{})
+	assert.Equal(t, DEFAULT_DEVIATION, deviation)
+
+	_, deviation, _ = calculateRating(1500, MIN_DEVIATION, 0.0001, []gameResult{
+		{opponentRating: 1500, opponentDeviation: MIN_DEVIATION, score: 1},
+	})
+	assert.Equal(t, MIN_DEVIATION, deviation)
+}
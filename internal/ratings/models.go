@@ -0,0 +1,56 @@
+package ratings
+
+import "time"
+
+// rating of the user in one game variant
+type Rating struct {
+	RatingId    string    `bson:"_id" json:"-"` // <user id>:<variant>
+	UserId      string    `bson:"user_id" json:"user_id"`
+	Variant     string    `bson:"variant" json:"variant"`
+	Rating      float64   `bson:"rating" json:"rating"`
+	Deviation   float64   `bson:"deviation" json:"deviation"`
+	Volatility  float64   `bson:"volatility" json:"volatility"`
+	GamesPlayed int       `bson:"games_played" json:"games_played"`
+	UpdatedAt   time.Time `bson:"updated_at" json:"updated_at"`
+
+	// incremented by every update, an update of an older version is rejected
+	Version int64 `bson:"version" json:"-"`
+	// latest games applied to the rating, a game is never applied twice
+	RatedGames []*RatedGame `bson:"rated_games,omitempty" json:"-"`
+}
+
+type RatedGame struct {
+	GameId string        `bson:"game_id"`
+	Change *RatingChange `bson:"change"`
+	// the opponent is rated against the deviation before the game even when this rating is updated first
+	DeviationBefore float64 `bson:"deviation_before"`
+}
+
+func (r *Rating) ratedGame(gameId string) *RatedGame {
+	for _, game := range r.RatedGames {
+		if game.GameId == gameId {
+			return game
+		}
+	}
+	return nil
+}
+
+// the rating is provisional until the deviation drops low enough, so new players can't be ranked yet
+func (r *Rating) IsProvisional() bool {
+	return r.Deviation > PROVISIONAL_DEVIATION
+}
+
+type RatingChange struct {
+	UserId       string  `bson:"user_id" json:"user_id"`
+	RatingBefore float64 `bson:"rating_before" json:"rating_before"`
+	RatingAfter  float64 `bson:"rating_after" json:"rating_after"`
+	Change       float64 `bson:"change" json:"change"`
+	Deviation    float64 `bson:"deviation" json:"deviation"`
+	Provisional  bool    `bson:"provisional" json:"provisional"`
+}
+
+type gameResult struct {
+	opponentRating    float64
+	opponentDeviation float64
+	score             float64 // 1 for a win, 0 for a loss
+}
@@ -0,0 +1,84 @@
+package ratings
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type RatingRepository interface {
+	UpdateRating(rating *Rating, version int64) (bool, error)
+	GetRatings(userIds []string, variant string) ([]*Rating, error)
+}
+
+type MongoRatingRepository struct {
+	database   *mongo.Database
+	collection *mongo.Collection
+}
+
+func NewMongoRatingRepository(database *mongo.Database, collectionName string) *MongoRatingRepository {
+	collection := database.Collection(collectionName)
+	return &MongoRatingRepository{
+		database:   database,
+		collection: collection,
+	}
+}
+
+/*
+replaces the rating only if it is still at the given version, returns false when it was updated in the meantime.
+version 0 is a rating that was never stored, ratings stored before versioning have no version
+*/
+func (r *MongoRatingRepository) UpdateRating(rating *Rating, version int64) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{"_id": rating.RatingId, "version": version}
+	if version == 0 {
+		filter = bson.M{"_id": rating.RatingId, "version": bson.M{"$in": bson.A{0, nil}}}
+	}
+
+	rating.Version = version + 1
+	result, err := r.collection.ReplaceOne(ctx, filter, rating, options.Replace().SetUpsert(version == 0))
+	if mongo.IsDuplicateKeyError(err) {
+		return false, nil
+	}
+	if err != nil {
+		log.Printf("Error saving rating: %v", err)
+		return false, err
+	}
+	return result.MatchedCount > 0 || result.UpsertedCount > 0, nil
+}
+
+func (r *MongoRatingRepository) GetRatings(userIds []string, variant string) ([]*Rating, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cursor, err := r.collection.Find(ctx, bson.M{"user_id": bson.M{"$in": userIds}, "variant": variant})
+	if err != nil {
+		log.Printf("Error loading ratings: %v", err)
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	ratings := []*Rating{}
+	for cursor.Next(ctx) {
+		var rating Rating
+		err := cursor.Decode(&rating)
+		if err != nil {
+			log.Printf("Error decoding rating: %v", err)
+			continue
+		}
+		ratings = append(ratings, &rating)
+	}
+
+	if err := cursor.Err(); err != nil {
+		log.Printf("Cursor error: %v", err)
+		return nil, err
+	}
+
+	return ratings, nil
+}
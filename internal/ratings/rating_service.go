@@ -0,0 +1,162 @@
+package ratings
+
+import (
+	"fmt"
+	"log"
+	"quoridor/internal/errors"
+	"time"
+)
+
+type RatingService interface {
+	GetRatings(userIds []string, variant string) (map[string]*Rating, error)
+	RateGame(gameId, variant, winnerId, loserId string) ([]*RatingChange, error)
+}
+
+const (
+	// ratings updated concurrently by other games are read again, this many times at most
+	MAX_RATING_ATTEMPTS = 5
+	// games kept on the rating to recognise a game that is rated again
+	RATED_GAMES_KEPT = 20
+)
+
+type RatingServiceImpl struct {
+	repository RatingRepository
+}
+
+func NewRatingService(repository RatingRepository) *RatingServiceImpl {
+	return &RatingServiceImpl{
+		repository: repository,
+	}
+}
+
+// users without a rating in the variant get the default rating of a new player
+func (service *RatingServiceImpl) GetRatings(userIds []string, variant string) (map[string]*Rating, error) {
+	log.Printf("Fetching ratings: userIds=%v, variant=%v", userIds, variant)
+
+	ratings, err := service.repository.GetRatings(userIds, variant)
+	if err != nil {
+		log.Printf("Error while fetching ratings. err=%v", err)
+		return nil, errors.ErrInternalError
+	}
+
+	ratingsByUserId := make(map[string]*Rating, len(userIds))
+	for _, rating := range ratings {
+		ratingsByUserId[rating.UserId] = rating
+	}
+
+	for _, userId := range userIds {
+		if _, ok := ratingsByUserId[userId]; !ok {
+			ratingsByUserId[userId] = newRating(userId, variant)
+		}
+	}
+
+	return ratingsByUserId, nil
+}
+
+/*
+updates ratings of both players after a finished game, every game is its own rating period.
+the game is applied to each rating at most once, rating it again returns the changes it made the first time.
+returns the changes of the winner and the loser in this order
+*/
+func (service *RatingServiceImpl) RateGame(gameId, variant, winnerId, loserId string) ([]*RatingChange, error) {
+	log.Printf("Rating game: gameId=%v, variant=%v, winnerId=%v, loserId=%v", gameId, variant, winnerId, loserId)
+
+	for attempt := 0; attempt < MAX_RATING_ATTEMPTS; attempt++ {
+		changes, err := service.tryRateGame(gameId, variant, winnerId, loserId)
+		if err != nil {
+			return nil, err
+		}
+		if changes != nil {
+			log.Printf("Rated game: gameId=%v, winner %s %+.1f, loser %s %+.1f", gameId, winnerId, changes[0].Change, loserId, changes[1].Change)
+			return changes, nil
+		}
+		log.Printf("Ratings were updated concurrently, rating the game again: gameId=%v, attempt=%d", gameId, attempt+1)
+	}
+
+	log.Printf("Failed to rate the game, ratings keep changing: gameId=%v", gameId)
+	return nil, errors.ErrInternalError
+}
+
+// returns nil changes when one of the ratings was updated after it was read
+func (service *RatingServiceImpl) tryRateGame(gameId, variant, winnerId, loserId string) ([]*RatingChange, error) {
+	ratings, err := service.GetRatings([]string{winnerId, loserId}, variant)
+	if err != nil {
+		return nil, err
+	}
+	winner, loser := ratings[winnerId], ratings[loserId]
+
+	// both players are rated against the ratings before the game
+	winnerResult := gameResult{opponentRating: loser.Rating, opponentDeviation: loser.Deviation, score: 1}
+	loserResult := gameResult{opponentRating: winner.Rating, opponentDeviation: winner.Deviation, score: 0}
+	if game := winner.ratedGame(gameId); game != nil {
+		loserResult.opponentRating, loserResult.opponentDeviation = game.Change.RatingBefore, game.DeviationBefore
+	}
+	if game := loser.ratedGame(gameId); game != nil {
+		winnerResult.opponentRating, winnerResult.opponentDeviation = game.Change.RatingBefore, game.DeviationBefore
+	}
+
+	now := time.Now()
+	winnerChange, err := service.applyGame(gameId, winner, winnerResult, now)
+	if err != nil || winnerChange == nil {
+		return nil, err
+	}
+	loserChange, err := service.applyGame(gameId, loser, loserResult, now)
+	if err != nil || loserChange == nil {
+		return nil, err
+	}
+
+	return []*RatingChange{winnerChange, loserChange}, nil
+}
+
+// returns the stored change when the game was already applied, nil when the rating was updated after it was read
+func (service *RatingServiceImpl) applyGame(gameId string, rating *Rating, result gameResult, now time.Time) (*RatingChange, error) {
+	if game := rating.ratedGame(gameId); game != nil {
+		return game.Change, nil
+	}
+
+	version := rating.Version
+	deviation := rating.Deviation
+	change := applyResult(rating, result, now)
+
+	rating.RatedGames = append(rating.RatedGames, &RatedGame{GameId: gameId, Change: change, DeviationBefore: deviation})
+	if len(rating.RatedGames) > RATED_GAMES_KEPT {
+		rating.RatedGames = rating.RatedGames[len(rating.RatedGames)-RATED_GAMES_KEPT:]
+	}
+
+	updated, err := service.repository.UpdateRating(rating, version)
+	if err != nil {
+		log.Printf("Error while saving the rating. userId=%v, err=%v", rating.UserId, err)
+		return nil, errors.ErrInternalError
+	}
+	if !updated {
+		return nil, nil
+	}
+	return change, nil
+}
+
+func applyResult(rating *Rating, result gameResult, now time.Time) *RatingChange {
+	before := rating.Rating
+	rating.Rating, rating.Deviation, rating.Volatility = calculateRating(rating.Rating, rating.Deviation, rating.Volatility, []gameResult{result})
+	rating.GamesPlayed++
+	rating.UpdatedAt = now
+
+	return &RatingChange{
+		UserId:       rating.UserId,
+		RatingBefore: before,
+		RatingAfter:  rating.Rating,
+		Change:       rating.Rating - before,
+		Deviation:    rating.Deviation,
+		Provisional:  rating.IsProvisional(),
+	}
+}
+
+func newRating(userId, variant string) *Rating {
+	return &Rating{
+		RatingId:   fmt.Sprintf("%s:%s", userId, variant),
+		UserId:     userId,
+		Variant:    variant,
+		Rating:     DEFAULT_RATING,
+		Deviation:  DEFAULT_DEVIATION,
+		Volatility: DEFAULT_VOLATILITY,
+	}
+}
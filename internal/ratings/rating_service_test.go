@@ -0,0 +1,186 @@
+package ratings
+
+import (
+	"testing"
+
+	"quoridor/internal/errors"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockRatingRepository struct {
+	mock.Mock
+}
+
+func (m *MockRatingRepository) UpdateRating(rating *Rating, version int64) (bool, error) {
+	args := m.Called(rating, version)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRatingRepository) GetRatings(userIds []string, variant string) ([]*Rating, error) {
+	args := m.Called(userIds, variant)
+	return args.Get(0).([]*Rating), args.Error(1)
+}
+
+func TestGetRatings_givenNewPlayer_shouldReturnDefaultRating(t *testing.T) {
+	repo := new(MockRatingRepository)
+	service := NewRatingService(repo)
+
+	repo.On("GetRatings", []string{"user1", "user2"}, "standard").Return([]*Rating{
+		{RatingId: "user1:standard", UserId: "user1", Variant: "standard", Rating: 1700, Deviation: 60, Volatility: 0.06},
+	}, nil)
+
+	ratings, err := service.GetRatings([]string{"user1", "user2"}, "standard")
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1700.0, ratings["user1"].Rating)
+	assert.False(t, ratings["user1"].IsProvisional())
+	assert.Equal(t, "user2:standard", ratings["user2"].RatingId)
+	assert.Equal(t, DEFAULT_RATING, ratings["user2"].Rating)
+	assert.Equal(t, DEFAULT_DEVIATION, ratings["user2"].Deviation)
+	assert.True(t, ratings["user2"].IsProvisional())
+}
+
+func TestRateGame(t *testing.T) {
+	repo := new(MockRatingRepository)
+	service := NewRatingService(repo)
+
+	repo.On("GetRatings", []string{"user1", "user2"}, "standard").Return([]*Rating{
+		{RatingId: "user1:standard", UserId: "user1", Variant: "standard", Rating: 1600, Deviation: 80, Volatility: 0.06, GamesPlayed: 30},
+		{RatingId: "user2:standard", UserId: "user2", Variant: "standard", Rating: 1600, Deviation: 80, Volatility: 0.06, GamesPlayed: 30},
+	}, nil)
+	repo.On("UpdateRating", mock.Anything, int64(0)).Return(true, nil)
+
+	changes, err := service.RateGame("game1", "standard", "user1", "user2")
+
+	assert.NoError(t, err)
+	assert.Len(t, changes, 2)
+
+	winner, loser := changes[0], changes[1]
+	assert.Equal(t, "user1", winner.UserId)
+	assert.Equal(t, 1600.0, winner.RatingBefore)
+	assert.Greater(t, winner.Change, 0.0)
+	assert.Equal(t, winner.RatingAfter-winner.RatingBefore, winner.Change)
+	assert.False(t, winner.Provisional)
+
+	// equally rated players exchange the same amount of points
+	assert.Equal(t, "user2", loser.UserId)
+	assert.InDelta(t, -winner.Change, loser.Change, 0.0001)
+
+	repo.AssertNumberOfCalls(t, "UpdateRating", 2)
+	repo.AssertCalled(t, "UpdateRating", mock.MatchedBy(func(rating *Rating) bool {
+		return rating.UserId == "user1" && rating.GamesPlayed == 31 && rating.Rating == winner.RatingAfter && rating.ratedGame("game1") != nil
+	}), int64(0))
+}
+
+func TestRateGame_givenNewPlayer_shouldChangeProvisionalRatingMore(t *testing.T) {
+	repo := new(MockRatingRepository)
+	service := NewRatingService(repo)
+
+	repo.On("GetRatings", []string{"user1", "user2"}, "standard").Return([]*Rating{
+		{RatingId: "user2:standard", UserId: "user2", Variant: "standard", Rating: 1500, Deviation: 60, Volatility: 0.06, GamesPlayed: 50},
+	}, nil)
+	repo.On("UpdateRating", mock.Anything, int64(0)).Return(true, nil)
+
+	changes, err := service.RateGame("game1", "standard", "user1", "user2")
+
+	assert.NoError(t, err)
+
+	newPlayer, establishedPlayer := changes[0], changes[1]
+	assert.True(t, newPlayer.Provisional)
+	assert.False(t, establishedPlayer.Provisional)
+	assert.Greater(t, newPlayer.Change, -establishedPlayer.Change)
+}
+
+func TestRateGame_givenSaveError_shouldReturnError(t *testing.T) {
+	repo := new(MockRatingRepository)
+	service := NewRatingService(repo)
+
+	repo.On("GetRatings", []string{"user1", "user2"}, "standard").Return([]*Rating{}, nil)
+	repo.On("UpdateRating", mock.Anything, int64(0)).Return(false, assert.AnError)
+
+	changes, err := service.RateGame("game1", "standard", "user1", "user2")
+
+	assert.ErrorIs(t, err, errors.ErrInternalError)
+	assert.Nil(t, changes)
+}
+
+func TestRateGame_givenGameAlreadyRated_shouldReturnStoredChanges(t *testing.T) {
+	repo := new(MockRatingRepository)
+	service := NewRatingService(repo)
+
+	winnerChange := &RatingChange{UserId: "user1", RatingBefore: 1600, RatingAfter: 1610, Change: 10}
+	loserChange := &RatingChange{UserId: "user2", RatingBefore: 1600, RatingAfter: 1590, Change: -10}
+	repo.On("GetRatings", []string{"user1", "user2"}, "standard").Return([]*Rating{
+		{RatingId: "user1:standard", UserId: "user1", Variant: "standard", Rating: 1610, Deviation: 70, Volatility: 0.06, Version: 3,
+			RatedGames: []*RatedGame{{GameId: "game1", Change: winnerChange, DeviationBefore: 80}}},
+		{RatingId: "user2:standard", UserId: "user2", Variant: "standard", Rating: 1590, Deviation: 70, Volatility: 0.06, Version: 5,
+			RatedGames: []*RatedGame{{GameId: "game1", Change: loserChange, DeviationBefore: 80}}},
+	}, nil)
+
+	changes, err := service.RateGame("game1", "standard", "user1", "user2")
+
+	assert.NoError(t, err)
+	assert.Equal(t, []*RatingChange{winnerChange, loserChange}, changes)
+	repo.AssertNotCalled(t, "UpdateRating", mock.Anything, mock.Anything)
+}
+
+func TestRateGame_givenWinnerAlreadyRated_shouldRateLoserAgainstRatingBeforeGame(t *testing.T) {
+	repo := new(MockRatingRepository)
+	service := NewRatingService(repo)
+
+	repo.On("GetRatings", []string{"user1", "user2"}, "standard").Return([]*Rating{
+		{RatingId: "user1:standard", UserId: "user1", Variant: "standard", Rating: 1600, Deviation: 80, Volatility: 0.06, GamesPlayed: 30},
+		{RatingId: "user2:standard", UserId: "user2", Variant: "standard", Rating: 1600, Deviation: 80, Volatility: 0.06, GamesPlayed: 30},
+	}, nil).Once()
+	repo.On("UpdateRating", mock.Anything, int64(0)).Return(true, nil)
+
+	expected, err := service.RateGame("game1", "standard", "user1", "user2")
+	assert.NoError(t, err)
+
+	// the loser wasn't saved the first time, the winner already has the game
+	repo.On("GetRatings", []string{"user1", "user2"}, "standard").Return([]*Rating{
+		{RatingId: "user1:standard", UserId: "user1", Variant: "standard", Rating: expected[0].RatingAfter, Deviation: expected[0].Deviation, Volatility: 0.06, GamesPlayed: 31, Version: 1,
+			RatedGames: []*RatedGame{{GameId: "game1", Change: expected[0], DeviationBefore: 80}}},
+		{RatingId: "user2:standard", UserId: "user2", Variant: "standard", Rating: 1600, Deviation: 80, Volatility: 0.06, GamesPlayed: 30, Version: 4},
+	}, nil).Once()
+	repo.On("UpdateRating", mock.Anything, int64(4)).Return(true, nil)
+
+	changes, err := service.RateGame("game1", "standard", "user1", "user2")
+
+	assert.NoError(t, err)
+	assert.Equal(t, expected[0], changes[0])
+	assert.InDelta(t, expected[1].Change, changes[1].Change, 0.0001)
+	repo.AssertNotCalled(t, "UpdateRating", mock.Anything, int64(1))
+}
+
+func TestRateGame_givenRatingUpdatedConcurrently_shouldRetry(t *testing.T) {
+	repo := new(MockRatingRepository)
+	service := NewRatingService(repo)
+
+	repo.On("GetRatings", []string{"user1", "user2"}, "standard").Return([]*Rating{}, nil)
+	repo.On("UpdateRating", mock.Anything, int64(0)).Return(false, nil).Once()
+	repo.On("UpdateRating", mock.Anything, int64(0)).Return(true, nil)
+
+	changes, err := service.RateGame("game1", "standard", "user1", "user2")
+
+	assert.NoError(t, err)
+	assert.Len(t, changes, 2)
+	repo.AssertNumberOfCalls(t, "GetRatings", 2)
+	repo.AssertNumberOfCalls(t, "UpdateRating", 3)
+}
+
+func TestRateGame_givenRatingsKeepChanging_shouldReturnError(t *testing.T) {
+	repo := new(MockRatingRepository)
+	service := NewRatingService(repo)
+
+	repo.On("GetRatings", []string{"user1", "user2"}, "standard").Return([]*Rating{}, nil)
+	repo.On("UpdateRating", mock.Anything, int64(0)).Return(false, nil)
+
+	changes, err := service.RateGame("game1", "standard", "user1", "user2")
+
+	assert.ErrorIs(t, err, errors.ErrInternalError)
+	assert.Nil(t, changes)
+	repo.AssertNumberOfCalls(t, "GetRatings", MAX_RATING_ATTEMPTS)
+}
@@ -2,7 +2,11 @@ package router
 
 import (
 	"net/http"
+	"quoridor/internal/auth"
+	"quoridor/internal/cors"
+	"quoridor/internal/game"
 	"quoridor/internal/sockets"
+	"quoridor/internal/users"
 
 	"github.com/gin-gonic/gin"
 )
@@ -15,8 +19,9 @@ type RouterImpl struct {
 	Engine *gin.Engine
 }
 
-func NewRouter(websocketHander sockets.WebsocketHandler) *RouterImpl {
+func NewRouter(websocketHander sockets.WebsocketHandler, gameHandler game.GameHandler, userHandler users.UserHandler, authHandler auth.AuthHandler, tokenService auth.TokenService, origins *cors.OriginPolicy) *RouterImpl {
 	router := gin.Default()
+	router.Use(cors.Middleware(origins))
 
 	v1 := router.Group("v1")
 	v1.GET("health", func(ctx *gin.Context) {
@@ -25,6 +30,25 @@ func NewRouter(websocketHander sockets.WebsocketHandler) *RouterImpl {
 
 	v1.GET("ws", websocketHander.HandleWs)
 
+	v1.POST("auth/token", authHandler.HandleLogin)
+	v1.POST("auth/guest", authHandler.HandleGuestLogin)
+	v1.POST("auth/upgrade", authHandler.HandleUpgradeGuest)
+
+	v1.POST("users", userHandler.HandleRegisterUser)
+	v1.GET("users/:user_id", userHandler.HandleGetUser)
+	v1.PATCH("users/:user_id", auth.RequireToken(tokenService), auth.RequireOwner("user_id"), userHandler.HandleUpdateUser)
+	v1.GET("users/:user_id/games", auth.RequireToken(tokenService), gameHandler.HandleGetUserGames)
+
+	return &RouterImpl{Engine: router}
+}
+
+// routes for the operators of the instance, served on the internal address only
+func NewInternalRouter(websocketHander sockets.WebsocketHandler) *RouterImpl {
+	router := gin.Default()
+
+	v1 := router.Group("v1")
+	v1.GET("ws/stats", websocketHander.HandleGetStats)
+
 	return &RouterImpl{Engine: router}
 }
 
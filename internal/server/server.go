@@ -15,8 +15,17 @@ type ServerImpl struct {
 }
 
 func Serve(router router.Router) {
+	listen(":8080", router)
+}
+
+// serves the internal routes in the background, the address shouldn't be exposed outside of the deployment
+func ServeInternal(router router.Router, addr string) {
+	go listen(addr, router)
+}
+
+func listen(addr string, router router.Router) {
 	server := &http.Server{
-		Addr:         ":8080",
+		Addr:         addr,
 		Handler:      router.Handler(),
 		IdleTimeout:  time.Minute,
 		ReadTimeout:  30 * time.Second,
@@ -24,6 +33,6 @@ func Serve(router router.Router) {
 	}
 
 	if err := server.ListenAndServe(); err != nil {
-		log.Fatalf("Cannot start server: %s", err)
+		log.Fatalf("Cannot start server: addr=%s, err=%s", addr, err)
 	}
 }
@@ -0,0 +1,102 @@
+package sockets
+
+import (
+	"encoding/json"
+	"log"
+	"sync"
+)
+
+// topic of the broker all instances of the server exchange websocket messages on
+const BACKPLANE_TOPIC = "websocket_messages"
+
+/*
+delivers messages to users connected to other instances of the server.
+the messages published by an instance are not delivered back to it
+*/
+type Backplane interface {
+	Publish(userId string, message *WebsocketMessage) error
+	Subscribe(deliver func(userId string, message *WebsocketMessage)) error
+}
+
+// adapter of a pub/sub broker, e.g. redis or nats, every subscriber of the topic gets every message
+type MessageBroker interface {
+	Publish(topic string, data []byte) error
+	Subscribe(topic string, handler func(data []byte)) error
+}
+
+type backplaneEnvelope struct {
+	InstanceId string            `json:"instance_id"`
+	UserId     string            `json:"user_id"`
+	Message    *WebsocketMessage `json:"message"`
+	Delta      *WebsocketMessage `json:"delta,omitempty"` // the connections on the other instances choose between the two
+}
+
+type BrokerBackplane struct {
+	instanceId string
+	broker     MessageBroker
+}
+
+func NewBrokerBackplane(instanceId string, broker MessageBroker) *BrokerBackplane {
+	return &BrokerBackplane{
+		instanceId: instanceId,
+		broker:     broker,
+	}
+}
+
+func (b *BrokerBackplane) Publish(userId string, message *WebsocketMessage) error {
+	data, err := json.Marshal(backplaneEnvelope{InstanceId: b.instanceId, UserId: userId, Message: message, Delta: message.Delta})
+	if err != nil {
+		log.Printf("Failed to marshal backplane message: err=%v", err)
+		return err
+	}
+
+	return b.broker.Publish(BACKPLANE_TOPIC, data)
+}
+
+func (b *BrokerBackplane) Subscribe(deliver func(userId string, message *WebsocketMessage)) error {
+	return b.broker.Subscribe(BACKPLANE_TOPIC, func(data []byte) {
+		envelope := backplaneEnvelope{}
+		if err := json.Unmarshal(data, &envelope); err != nil {
+			log.Printf("Failed to unmarshal backplane message: err=%v", err)
+			return
+		}
+
+		if envelope.InstanceId == b.instanceId || envelope.Message == nil {
+			return
+		}
+		envelope.Message.Delta = envelope.Delta
+		deliver(envelope.UserId, envelope.Message)
+	})
+}
+
+// broker of a single process, used when the server runs as one instance and to connect instances in tests
+type InProcessBroker struct {
+	mutex    sync.RWMutex
+	handlers map[string][]func(data []byte)
+}
+
+func NewInProcessBroker() *InProcessBroker {
+	return &InProcessBroker{
+		handlers: map[string][]func(data []byte){},
+	}
+}
+
+// handlers are called synchronously, like the handlers of the events service
+func (b *InProcessBroker) Publish(topic string, data []byte) error {
+	b.mutex.RLock()
+	handlers := b.handlers[topic]
+	b.mutex.RUnlock()
+
+	for _, handler := range handlers {
+		handler(data)
+	}
+	return nil
+}
+
+func (b *InProcessBroker) Subscribe(topic string, handler func(data []byte)) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.handlers[topic] = append(b.handlers[topic], handler)
+	return nil
+}
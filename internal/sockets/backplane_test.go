@@ -0,0 +1,65 @@
+package sockets
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBrokerBackplane_Publish(t *testing.T) {
+	broker := NewInProcessBroker()
+	backplane1 := NewBrokerBackplane("instance1", broker)
+	backplane2 := NewBrokerBackplane("instance2", broker)
+
+	received1 := []string{}
+	received2 := []string{}
+	backplane1.Subscribe(func(userId string, message *WebsocketMessage) {
+		received1 = append(received1, userId)
+	})
+	backplane2.Subscribe(func(userId string, message *WebsocketMessage) {
+		received2 = append(received2, userId)
+		assert.Equal(t, EventTypeGameState, message.Type)
+		assert.JSONEq(t, `{"game_id":"game1"}`, string(message.Payload))
+	})
+
+	err := backplane1.Publish("user1", &WebsocketMessage{Type: EventTypeGameState, Payload: []byte(`{"game_id":"game1"}`)})
+
+	assert.NoError(t, err)
+	assert.Empty(t, received1)
+	assert.Equal(t, []string{"user1"}, received2)
+}
+
+func TestBrokerBackplane_givenInvalidMessage_shouldIgnoreIt(t *testing.T) {
+	broker := NewInProcessBroker()
+	backplane := NewBrokerBackplane("instance1", broker)
+
+	called := false
+	backplane.Subscribe(func(userId string, message *WebsocketMessage) {
+		called = true
+	})
+
+	err := broker.Publish(BACKPLANE_TOPIC, []byte("not json"))
+
+	assert.NoError(t, err)
+	assert.False(t, called)
+}
+
+func TestBrokerBackplane_givenDelta_shouldDeliverIt(t *testing.T) {
+	broker := NewInProcessBroker()
+	backplane1 := NewBrokerBackplane("instance1", broker)
+	backplane2 := NewBrokerBackplane("instance2", broker)
+
+	var received *WebsocketMessage
+	backplane2.Subscribe(func(userId string, message *WebsocketMessage) {
+		received = message
+	})
+
+	message := &WebsocketMessage{Type: EventTypeGameState, GameId: "game1", Sequence: 3}
+	message.Delta = &WebsocketMessage{Type: EventTypeMoveApplied, GameId: "game1", Sequence: 3}
+	err := backplane1.Publish("user1", message)
+
+	assert.NoError(t, err)
+	assert.Equal(t, EventTypeGameState, received.Type)
+	assert.Equal(t, EventTypeMoveApplied, received.Delta.Type)
+	assert.Equal(t, int64(3), received.Delta.Sequence)
+}
@@ -0,0 +1,72 @@
+package sockets
+
+import "sync/atomic"
+
+// counters of the websocket connections since the server started
+type ConnectionMetrics struct {
+	opened          atomic.Int64
+	closed          atomic.Int64
+	dropped         atomic.Int64
+	writeFailures   atomic.Int64
+	droppedMessages atomic.Int64
+	throttled       atomic.Int64
+	rateLimited     atomic.Int64
+}
+
+type ConnectionStats struct {
+	Open            int64 `json:"open"`
+	Opened          int64 `json:"opened"`
+	Closed          int64 `json:"closed"`
+	Dropped         int64 `json:"dropped"` // closed because the peer stopped answering pings or went away without a close frame
+	WriteFailures   int64 `json:"write_failures"`
+	DroppedMessages int64 `json:"dropped_messages"` // didn't fit in the send buffer of a slow client
+	Throttled       int64 `json:"throttled_messages"`
+	RateLimited     int64 `json:"rate_limited"` // closed because they were throttled too often
+}
+
+func NewConnectionMetrics() *ConnectionMetrics {
+	return &ConnectionMetrics{}
+}
+
+func (m *ConnectionMetrics) ConnectionOpened() {
+	m.opened.Add(1)
+}
+
+func (m *ConnectionMetrics) ConnectionClosed() {
+	m.closed.Add(1)
+}
+
+func (m *ConnectionMetrics) ConnectionDropped() {
+	m.dropped.Add(1)
+}
+
+func (m *ConnectionMetrics) WriteFailed() {
+	m.writeFailures.Add(1)
+}
+
+func (m *ConnectionMetrics) MessageDropped() {
+	m.droppedMessages.Add(1)
+}
+
+func (m *ConnectionMetrics) MessageThrottled() {
+	m.throttled.Add(1)
+}
+
+func (m *ConnectionMetrics) ConnectionRateLimited() {
+	m.rateLimited.Add(1)
+}
+
+func (m *ConnectionMetrics) Stats() ConnectionStats {
+	opened := m.opened.Load()
+	closed := m.closed.Load()
+	return ConnectionStats{
+		Open:            opened - closed,
+		Opened:          opened,
+		Closed:          closed,
+		Dropped:         m.dropped.Load(),
+		WriteFailures:   m.writeFailures.Load(),
+		DroppedMessages: m.droppedMessages.Load(),
+		Throttled:       m.throttled.Load(),
+		RateLimited:     m.rateLimited.Load(),
+	}
+}
@@ -0,0 +1,140 @@
+package sockets
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+
+	"github.com/gorilla/websocket"
+	"github.com/ugorji/go/codec"
+)
+
+/*
+websocket subprotocols of the encodings, the client asks for one in the Sec-WebSocket-Protocol header.
+a client that asks for several gets the first one of this list that it asked for, a client that asks for none gets json
+*/
+const (
+	SUBPROTOCOL_JSON    = "json"
+	SUBPROTOCOL_MSGPACK = "msgpack"
+	SUBPROTOCOL_CBOR    = "cbor"
+)
+
+var Subprotocols = []string{SUBPROTOCOL_JSON, SUBPROTOCOL_MSGPACK, SUBPROTOCOL_CBOR}
+
+/*
+encoding of the messages on the wire. the protocol versions produce json, the binary encodings transcode it,
+so every version of the protocol can be used with every encoding and the messages carry the same values
+*/
+type Encoding interface {
+	Name() string
+	// websocket.TextMessage or websocket.BinaryMessage
+	MessageType() int
+	FromJSON(data []byte) ([]byte, error)
+	ToJSON(data []byte) ([]byte, error)
+}
+
+// returns json for the empty or an unknown subprotocol
+func EncodingForSubprotocol(subprotocol string) Encoding {
+	switch subprotocol {
+	case SUBPROTOCOL_MSGPACK:
+		return &binaryEncoding{name: SUBPROTOCOL_MSGPACK, handle: msgpackHandle()}
+	case SUBPROTOCOL_CBOR:
+		return &binaryEncoding{name: SUBPROTOCOL_CBOR, handle: cborHandle()}
+	default:
+		return &jsonEncoding{}
+	}
+}
+
+type jsonEncoding struct{}
+
+func (e *jsonEncoding) Name() string {
+	return SUBPROTOCOL_JSON
+}
+
+func (e *jsonEncoding) MessageType() int {
+	return websocket.TextMessage
+}
+
+func (e *jsonEncoding) FromJSON(data []byte) ([]byte, error) {
+	return data, nil
+}
+
+func (e *jsonEncoding) ToJSON(data []byte) ([]byte, error) {
+	return data, nil
+}
+
+type binaryEncoding struct {
+	name   string
+	handle codec.Handle
+}
+
+func (e *binaryEncoding) Name() string {
+	return e.name
+}
+
+func (e *binaryEncoding) MessageType() int {
+	return websocket.BinaryMessage
+}
+
+func (e *binaryEncoding) FromJSON(data []byte) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+
+	encoded := []byte{}
+	if err := codec.NewEncoderBytes(&encoded, e.handle).Encode(fromJSONNumbers(value)); err != nil {
+		return nil, err
+	}
+	return encoded, nil
+}
+
+func (e *binaryEncoding) ToJSON(data []byte) ([]byte, error) {
+	var value interface{}
+	if err := codec.NewDecoderBytes(data, e.handle).Decode(&value); err != nil {
+		return nil, err
+	}
+	return json.Marshal(value)
+}
+
+// integers stay integers instead of becoming floats, which are both bigger and unexpected by the clients
+func fromJSONNumbers(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = fromJSONNumbers(item)
+		}
+		return v
+	case []interface{}:
+		for i, item := range v {
+			v[i] = fromJSONNumbers(item)
+		}
+		return v
+	case json.Number:
+		if number, err := v.Int64(); err == nil {
+			return number
+		}
+		number, _ := v.Float64()
+		return number
+	default:
+		return value
+	}
+}
+
+var stringMapType = reflect.TypeOf(map[string]interface{}(nil))
+
+func msgpackHandle() *codec.MsgpackHandle {
+	handle := &codec.MsgpackHandle{WriteExt: true}
+	handle.RawToString = true
+	handle.MapType = stringMapType
+	return handle
+}
+
+func cborHandle() *codec.CborHandle {
+	handle := &codec.CborHandle{}
+	handle.MapType = stringMapType
+	return handle
+}
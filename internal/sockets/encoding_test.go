@@ -0,0 +1,106 @@
+package sockets
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/ugorji/go/codec"
+)
+
+var encodingTestMessages = []*WebsocketMessage{
+	{Type: EventTypeStartGame, Payload: []byte(`{"ranked":true,"variant":"standard","time_control":{"initial_seconds":300,"increment_seconds":2}}`), RequestId: "r1"},
+	{Type: EventTypeMakeMove, Payload: []byte(`{"game_id":"game1","position":{"x":4,"y":-1}}`)},
+	{Type: EventTypeMoveApplied, Payload: []byte(`{"game_id":"game1","moves":[{"type":"wall","wall":{"x":1,"y":2,"direction":"vertical"}}],"moves_count":12,"turn":"user1","rating":1523.5,"winner":null}`), GameId: "game1", Sequence: 7},
+	{Type: EventTypeQueueStatus, Payload: []byte(`{"ranked":false,"waited_seconds":0,"players_in_pool":1}`)},
+	{Type: EventTypeError, Payload: []byte(`{"error_type":"unsupported_version","supported_versions":[1,2]}`), RequestId: "r2"},
+}
+
+func TestEncodings_shouldCarrySameMessagesAsJSON(t *testing.T) {
+	for _, subprotocol := range Subprotocols {
+		encoding := EncodingForSubprotocol(subprotocol)
+
+		for _, version := range SupportedProtocolVersions {
+			protocol, _ := ProtocolForVersion(version)
+
+			for _, message := range encodingTestMessages {
+				expected, err := protocol.Encode(message)
+				assert.NoError(t, err)
+
+				encoded, err := encoding.FromJSON(expected)
+				assert.NoError(t, err)
+
+				decoded, err := encoding.ToJSON(encoded)
+				assert.NoError(t, err)
+				assert.JSONEq(t, string(expected), string(decoded), "subprotocol=%v, version=%v", subprotocol, version)
+
+				received, err := protocol.Decode(decoded)
+				assert.NoError(t, err)
+				assert.Equal(t, message.Type, received.Type)
+				assert.JSONEq(t, string(message.Payload), string(received.Payload))
+				assert.Equal(t, message.RequestId, received.RequestId)
+				assert.Equal(t, message.GameId, received.GameId)
+				assert.Equal(t, message.Sequence, received.Sequence)
+			}
+		}
+	}
+}
+
+func TestEncodings_shouldBeSmallerThanJSON(t *testing.T) {
+	protocol, _ := ProtocolForVersion(DEFAULT_PROTOCOL_VERSION)
+
+	for _, subprotocol := range []string{SUBPROTOCOL_MSGPACK, SUBPROTOCOL_CBOR} {
+		encoding := EncodingForSubprotocol(subprotocol)
+		assert.Equal(t, websocket.BinaryMessage, encoding.MessageType())
+
+		for _, message := range encodingTestMessages {
+			data, err := protocol.Encode(message)
+			assert.NoError(t, err)
+
+			encoded, err := encoding.FromJSON(data)
+			assert.NoError(t, err)
+			assert.Less(t, len(encoded), len(data))
+		}
+	}
+}
+
+func TestMsgpackEncoding_shouldKeepIntegers(t *testing.T) {
+	encoding := EncodingForSubprotocol(SUBPROTOCOL_MSGPACK)
+
+	encoded, err := encoding.FromJSON([]byte(`{"moves_count":12,"rating":1523.5}`))
+	assert.NoError(t, err)
+
+	value := map[string]interface{}{}
+	assert.NoError(t, codec.NewDecoderBytes(encoded, msgpackHandle()).Decode(&value))
+	assert.Equal(t, int64(12), value["moves_count"])
+	assert.Equal(t, 1523.5, value["rating"])
+}
+
+func TestEncodingForSubprotocol_givenUnknownSubprotocol_shouldUseJSON(t *testing.T) {
+	for _, subprotocol := range []string{"", SUBPROTOCOL_JSON, "xml"} {
+		encoding := EncodingForSubprotocol(subprotocol)
+		assert.Equal(t, SUBPROTOCOL_JSON, encoding.Name())
+		assert.Equal(t, websocket.TextMessage, encoding.MessageType())
+
+		data := []byte(`{"event":"ack"}`)
+		encoded, err := encoding.FromJSON(data)
+		assert.NoError(t, err)
+		assert.Equal(t, data, encoded)
+	}
+}
+
+func decodeMsgpack(t *testing.T, data []byte) string {
+	decoded, err := EncodingForSubprotocol(SUBPROTOCOL_MSGPACK).ToJSON(data)
+	assert.NoError(t, err)
+	return string(decoded)
+}
+
+func encodeMsgpack(t *testing.T, message interface{}) []byte {
+	data, err := json.Marshal(message)
+	assert.NoError(t, err)
+
+	encoded, err := EncodingForSubprotocol(SUBPROTOCOL_MSGPACK).FromJSON(data)
+	assert.NoError(t, err)
+	return encoded
+}
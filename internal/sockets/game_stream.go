@@ -0,0 +1,135 @@
+package sockets
+
+import (
+	"sync"
+	"time"
+)
+
+// number of the latest messages of a game kept for the clients that resume after a short disconnect
+const GAME_STREAM_BUFFER = 64
+
+/*
+streams of the games without messages for this long are forgotten, e.g. abandoned or archived games that never finished here.
+a client resuming such a game gets the full state, like after a restart
+*/
+const (
+	GAME_STREAM_IDLE_TTL       = time.Hour
+	GAME_STREAM_PRUNE_INTERVAL = time.Minute
+)
+
+/*
+numbers the outbound messages of every game in the order they are sent, starting from 1.
+messages sent by other instances are recorded with their numbers, so the numbers keep growing whichever instance sends the next one
+*/
+type GameStreams struct {
+	mutex     sync.Mutex
+	streams   map[string]*gameStream
+	lastPrune time.Time
+	now       func() time.Time
+}
+
+type gameStream struct {
+	lastSequence int64
+	buffer       []*WebsocketMessage
+	updated      time.Time
+}
+
+func NewGameStreams() *GameStreams {
+	return &GameStreams{
+		streams: map[string]*gameStream{},
+		now:     time.Now,
+	}
+}
+
+// sets the game id and the next sequence number of the game on the message
+func (gs *GameStreams) Append(gameId string, message *WebsocketMessage) {
+	gs.mutex.Lock()
+	defer gs.mutex.Unlock()
+
+	stream := gs.stream(gameId, gs.now())
+	message.GameId = gameId
+	message.Sequence = stream.lastSequence + 1
+	stream.add(message)
+}
+
+// records a message numbered by another instance, messages that are already known are ignored
+func (gs *GameStreams) Record(message *WebsocketMessage) {
+	gs.mutex.Lock()
+	defer gs.mutex.Unlock()
+
+	stream := gs.stream(message.GameId, gs.now())
+	if message.Sequence > stream.lastSequence {
+		stream.add(message)
+	}
+}
+
+// returns 0 when nothing was sent in the game yet
+func (gs *GameStreams) LastSequence(gameId string) int64 {
+	gs.mutex.Lock()
+	defer gs.mutex.Unlock()
+
+	if stream, ok := gs.streams[gameId]; ok {
+		return stream.lastSequence
+	}
+	return 0
+}
+
+// returns false when some of the messages after the sequence are no longer in the buffer
+func (gs *GameStreams) Since(gameId string, sequence int64) ([]*WebsocketMessage, bool) {
+	gs.mutex.Lock()
+	defer gs.mutex.Unlock()
+
+	stream, ok := gs.streams[gameId]
+	if !ok || sequence > stream.lastSequence {
+		return nil, false
+	}
+
+	messages := []*WebsocketMessage{}
+	for _, message := range stream.buffer {
+		if message.Sequence > sequence {
+			messages = append(messages, message)
+		}
+	}
+
+	missing := stream.lastSequence - sequence
+	return messages, int64(len(messages)) == missing
+}
+
+func (gs *GameStreams) Remove(gameId string) {
+	gs.mutex.Lock()
+	defer gs.mutex.Unlock()
+
+	delete(gs.streams, gameId)
+}
+
+// must be called while holding the mutex, the idle streams are pruned at most once per GAME_STREAM_PRUNE_INTERVAL
+func (gs *GameStreams) stream(gameId string, now time.Time) *gameStream {
+	if now.Sub(gs.lastPrune) >= GAME_STREAM_PRUNE_INTERVAL {
+		gs.prune(now)
+	}
+
+	stream, ok := gs.streams[gameId]
+	if !ok {
+		stream = &gameStream{}
+		gs.streams[gameId] = stream
+	}
+	stream.updated = now
+	return stream
+}
+
+func (gs *GameStreams) prune(now time.Time) {
+	for gameId, stream := range gs.streams {
+		if now.Sub(stream.updated) >= GAME_STREAM_IDLE_TTL {
+			delete(gs.streams, gameId)
+		}
+	}
+	gs.lastPrune = now
+}
+
+func (s *gameStream) add(message *WebsocketMessage) {
+	s.lastSequence = message.Sequence
+	s.buffer = append(s.buffer, message)
+	if len(s.buffer) > GAME_STREAM_BUFFER {
+		s.buffer = s.buffer[len(s.buffer)-GAME_STREAM_BUFFER:]
+	}
+}
@@ -0,0 +1,96 @@
+package sockets
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGameStreams_Append(t *testing.T) {
+	streams := NewGameStreams()
+
+	message1 := &WebsocketMessage{Type: EventTypeGameState}
+	message2 := &WebsocketMessage{Type: EventTypeGameState}
+	other := &WebsocketMessage{Type: EventTypeGameState}
+	streams.Append("game1", message1)
+	streams.Append("game1", message2)
+	streams.Append("game2", other)
+
+	assert.Equal(t, int64(1), message1.Sequence)
+	assert.Equal(t, int64(2), message2.Sequence)
+	assert.Equal(t, "game1", message2.GameId)
+	assert.Equal(t, int64(1), other.Sequence)
+	assert.Equal(t, int64(2), streams.LastSequence("game1"))
+}
+
+func TestGameStreams_Since(t *testing.T) {
+	streams := NewGameStreams()
+	for i := 0; i < 3; i++ {
+		streams.Append("game1", &WebsocketMessage{Type: EventTypeGameState})
+	}
+
+	missed, ok := streams.Since("game1", 1)
+	assert.True(t, ok)
+	assert.Len(t, missed, 2)
+	assert.Equal(t, int64(2), missed[0].Sequence)
+
+	missed, ok = streams.Since("game1", 3)
+	assert.True(t, ok)
+	assert.Empty(t, missed)
+
+	_, ok = streams.Since("game1", 4)
+	assert.False(t, ok)
+
+	_, ok = streams.Since("game2", 0)
+	assert.False(t, ok)
+}
+
+func TestGameStreams_Since_givenEvictedMessages_shouldReturnFalse(t *testing.T) {
+	streams := NewGameStreams()
+	for i := 0; i < GAME_STREAM_BUFFER+2; i++ {
+		streams.Append("game1", &WebsocketMessage{Type: EventTypeGameState})
+	}
+
+	_, ok := streams.Since("game1", 1)
+	assert.False(t, ok)
+
+	missed, ok := streams.Since("game1", 2)
+	assert.True(t, ok)
+	assert.Len(t, missed, GAME_STREAM_BUFFER)
+}
+
+func TestGameStreams_Record(t *testing.T) {
+	streams := NewGameStreams()
+	streams.Append("game1", &WebsocketMessage{Type: EventTypeGameState})
+
+	streams.Record(&WebsocketMessage{Type: EventTypeGameState, GameId: "game1", Sequence: 2})
+	streams.Record(&WebsocketMessage{Type: EventTypeGameState, GameId: "game1", Sequence: 2})
+
+	next := &WebsocketMessage{Type: EventTypeGameState}
+	streams.Append("game1", next)
+
+	assert.Equal(t, int64(3), next.Sequence)
+	missed, ok := streams.Since("game1", 0)
+	assert.True(t, ok)
+	assert.Len(t, missed, 3)
+}
+
+func TestGameStreams_givenIdleStream_shouldForgetIt(t *testing.T) {
+	now := time.Now()
+	streams := NewGameStreams()
+	streams.now = func() time.Time { return now }
+
+	streams.Append("game1", &WebsocketMessage{Type: EventTypeGameState})
+	streams.Append("game2", &WebsocketMessage{Type: EventTypeGameState})
+
+	now = now.Add(GAME_STREAM_IDLE_TTL / 2)
+	streams.Record(&WebsocketMessage{Type: EventTypeGameState, GameId: "game2", Sequence: 2})
+
+	now = now.Add(GAME_STREAM_IDLE_TTL / 2)
+	streams.Append("game3", &WebsocketMessage{Type: EventTypeGameState})
+
+	assert.Equal(t, int64(0), streams.LastSequence("game1"))
+	assert.Equal(t, int64(2), streams.LastSequence("game2"))
+	assert.Len(t, streams.streams, 2)
+}
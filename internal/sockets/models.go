@@ -9,20 +9,56 @@ type EventType string
 
 const (
 	// IN
-	EventTypeStartGame EventType = "start_game"
-	EventTypeMakeMove  EventType = "make_move"
-	EventTypePlaceWall EventType = "place_wall"
-	EventTypeResign    EventType = "resign"
-	EventTypeReconnect EventType = "reconnect"
+	EventTypeStartGame         EventType = "start_game"
+	EventTypeCancelMatchmaking EventType = "cancel_matchmaking"
+	EventTypeMakeMove          EventType = "make_move"
+	EventTypePlaceWall         EventType = "place_wall"
+	EventTypeResign            EventType = "resign"
+	EventTypeReconnect         EventType = "reconnect"
+	EventTypeResume            EventType = "resume"
+	EventTypeHello             EventType = "hello" // switches the protocol version of the connection, answered with hello
 
 	// OUT
-	EventTypeGameState EventType = "game_state"
-	EventTypeError     EventType = "error"
+	EventTypeGameState            EventType = "game_state"
+	EventTypeMoveApplied          EventType = "move_applied" // instead of game_state for the connections that asked for deltas
+	EventTypeQueueJoined          EventType = "queue_joined"
+	EventTypeQueueStatus          EventType = "queue_status"
+	EventTypeMatchmakingCancelled EventType = "matchmaking_cancelled"
+	EventTypeSessionReplaced      EventType = "session_replaced" // the connection is closed after it
+	EventTypeAck                  EventType = "ack"              // the command was accepted
+	EventTypeError                EventType = "error"
 )
 
 type WebsocketMessage struct {
 	Type    EventType       `json:"event"`
 	Payload json.RawMessage `json:"payload,omitempty"`
+	// optional id set by the client, replies to the message carry the same id
+	RequestId string `json:"request_id,omitempty"`
+	// messages of a game are numbered per game, the client resumes from the last number it saw
+	GameId   string `json:"game_id,omitempty"`
+	Sequence int64  `json:"seq,omitempty"`
+
+	// move_applied version of a game_state, sent instead of it to the connections that asked for deltas
+	Delta *WebsocketMessage `json:"-"`
+}
+
+// all fields are optional, the default game settings are used for the missing ones
+type StartGamePayload struct {
+	Ranked      bool             `json:"ranked"`
+	Variant     game.GameVariant `json:"variant,omitempty"`
+	TimeControl game.TimeControl `json:"time_control,omitempty"`
+	RematchWith string           `json:"rematch_with,omitempty"` // id of the recent opponent
+}
+
+// payload of both queue_joined and queue_status
+type QueueStatusPayload struct {
+	Ranked        bool             `json:"ranked"`
+	Variant       game.GameVariant `json:"variant"`
+	TimeControl   game.TimeControl `json:"time_control"`
+	WaitedSeconds int              `json:"waited_seconds"`
+	PlayersInPool int              `json:"players_in_pool"`
+	// missing until somebody is matched in the pool
+	EstimatedWaitSeconds *int `json:"estimated_wait_seconds,omitempty"`
 }
 
 type MakeMovePayload struct {
@@ -43,6 +79,37 @@ type ReconnectPayload struct {
 	GameId string `json:"game_id"`
 }
 
+/*
+changes made by one command, i.e. the move of the player and the reply of the bot in bot games.
+the full state is still sent when the game starts, ends, or the player reconnects
+*/
+type MoveAppliedPayload struct {
+	GameId       string          `json:"game_id"`
+	Moves        []*game.Move    `json:"moves"`
+	MovesCount   int             `json:"moves_count"` // moves in the game including these, tells the client it missed some
+	Turn         string          `json:"turn"`
+	Player1Walls int             `json:"player_1_walls"`
+	Player2Walls int             `json:"player_2_walls"`
+	Status       game.GameStatus `json:"status"`
+}
+
+type ResumePayload struct {
+	GameId       string `json:"game_id"`
+	LastSequence int64  `json:"last_seq"`
+}
+
+type AckPayload struct {
+	Event EventType `json:"event"` // type of the accepted command
+}
+
+type HelloPayload struct {
+	Version int `json:"version"`
+	// only in the replies of the server
+	SupportedVersions []int `json:"supported_versions,omitempty"`
+}
+
 type ErrorMessagePayload struct {
 	ErrorType string `json:"error_type"`
+	// set with unsupported_version
+	SupportedVersions []int `json:"supported_versions,omitempty"`
 }
@@ -0,0 +1,119 @@
+package sockets
+
+import (
+	"encoding/json"
+	"strconv"
+)
+
+/*
+versions of the websocket protocol, the clients that can't be updated at once keep the version they were built for.
+  - 1: {"event", "payload", "request_id"} envelope, full game_state after every move unless deltas are requested
+  - 2: {"type", "data", "id"} envelope, move_applied deltas after moves
+*/
+const (
+	PROTOCOL_VERSION_1 = 1
+	PROTOCOL_VERSION_2 = 2
+
+	// used by the clients that don't declare a version
+	DEFAULT_PROTOCOL_VERSION = PROTOCOL_VERSION_1
+)
+
+var SupportedProtocolVersions = []int{PROTOCOL_VERSION_1, PROTOCOL_VERSION_2}
+
+// encodes and decodes the messages of one version of the protocol
+type Protocol interface {
+	Version() int
+	Encode(message *WebsocketMessage) ([]byte, error)
+	Decode(data []byte) (*WebsocketMessage, error)
+	DeltaUpdates() bool
+}
+
+// returns false when the version is not supported
+func ProtocolForVersion(version int) (Protocol, bool) {
+	switch version {
+	case PROTOCOL_VERSION_1:
+		return &protocolV1{}, true
+	case PROTOCOL_VERSION_2:
+		return &protocolV2{}, true
+	default:
+		return nil, false
+	}
+}
+
+// an empty version means the default one, anything that is not a number is not supported
+func ParseProtocolVersion(version string) (Protocol, bool) {
+	if version == "" {
+		return ProtocolForVersion(DEFAULT_PROTOCOL_VERSION)
+	}
+
+	number, err := strconv.Atoi(version)
+	if err != nil {
+		return nil, false
+	}
+	return ProtocolForVersion(number)
+}
+
+type protocolV1 struct{}
+
+func (p *protocolV1) Version() int {
+	return PROTOCOL_VERSION_1
+}
+
+func (p *protocolV1) Encode(message *WebsocketMessage) ([]byte, error) {
+	return json.Marshal(message)
+}
+
+func (p *protocolV1) Decode(data []byte) (*WebsocketMessage, error) {
+	message := &WebsocketMessage{}
+	if err := json.Unmarshal(data, message); err != nil {
+		return nil, err
+	}
+	return message, nil
+}
+
+func (p *protocolV1) DeltaUpdates() bool {
+	return false
+}
+
+type websocketMessageV2 struct {
+	Type      EventType       `json:"type"`
+	Data      json.RawMessage `json:"data,omitempty"`
+	RequestId string          `json:"id,omitempty"`
+	GameId    string          `json:"game_id,omitempty"`
+	Sequence  int64           `json:"seq,omitempty"`
+}
+
+type protocolV2 struct{}
+
+func (p *protocolV2) Version() int {
+	return PROTOCOL_VERSION_2
+}
+
+func (p *protocolV2) Encode(message *WebsocketMessage) ([]byte, error) {
+	return json.Marshal(websocketMessageV2{
+		Type:      message.Type,
+		Data:      message.Payload,
+		RequestId: message.RequestId,
+		GameId:    message.GameId,
+		Sequence:  message.Sequence,
+	})
+}
+
+func (p *protocolV2) Decode(data []byte) (*WebsocketMessage, error) {
+	wire := websocketMessageV2{}
+	if err := json.Unmarshal(data, &wire); err != nil {
+		return nil, err
+	}
+
+	return &WebsocketMessage{
+		Type:      wire.Type,
+		Payload:   wire.Data,
+		RequestId: wire.RequestId,
+		GameId:    wire.GameId,
+		Sequence:  wire.Sequence,
+	}, nil
+}
+
+func (p *protocolV2) DeltaUpdates() bool {
+	return true
+}
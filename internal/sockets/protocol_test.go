@@ -0,0 +1,70 @@
+package sockets
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProtocolV1_shouldEncodeAndDecodeMessage(t *testing.T) {
+	protocol, ok := ProtocolForVersion(PROTOCOL_VERSION_1)
+	assert.True(t, ok)
+
+	message := &WebsocketMessage{Type: EventTypeGameState, Payload: []byte(`{"id":"game1"}`), RequestId: "r1", GameId: "game1", Sequence: 3}
+
+	data, err := protocol.Encode(message)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"event":"game_state","payload":{"id":"game1"},"request_id":"r1","game_id":"game1","seq":3}`, string(data))
+
+	decoded, err := protocol.Decode(data)
+	assert.NoError(t, err)
+	assert.Equal(t, message, decoded)
+	assert.False(t, protocol.DeltaUpdates())
+}
+
+func TestProtocolV2_shouldEncodeAndDecodeMessage(t *testing.T) {
+	protocol, ok := ProtocolForVersion(PROTOCOL_VERSION_2)
+	assert.True(t, ok)
+
+	message := &WebsocketMessage{Type: EventTypeMoveApplied, Payload: []byte(`{"game_id":"game1"}`), RequestId: "r1", GameId: "game1", Sequence: 3}
+
+	data, err := protocol.Encode(message)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"type":"move_applied","data":{"game_id":"game1"},"id":"r1","game_id":"game1","seq":3}`, string(data))
+
+	decoded, err := protocol.Decode(data)
+	assert.NoError(t, err)
+	assert.Equal(t, message, decoded)
+	assert.True(t, protocol.DeltaUpdates())
+}
+
+func TestParseProtocolVersion(t *testing.T) {
+	protocol, ok := ParseProtocolVersion("")
+	assert.True(t, ok)
+	assert.Equal(t, DEFAULT_PROTOCOL_VERSION, protocol.Version())
+
+	protocol, ok = ParseProtocolVersion("2")
+	assert.True(t, ok)
+	assert.Equal(t, PROTOCOL_VERSION_2, protocol.Version())
+
+	for _, version := range []string{"0", "3", "v1"} {
+		_, ok = ParseProtocolVersion(version)
+		assert.False(t, ok)
+	}
+}
+
+func TestSend_givenProtocolWithDeltaUpdates_shouldSendDelta(t *testing.T) {
+	protocol, _ := ProtocolForVersion(PROTOCOL_VERSION_2)
+	client := &Client{
+		userId:   "user1",
+		messages: make(chan *WebsocketMessage, 1),
+		protocol: protocol,
+	}
+
+	delta := &WebsocketMessage{Type: EventTypeMoveApplied}
+	assert.True(t, client.Send(&WebsocketMessage{Type: EventTypeGameState, RequestId: "r1", Delta: delta}))
+
+	sent := <-client.messages
+	assert.Equal(t, EventTypeMoveApplied, sent.Type)
+	assert.Equal(t, "r1", sent.RequestId)
+}
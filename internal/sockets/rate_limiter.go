@@ -0,0 +1,162 @@
+package sockets
+
+import (
+	"sync"
+	"time"
+)
+
+// how often the buckets of the users that stopped sending are forgotten
+const RATE_LIMITER_PRUNE_INTERVAL = time.Minute
+
+/*
+limits of the messages sent by the clients, every message takes a token from the bucket of the connection and of the user.
+a connection that gets throttled more than MaxViolations times a minute is closed
+*/
+type RateLimitConfig struct {
+	MessagesPerSecond     float64
+	Burst                 int
+	UserMessagesPerSecond float64 // shared by all connections of the user
+	UserBurst             int
+	MaxViolations         int
+	MaxMessageSize        int64 // bytes, bigger messages close the connection
+}
+
+type RateLimitVerdict int
+
+const (
+	RateLimitAllowed RateLimitVerdict = iota
+	RateLimitThrottled
+	RateLimitExceeded // throttled too often, the connection should be closed
+)
+
+// starts full, refills continuously up to the capacity
+type TokenBucket struct {
+	mutex    sync.Mutex
+	capacity float64
+	rate     float64 // tokens per second
+	tokens   float64
+	updated  time.Time
+}
+
+func NewTokenBucket(capacity int, rate float64, now time.Time) *TokenBucket {
+	return &TokenBucket{
+		capacity: float64(capacity),
+		rate:     rate,
+		tokens:   float64(capacity),
+		updated:  now,
+	}
+}
+
+func (b *TokenBucket) Allow(now time.Time) bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.refill(now)
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// checks for a token without taking it
+func (b *TokenBucket) Available(now time.Time) bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.refill(now)
+	return b.tokens >= 1
+}
+
+// a full bucket behaves like a new one, so it can be forgotten
+func (b *TokenBucket) Full(now time.Time) bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.refill(now)
+	return b.tokens >= b.capacity
+}
+
+func (b *TokenBucket) refill(now time.Time) {
+	if elapsed := now.Sub(b.updated).Seconds(); elapsed > 0 {
+		b.tokens = min(b.capacity, b.tokens+elapsed*b.rate)
+		b.updated = now
+	}
+}
+
+// keeps the buckets of the users, the buckets of the connections are created for each of them
+type RateLimiter struct {
+	config    RateLimitConfig
+	mutex     sync.Mutex
+	users     map[string]*TokenBucket
+	lastPrune time.Time
+}
+
+func NewRateLimiter(config RateLimitConfig) *RateLimiter {
+	return &RateLimiter{
+		config: config,
+		users:  map[string]*TokenBucket{},
+	}
+}
+
+func (l *RateLimiter) NewConnectionLimiter(userId string, now time.Time) *ConnectionRateLimiter {
+	return &ConnectionRateLimiter{
+		userId:     userId,
+		limiter:    l,
+		messages:   NewTokenBucket(l.config.Burst, l.config.MessagesPerSecond, now),
+		violations: NewTokenBucket(l.config.MaxViolations, float64(l.config.MaxViolations)/time.Minute.Seconds(), now),
+	}
+}
+
+func (l *RateLimiter) MaxMessageSize() int64 {
+	return l.config.MaxMessageSize
+}
+
+func (l *RateLimiter) allowUser(userId string, now time.Time) bool {
+	l.mutex.Lock()
+	if now.Sub(l.lastPrune) >= RATE_LIMITER_PRUNE_INTERVAL {
+		l.prune(now)
+	}
+
+	bucket, ok := l.users[userId]
+	if !ok {
+		bucket = NewTokenBucket(l.config.UserBurst, l.config.UserMessagesPerSecond, now)
+		l.users[userId] = bucket
+	}
+	l.mutex.Unlock()
+
+	return bucket.Allow(now)
+}
+
+// must be called while holding the mutex
+func (l *RateLimiter) prune(now time.Time) {
+	for userId, bucket := range l.users {
+		if bucket.Full(now) {
+			delete(l.users, userId)
+		}
+	}
+	l.lastPrune = now
+}
+
+type ConnectionRateLimiter struct {
+	userId     string
+	limiter    *RateLimiter
+	messages   *TokenBucket
+	violations *TokenBucket
+}
+
+/*
+the message takes a token from both the connection and the user only when both have one.
+the connection's bucket is used only by the goroutine that reads its messages, so its token can't be taken in between
+*/
+func (l *ConnectionRateLimiter) Allow(now time.Time) RateLimitVerdict {
+	if l.messages.Available(now) && l.limiter.allowUser(l.userId, now) {
+		l.messages.Allow(now)
+		return RateLimitAllowed
+	}
+
+	if l.violations.Allow(now) {
+		return RateLimitThrottled
+	}
+	return RateLimitExceeded
+}
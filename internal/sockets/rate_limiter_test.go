@@ -0,0 +1,88 @@
+package sockets
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenBucket_shouldAllowBurstAndRefill(t *testing.T) {
+	now := time.Now()
+	bucket := NewTokenBucket(2, 1, now)
+
+	assert.True(t, bucket.Allow(now))
+	assert.True(t, bucket.Allow(now))
+	assert.False(t, bucket.Allow(now))
+
+	assert.False(t, bucket.Allow(now.Add(500*time.Millisecond)))
+	assert.True(t, bucket.Allow(now.Add(time.Second)))
+	assert.False(t, bucket.Full(now.Add(time.Second)))
+
+	// the bucket doesn't fill over the capacity
+	assert.True(t, bucket.Full(now.Add(time.Hour)))
+	assert.True(t, bucket.Allow(now.Add(time.Hour)))
+	assert.True(t, bucket.Allow(now.Add(time.Hour)))
+	assert.False(t, bucket.Allow(now.Add(time.Hour)))
+}
+
+func TestConnectionRateLimiter_givenConnectionLimitReached_shouldThrottleAndThenExceed(t *testing.T) {
+	now := time.Now()
+	limiter := NewRateLimiter(RateLimitConfig{MessagesPerSecond: 1, Burst: 2, UserMessagesPerSecond: 10, UserBurst: 10, MaxViolations: 2})
+	connection := limiter.NewConnectionLimiter("user1", now)
+
+	assert.Equal(t, RateLimitAllowed, connection.Allow(now))
+	assert.Equal(t, RateLimitAllowed, connection.Allow(now))
+	assert.Equal(t, RateLimitThrottled, connection.Allow(now))
+	assert.Equal(t, RateLimitThrottled, connection.Allow(now))
+	assert.Equal(t, RateLimitExceeded, connection.Allow(now))
+
+	assert.Equal(t, RateLimitAllowed, connection.Allow(now.Add(time.Second)))
+}
+
+func TestConnectionRateLimiter_givenUserLimitReached_shouldThrottleAllConnectionsOfUser(t *testing.T) {
+	now := time.Now()
+	limiter := NewRateLimiter(RateLimitConfig{MessagesPerSecond: 10, Burst: 10, UserMessagesPerSecond: 1, UserBurst: 3, MaxViolations: 5})
+	connection1 := limiter.NewConnectionLimiter("user1", now)
+	connection2 := limiter.NewConnectionLimiter("user1", now)
+	connection3 := limiter.NewConnectionLimiter("user2", now)
+
+	assert.Equal(t, RateLimitAllowed, connection1.Allow(now))
+	assert.Equal(t, RateLimitAllowed, connection2.Allow(now))
+	assert.Equal(t, RateLimitAllowed, connection1.Allow(now))
+	assert.Equal(t, RateLimitThrottled, connection2.Allow(now))
+	assert.Equal(t, RateLimitThrottled, connection1.Allow(now))
+
+	assert.Equal(t, RateLimitAllowed, connection3.Allow(now))
+}
+
+func TestConnectionRateLimiter_givenUserLimitReached_shouldKeepConnectionTokens(t *testing.T) {
+	now := time.Now()
+	limiter := NewRateLimiter(RateLimitConfig{MessagesPerSecond: 0, Burst: 2, UserMessagesPerSecond: 1, UserBurst: 1, MaxViolations: 5})
+	connection1 := limiter.NewConnectionLimiter("user1", now)
+	connection2 := limiter.NewConnectionLimiter("user1", now)
+
+	assert.Equal(t, RateLimitAllowed, connection2.Allow(now))
+	assert.Equal(t, RateLimitThrottled, connection1.Allow(now))
+	assert.Equal(t, RateLimitThrottled, connection1.Allow(now))
+
+	// the connection doesn't refill, it still has both tokens when the user has tokens again
+	later := now.Add(time.Second)
+	assert.Equal(t, RateLimitAllowed, connection1.Allow(later))
+	assert.Equal(t, RateLimitAllowed, connection1.Allow(later.Add(time.Second)))
+}
+
+func TestRateLimiter_shouldForgetUsersWithFullBuckets(t *testing.T) {
+	now := time.Now()
+	limiter := NewRateLimiter(RateLimitConfig{MessagesPerSecond: 10, Burst: 10, UserMessagesPerSecond: 1, UserBurst: 3, MaxViolations: 5})
+
+	limiter.NewConnectionLimiter("user1", now).Allow(now)
+	limiter.NewConnectionLimiter("user2", now).Allow(now)
+	assert.Len(t, limiter.users, 2)
+
+	later := now.Add(RATE_LIMITER_PRUNE_INTERVAL)
+	limiter.NewConnectionLimiter("user2", later).Allow(later)
+
+	assert.Len(t, limiter.users, 1)
+	assert.Contains(t, limiter.users, "user2")
+}
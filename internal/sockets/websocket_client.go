@@ -2,24 +2,188 @@ package sockets
 
 import (
 	"encoding/json"
+	"errors"
 	"log"
+	"net"
+	"sync"
+	"time"
 
+	internalErrors "quoridor/internal/errors"
+
+	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
 )
 
+/*
+the server pings the client every ping interval, the client answers with a pong.
+a connection that sends nothing, not even a pong, for the pong wait is considered dead and is closed
+*/
+type HeartbeatConfig struct {
+	PingInterval time.Duration
+	PongWait     time.Duration
+	WriteWait    time.Duration
+}
+
+const CLIENT_SEND_BUFFER = 8
+
+// what happens to a message for a client whose send buffer is full because it reads too slowly
+type OverflowPolicy string
+
+const (
+	/*
+		the oldest queued game state or delta is dropped, they supersede each other so the client catches up with the next one.
+		the client is disconnected when only replies and errors are queued, they can't be dropped
+	*/
+	OverflowPolicyDropOldest OverflowPolicy = "drop_oldest"
+	OverflowPolicyDisconnect OverflowPolicy = "disconnect"
+)
+
 type Client struct {
-	userId   string
-	conn     *websocket.Conn
-	service  WebsocketService
+	userId         string
+	sessionId      string // tells apart the connections of the same user
+	guest          bool
+	conn           *websocket.Conn
+	service        WebsocketService
+	heartbeat      HeartbeatConfig
+	overflowPolicy OverflowPolicy
+	deltaUpdates   bool                   // the connection gets move_applied instead of game_state after moves
+	limiter        *ConnectionRateLimiter // no limits when nil
+	metrics        *ConnectionMetrics
+	closeOnce      sync.Once
+
+	// messages are sent and the channel is closed only while holding the mutex, so nothing is sent to a closed channel
+	mutex    sync.Mutex
 	messages chan *WebsocketMessage
+	closed   bool
+	protocol Protocol // changed by a hello message, the default version when nil
+	encoding Encoding // chosen with the subprotocol on connect, json when nil
 }
 
-func NewWebsocketClient(userId string, conn *websocket.Conn, service WebsocketService) *Client {
+func NewWebsocketClient(userId string, guest bool, conn *websocket.Conn, service WebsocketService, heartbeat HeartbeatConfig, overflowPolicy OverflowPolicy, deltaUpdates bool, protocol Protocol, encoding Encoding, limiter *ConnectionRateLimiter, metrics *ConnectionMetrics) *Client {
+	metrics.ConnectionOpened()
+
 	return &Client{
-		userId:   userId,
-		conn:     conn,
-		service:  service,
-		messages: make(chan *WebsocketMessage, 8),
+		userId:         userId,
+		sessionId:      uuid.NewString(),
+		guest:          guest,
+		conn:           conn,
+		service:        service,
+		heartbeat:      heartbeat,
+		overflowPolicy: overflowPolicy,
+		deltaUpdates:   deltaUpdates,
+		limiter:        limiter,
+		metrics:        metrics,
+		messages:       make(chan *WebsocketMessage, CLIENT_SEND_BUFFER),
+		protocol:       protocol,
+		encoding:       encoding,
+	}
+}
+
+func (c *Client) Protocol() Protocol {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.currentProtocol()
+}
+
+func (c *Client) Encoding() Encoding {
+	if c.encoding == nil {
+		return EncodingForSubprotocol(SUBPROTOCOL_JSON)
+	}
+	return c.encoding
+}
+
+// must be called while holding the mutex
+func (c *Client) currentProtocol() Protocol {
+	if c.protocol == nil {
+		protocol, _ := ProtocolForVersion(DEFAULT_PROTOCOL_VERSION)
+		return protocol
+	}
+	return c.protocol
+}
+
+func (c *Client) setProtocol(protocol Protocol) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.protocol = protocol
+}
+
+/*
+never blocks, the message is queued for the writer or handled by the overflow policy when the buffer is full.
+a dropped delta leaves a gap in the sequence of the game, so the client resumes from the last message it got.
+returns false when the message was not queued
+*/
+func (c *Client) Send(message *WebsocketMessage) bool {
+	c.mutex.Lock()
+	if c.closed {
+		c.mutex.Unlock()
+		return false
+	}
+
+	if (c.deltaUpdates || c.currentProtocol().DeltaUpdates()) && message.Delta != nil {
+		delta := *message.Delta
+		delta.RequestId = message.RequestId
+		message = &delta
+	}
+
+	select {
+	case c.messages <- message:
+		c.mutex.Unlock()
+		return true
+	default:
+	}
+
+	c.metrics.MessageDropped()
+	if c.overflowPolicy == OverflowPolicyDisconnect || !c.dropOldestStateUpdate() {
+		c.mutex.Unlock()
+		log.Printf("Send buffer overflow, disconnecting client: userId=%v, sessionId=%v", c.userId, c.sessionId)
+		c.Close()
+		return false
+	}
+	c.messages <- message
+	c.mutex.Unlock()
+
+	log.Printf("Send buffer overflow, dropped the oldest game state: userId=%v, sessionId=%v", c.userId, c.sessionId)
+	return true
+}
+
+/*
+must be called while holding the mutex. the queued messages are taken out and put back in the same order without the dropped one.
+only the writer takes messages from the channel meanwhile, so there is room for the new message when one was dropped
+*/
+func (c *Client) dropOldestStateUpdate() bool {
+	queued := []*WebsocketMessage{}
+	for len(c.messages) > 0 {
+		select {
+		case message := <-c.messages:
+			queued = append(queued, message)
+		default:
+		}
+	}
+
+	dropped := false
+	for _, message := range queued {
+		if !dropped && isStateUpdate(message) {
+			dropped = true
+			continue
+		}
+		c.messages <- message
+	}
+	return dropped
+}
+
+func isStateUpdate(message *WebsocketMessage) bool {
+	return message.Type == EventTypeGameState || message.Type == EventTypeMoveApplied
+}
+
+func (c *Client) closeMessages() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if !c.closed {
+		c.closed = true
+		close(c.messages)
 	}
 }
 
@@ -27,38 +191,171 @@ func (c *Client) ReadMessage() {
 	log.Printf("Reading messages from the webscoket client. userId=%v...", c.userId)
 	defer c.Close()
 
+	c.conn.SetReadDeadline(time.Now().Add(c.heartbeat.PongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(c.heartbeat.PongWait))
+	})
+
 	for {
 		_, message, err := c.conn.ReadMessage()
 		if err != nil {
 			log.Printf("Error while reading websocket message. Err=%v\n", err)
+			if errors.Is(err, websocket.ErrReadLimit) {
+				log.Printf("Websocket message too big: userId=%v, sessionId=%v", c.userId, c.sessionId)
+			}
+			if isConnectionDropped(err) {
+				log.Printf("Websocket connection dropped: userId=%v, sessionId=%v", c.userId, c.sessionId)
+				c.metrics.ConnectionDropped()
+			}
 			break
 		}
+		c.conn.SetReadDeadline(time.Now().Add(c.heartbeat.PongWait))
 
-		wsMessage := WebsocketMessage{}
-		if err = json.Unmarshal(message, &wsMessage); err != nil {
+		data, err := c.Encoding().ToJSON(message)
+		if err != nil {
+			log.Printf("Error while decoding websocket message. Err=%v\n", err)
+			break
+		}
+
+		wsMessage, err := c.Protocol().Decode(data)
+		if err != nil {
 			log.Printf("Error while unmarshaling websocket message. Err=%v\n", err)
 			break
 		}
 
+		if c.limiter != nil {
+			verdict := c.limiter.Allow(time.Now())
+			if verdict == RateLimitExceeded {
+				log.Printf("Rate limit exceeded too often, disconnecting client: userId=%v, sessionId=%v", c.userId, c.sessionId)
+				c.metrics.ConnectionRateLimited()
+				c.closeWithReason(websocket.ClosePolicyViolation, internalErrors.ErrRateLimited.Error())
+				break
+			}
+			if verdict == RateLimitThrottled {
+				log.Printf("Websocket message throttled: userId=%v, sessionId=%v, event=%v", c.userId, c.sessionId, wsMessage.Type)
+				c.metrics.MessageThrottled()
+				c.reply(wsMessage, EventTypeError, ErrorMessagePayload{ErrorType: internalErrors.ErrRateLimited.Error()})
+				continue
+			}
+		}
+
+		if wsMessage.Type == EventTypeHello {
+			c.handleHello(wsMessage)
+			continue
+		}
+
 		log.Printf("Received webscoket message: userId=%v", c.userId)
-		c.service.HandleMessage(c.userId, &wsMessage)
+		c.service.HandleMessage(c.userId, c.sessionId, wsMessage)
+	}
+}
+
+/*
+the protocol is switched before the reply, so the reply is encoded with the new version.
+an unsupported version is answered with an error and the connection keeps its version
+*/
+func (c *Client) handleHello(message *WebsocketMessage) {
+	payload := HelloPayload{}
+	if err := json.Unmarshal(message.Payload, &payload); err != nil {
+		log.Printf("Failed to unmarshal hello payload: userId=%v, err=%v", c.userId, err)
+		c.reply(message, EventTypeError, ErrorMessagePayload{ErrorType: internalErrors.ErrBadRequest.Error()})
+		return
 	}
+
+	protocol, ok := ProtocolForVersion(payload.Version)
+	if !ok {
+		log.Printf("Unsupported protocol version: userId=%v, version=%v", c.userId, payload.Version)
+		c.reply(message, EventTypeError, ErrorMessagePayload{ErrorType: internalErrors.ErrUnsupportedVersion.Error(), SupportedVersions: SupportedProtocolVersions})
+		return
+	}
+
+	c.setProtocol(protocol)
+	log.Printf("Switched protocol version: userId=%v, sessionId=%v, version=%v", c.userId, c.sessionId, protocol.Version())
+	c.reply(message, EventTypeHello, HelloPayload{Version: protocol.Version(), SupportedVersions: SupportedProtocolVersions})
 }
 
+func (c *Client) reply(request *WebsocketMessage, eventType EventType, payload interface{}) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		log.Printf("Failed to marshal payload: err=%v", err)
+		return
+	}
+
+	c.Send(&WebsocketMessage{Type: eventType, Payload: data, RequestId: request.RequestId})
+}
+
+// pings stop the writer from blocking forever on a dead connection, the write fails once the connection is closed
 func (c *Client) WriteMessage() {
 	log.Printf("Writing messsages to the websocket client. userId=%v...", c.userId)
 	defer c.Close()
 
-	for message := range c.messages {
-		if err := c.conn.WriteJSON(message); err != nil {
-			log.Printf("Error while sending message. Err=%v\n", err)
-			break
+	ticker := time.NewTicker(c.heartbeat.PingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case message, ok := <-c.messages:
+			if !ok {
+				return
+			}
+
+			data, err := c.encode(message)
+			if err != nil {
+				log.Printf("Error while encoding message. Err=%v\n", err)
+				continue
+			}
+
+			c.conn.SetWriteDeadline(time.Now().Add(c.heartbeat.WriteWait))
+			if err := c.conn.WriteMessage(c.Encoding().MessageType(), data); err != nil {
+				log.Printf("Error while sending message. Err=%v\n", err)
+				c.metrics.WriteFailed()
+				return
+			}
+
+			if message.Type == EventTypeSessionReplaced {
+				return
+			}
+		case <-ticker.C:
+			c.conn.SetWriteDeadline(time.Now().Add(c.heartbeat.WriteWait))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				log.Printf("Error while sending ping. Err=%v\n", err)
+				return
+			}
 		}
 	}
 }
 
+func (c *Client) encode(message *WebsocketMessage) ([]byte, error) {
+	data, err := c.Protocol().Encode(message)
+	if err != nil {
+		return nil, err
+	}
+	return c.Encoding().FromJSON(data)
+}
+
+// the close frame tells the client why it was disconnected, the connection is closed by Close
+func (c *Client) closeWithReason(code int, reason string) {
+	deadline := time.Now().Add(c.heartbeat.WriteWait)
+	if err := c.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), deadline); err != nil {
+		log.Printf("Error while sending close message. Err=%v\n", err)
+	}
+}
+
+// both the reader and the writer close the client when they stop, closing the messages stops the writer
 func (c *Client) Close() {
-	log.Printf("Closing websocket client. userId=%v...", c.userId)
-	c.conn.Close()
-	c.service.UnregisterClient(c.userId)
+	c.closeOnce.Do(func() {
+		log.Printf("Closing websocket client. userId=%v, sessionId=%v...", c.userId, c.sessionId)
+		c.closeMessages()
+		c.conn.Close()
+		c.metrics.ConnectionClosed()
+		c.service.UnregisterClient(c.userId, c.sessionId)
+	})
+}
+
+// the peer disappeared without closing the connection, or stopped answering pings
+func isConnectionDropped(err error) bool {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	return websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway)
 }
@@ -0,0 +1,171 @@
+package sockets
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+// server side of a real websocket connection, the client side is closed with the test
+func newTestConn(t *testing.T) *websocket.Conn {
+	conns := make(chan *websocket.Conn, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := newUpgrader(nil).Upgrade(w, r, nil)
+		assert.NoError(t, err)
+		conns <- conn
+	}))
+	t.Cleanup(server.Close)
+
+	peer, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	assert.NoError(t, err)
+	t.Cleanup(func() { peer.Close() })
+
+	return <-conns
+}
+
+func TestSend_givenFullBuffer_shouldDropOldestMessage(t *testing.T) {
+	metrics := NewConnectionMetrics()
+	client := &Client{
+		userId:         "user1",
+		overflowPolicy: OverflowPolicyDropOldest,
+		metrics:        metrics,
+		messages:       make(chan *WebsocketMessage, 2),
+	}
+
+	message1 := &WebsocketMessage{Type: EventTypeGameState, Payload: []byte("1")}
+	message2 := &WebsocketMessage{Type: EventTypeGameState, Payload: []byte("2")}
+	message3 := &WebsocketMessage{Type: EventTypeGameState, Payload: []byte("3")}
+
+	assert.True(t, client.Send(message1))
+	assert.True(t, client.Send(message2))
+	assert.True(t, client.Send(message3))
+
+	assert.Equal(t, message2, <-client.messages)
+	assert.Equal(t, message3, <-client.messages)
+	assert.Equal(t, int64(1), metrics.Stats().DroppedMessages)
+}
+
+func TestSend_givenFullBuffer_shouldKeepReplies(t *testing.T) {
+	client := &Client{
+		userId:         "user1",
+		overflowPolicy: OverflowPolicyDropOldest,
+		metrics:        NewConnectionMetrics(),
+		messages:       make(chan *WebsocketMessage, 3),
+	}
+
+	ack := &WebsocketMessage{Type: EventTypeAck, RequestId: "1"}
+	state1 := &WebsocketMessage{Type: EventTypeMoveApplied, Payload: []byte("1")}
+	state2 := &WebsocketMessage{Type: EventTypeGameState, Payload: []byte("2")}
+	state3 := &WebsocketMessage{Type: EventTypeGameState, Payload: []byte("3")}
+
+	assert.True(t, client.Send(ack))
+	assert.True(t, client.Send(state1))
+	assert.True(t, client.Send(state2))
+	assert.True(t, client.Send(state3))
+
+	assert.Equal(t, ack, <-client.messages)
+	assert.Equal(t, state2, <-client.messages)
+	assert.Equal(t, state3, <-client.messages)
+}
+
+func TestSend_givenFullBufferOfReplies_shouldCloseClient(t *testing.T) {
+	mockMMService := new(MockMatchmakingService)
+	mockGameService := new(MockGameService)
+	service := NewWebsocketService(mockMMService, mockGameService, NewBrokerBackplane("instance1", NewInProcessBroker()), SessionPolicyMultiple)
+	mockMMService.On("RemoveUser", "user1").Return()
+
+	client := NewWebsocketClient("user1", false, newTestConn(t), service, testHeartbeat, OverflowPolicyDropOldest, false, nil, nil, nil, NewConnectionMetrics())
+	service.RegisterClient(client)
+
+	// the writer is not running, so nothing is taken from the buffer
+	for i := 0; i < CLIENT_SEND_BUFFER; i++ {
+		assert.True(t, client.Send(&WebsocketMessage{Type: EventTypeAck}))
+	}
+
+	assert.False(t, client.Send(&WebsocketMessage{Type: EventTypeGameState}))
+
+	service.mutex.Lock()
+	_, ok := service.clients["user1"]
+	service.mutex.Unlock()
+
+	assert.False(t, ok)
+	assert.Equal(t, int64(1), client.metrics.Stats().DroppedMessages)
+}
+
+func TestSend_givenFullBufferAndDisconnectPolicy_shouldCloseClient(t *testing.T) {
+	mockMMService := new(MockMatchmakingService)
+	mockGameService := new(MockGameService)
+	service := NewWebsocketService(mockMMService, mockGameService, NewBrokerBackplane("instance1", NewInProcessBroker()), SessionPolicyMultiple)
+	mockMMService.On("RemoveUser", "user1").Return()
+
+	client := NewWebsocketClient("user1", false, newTestConn(t), service, testHeartbeat, OverflowPolicyDisconnect, false, nil, nil, nil, NewConnectionMetrics())
+	service.RegisterClient(client)
+
+	// the writer is not running, so nothing is taken from the buffer
+	for i := 0; i < CLIENT_SEND_BUFFER; i++ {
+		assert.True(t, client.Send(&WebsocketMessage{Type: EventTypeGameState}))
+	}
+
+	done := make(chan bool)
+	go func() {
+		done <- client.Send(&WebsocketMessage{Type: EventTypeGameState})
+	}()
+
+	select {
+	case sent := <-done:
+		assert.False(t, sent)
+	case <-time.After(time.Second):
+		t.Fatal("send blocked on a full buffer")
+	}
+
+	service.mutex.Lock()
+	_, ok := service.clients["user1"]
+	service.mutex.Unlock()
+
+	assert.False(t, ok)
+	assert.Equal(t, int64(1), client.metrics.Stats().DroppedMessages)
+	mockMMService.AssertCalled(t, "RemoveUser", "user1")
+}
+
+func TestSend_givenClosedClient_shouldNotSend(t *testing.T) {
+	client := &Client{
+		userId:   "user1",
+		messages: make(chan *WebsocketMessage, 1),
+	}
+	client.closeMessages()
+	client.closeMessages()
+
+	assert.False(t, client.Send(&WebsocketMessage{Type: EventTypeGameState}))
+
+	_, ok := <-client.messages
+	assert.False(t, ok)
+}
+
+func TestWriteMessage_givenClientClosed_shouldStop(t *testing.T) {
+	mockMMService := new(MockMatchmakingService)
+	mockGameService := new(MockGameService)
+	service := NewWebsocketService(mockMMService, mockGameService, NewBrokerBackplane("instance1", NewInProcessBroker()), SessionPolicyMultiple)
+	mockMMService.On("RemoveUser", "user1").Return()
+
+	client := NewWebsocketClient("user1", false, newTestConn(t), service, testHeartbeat, OverflowPolicyDropOldest, false, nil, nil, nil, NewConnectionMetrics())
+	service.RegisterClient(client)
+
+	done := make(chan struct{})
+	go func() {
+		client.WriteMessage()
+		close(done)
+	}()
+
+	client.Close()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("writer didn't stop after the client was closed")
+	}
+}
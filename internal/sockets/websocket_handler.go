@@ -3,52 +3,107 @@ package sockets
 import (
 	"log"
 	"net/http"
+	"quoridor/internal/auth"
+	"quoridor/internal/cors"
+	"quoridor/internal/errors"
+	"time"
 
 	"github.com/gin-gonic/gin"
 
 	"github.com/gorilla/websocket"
 )
 
-var upgrader = websocket.Upgrader{
-	ReadBufferSize:  1024,
-	WriteBufferSize: 1024,
-	CheckOrigin: func(r *http.Request) bool {
-		return true
-	},
+// upgrades are refused with 403 when the check of the origin fails
+func newUpgrader(checkOrigin func(r *http.Request) bool) *websocket.Upgrader {
+	return &websocket.Upgrader{
+		ReadBufferSize:  1024,
+		WriteBufferSize: 1024,
+		Subprotocols:    Subprotocols,
+		CheckOrigin:     checkOrigin,
+	}
+}
+
+type UnsupportedVersionResponse struct {
+	ErrorType         string `json:"error_type"`
+	SupportedVersions []int  `json:"supported_versions"`
 }
 
 type WebsocketHandler interface {
 	HandleWs(c *gin.Context)
+	HandleGetStats(c *gin.Context)
 }
 
 type WebsocketHandlerImpl struct {
-	service WebsocketService
+	upgrader       *websocket.Upgrader
+	service        WebsocketService
+	tokenService   auth.TokenService
+	heartbeat      HeartbeatConfig
+	overflowPolicy OverflowPolicy
+	rateLimiter    *RateLimiter
+	metrics        *ConnectionMetrics
 }
 
-func NewWebsocketHandler(service WebsocketService) *WebsocketHandlerImpl {
+func NewWebsocketHandler(service WebsocketService, tokenService auth.TokenService, heartbeat HeartbeatConfig, overflowPolicy OverflowPolicy, rateLimiter *RateLimiter, origins *cors.OriginPolicy, metrics *ConnectionMetrics) *WebsocketHandlerImpl {
 	return &WebsocketHandlerImpl{
-		service: service,
+		upgrader:       newUpgrader(origins.CheckOrigin),
+		service:        service,
+		tokenService:   tokenService,
+		heartbeat:      heartbeat,
+		overflowPolicy: overflowPolicy,
+		rateLimiter:    rateLimiter,
+		metrics:        metrics,
 	}
 }
 
-// https://quoridory.domain.io/v1/ws?user_id=1234
+// https://quoridory.domain.io/v1/ws?token=eyJhbGciOi...&updates=delta&v=2
 func (handler *WebsocketHandlerImpl) HandleWs(c *gin.Context) {
-	userId := c.Query("user_id")
-	if userId == "" {
-		http.Error(c.Writer, "User id is required", http.StatusBadRequest)
+	token := auth.TokenFromRequest(c)
+	if token == "" {
+		c.JSON(http.StatusUnauthorized, errors.ErrorResponse{ErrorType: errors.ErrUnauthorized.Error()})
+		return
+	}
+
+	// the token is verified before the upgrade, so the user id can't be spoofed
+	claims, err := handler.tokenService.VerifyToken(token)
+	if err != nil {
+		c.JSON(errors.HttpStatus(err), errors.ErrorResponse{ErrorType: err.Error()})
+		return
+	}
+	userId := claims.Subject
+
+	// clients that don't declare a version get the default one, the version can be changed later with a hello message
+	protocol, ok := ParseProtocolVersion(c.Query("v"))
+	if !ok {
+		c.JSON(errors.HttpStatus(errors.ErrUnsupportedVersion), UnsupportedVersionResponse{ErrorType: errors.ErrUnsupportedVersion.Error(), SupportedVersions: SupportedProtocolVersions})
 		return
 	}
 
-	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	conn, err := handler.upgrader.Upgrade(c.Writer, c.Request, nil)
 	if err != nil {
 		log.Println("Failed to upgrade to websocket:", err)
 		return
 	}
 
-	client := NewWebsocketClient(userId, conn, handler.service)
+	// the connection is closed by the reader when a message is bigger
+	if size := handler.rateLimiter.MaxMessageSize(); size > 0 {
+		conn.SetReadLimit(size)
+	}
+	limiter := handler.rateLimiter.NewConnectionLimiter(userId, time.Now())
+
+	encoding := EncodingForSubprotocol(conn.Subprotocol())
+
+	// older clients don't ask for deltas and keep getting the full game state after every move
+	deltaUpdates := c.Query("updates") == "delta"
+
+	client := NewWebsocketClient(userId, claims.Guest, conn, handler.service, handler.heartbeat, handler.overflowPolicy, deltaUpdates, protocol, encoding, limiter, handler.metrics)
 
 	handler.service.RegisterClient(client)
 
 	go client.ReadMessage()
 	go client.WriteMessage()
 }
+
+// http://127.0.0.1:8081/v1/ws/stats, served on the internal address only
+func (handler *WebsocketHandlerImpl) HandleGetStats(c *gin.Context) {
+	c.JSON(http.StatusOK, handler.metrics.Stats())
+}
@@ -0,0 +1,427 @@
+package sockets
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"quoridor/internal/auth"
+	"quoridor/internal/cors"
+	"quoridor/internal/errors"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+var testHeartbeat = HeartbeatConfig{PingInterval: time.Second, PongWait: 2 * time.Second, WriteWait: time.Second}
+
+const testOrigin = "https://quoridory.domain.io"
+
+var testRateLimit = RateLimitConfig{MessagesPerSecond: 100, Burst: 100, UserMessagesPerSecond: 100, UserBurst: 100, MaxViolations: 10, MaxMessageSize: 4096}
+
+func newTestServer(t *testing.T, service *WebsocketServiceImpl, tokenService auth.TokenService) *httptest.Server {
+	return newTestServerWithHeartbeat(t, service, tokenService, testHeartbeat, NewConnectionMetrics())
+}
+
+func newTestServerWithHeartbeat(t *testing.T, service *WebsocketServiceImpl, tokenService auth.TokenService, heartbeat HeartbeatConfig, metrics *ConnectionMetrics) *httptest.Server {
+	return newTestServerWithRateLimit(t, service, tokenService, heartbeat, testRateLimit, metrics)
+}
+
+func newTestServerWithRateLimit(t *testing.T, service *WebsocketServiceImpl, tokenService auth.TokenService, heartbeat HeartbeatConfig, rateLimit RateLimitConfig, metrics *ConnectionMetrics) *httptest.Server {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	handler := NewWebsocketHandler(service, tokenService, heartbeat, OverflowPolicyDropOldest, NewRateLimiter(rateLimit), cors.NewOriginPolicy([]string{testOrigin}), metrics)
+	router.GET("/v1/ws", handler.HandleWs)
+	router.GET("/v1/ws/stats", handler.HandleGetStats)
+
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestHandleWs(t *testing.T) {
+	mockMMService := new(MockMatchmakingService)
+	mockGameService := new(MockGameService)
+	service := NewWebsocketService(mockMMService, mockGameService, NewBrokerBackplane("instance1", NewInProcessBroker()), SessionPolicyMultiple)
+	tokenService := auth.NewJwtTokenService([]byte("test-secret"), time.Hour)
+	server := newTestServer(t, service, tokenService)
+
+	mockMMService.On("RemoveUser", "user1").Return()
+
+	token, err := tokenService.IssueToken("user1", false)
+	assert.NoError(t, err)
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/v1/ws?token=" + token.Token
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	assert.Eventually(t, func() bool {
+		service.mutex.Lock()
+		defer service.mutex.Unlock()
+		_, ok := service.clients["user1"]
+		return ok
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestHandleWs_givenDeltaUpdatesRequested_shouldEnableThemForConnection(t *testing.T) {
+	mockMMService := new(MockMatchmakingService)
+	mockGameService := new(MockGameService)
+	service := NewWebsocketService(mockMMService, mockGameService, NewBrokerBackplane("instance1", NewInProcessBroker()), SessionPolicyMultiple)
+	tokenService := auth.NewJwtTokenService([]byte("test-secret"), time.Hour)
+	server := newTestServer(t, service, tokenService)
+
+	mockMMService.On("RemoveUser", "user1").Return()
+
+	token, err := tokenService.IssueToken("user1", false)
+	assert.NoError(t, err)
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/v1/ws?token=" + token.Token + "&updates=delta"
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	assert.Eventually(t, func() bool {
+		service.mutex.Lock()
+		defer service.mutex.Unlock()
+		for _, client := range service.clients["user1"] {
+			return client.deltaUpdates
+		}
+		return false
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestHandleWs_givenSecondConnectionClosed_shouldKeepFirst(t *testing.T) {
+	mockMMService := new(MockMatchmakingService)
+	mockGameService := new(MockGameService)
+	service := NewWebsocketService(mockMMService, mockGameService, NewBrokerBackplane("instance1", NewInProcessBroker()), SessionPolicyMultiple)
+	tokenService := auth.NewJwtTokenService([]byte("test-secret"), time.Hour)
+	server := newTestServer(t, service, tokenService)
+
+	mockMMService.On("RemoveUser", "user1").Return()
+
+	token, err := tokenService.IssueToken("user1", false)
+	assert.NoError(t, err)
+
+	sessions := func() int {
+		service.mutex.Lock()
+		defer service.mutex.Unlock()
+		return len(service.clients["user1"])
+	}
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/v1/ws?token=" + token.Token
+	conn1, _, err := websocket.DefaultDialer.Dial(url, nil)
+	assert.NoError(t, err)
+	defer conn1.Close()
+
+	conn2, _, err := websocket.DefaultDialer.Dial(url, nil)
+	assert.NoError(t, err)
+	assert.Eventually(t, func() bool { return sessions() == 2 }, time.Second, 10*time.Millisecond)
+
+	conn2.Close()
+
+	assert.Eventually(t, func() bool { return sessions() == 1 }, time.Second, 10*time.Millisecond)
+	mockMMService.AssertNotCalled(t, "RemoveUser", "user1")
+}
+
+func TestHandleWs_givenInvalidToken_shouldRejectUpgrade(t *testing.T) {
+	mockMMService := new(MockMatchmakingService)
+	mockGameService := new(MockGameService)
+	service := NewWebsocketService(mockMMService, mockGameService, NewBrokerBackplane("instance1", NewInProcessBroker()), SessionPolicyMultiple)
+	tokenService := auth.NewJwtTokenService([]byte("test-secret"), time.Hour)
+	server := newTestServer(t, service, tokenService)
+
+	otherToken, err := auth.NewJwtTokenService([]byte("other-secret"), time.Hour).IssueToken("user1", false)
+	assert.NoError(t, err)
+
+	for _, query := range []string{"", "?user_id=user1", "?token=invalid", "?token=" + otherToken.Token} {
+		url := "ws" + strings.TrimPrefix(server.URL, "http") + "/v1/ws" + query
+		_, response, err := websocket.DefaultDialer.Dial(url, nil)
+
+		assert.ErrorIs(t, err, websocket.ErrBadHandshake)
+		assert.Equal(t, http.StatusUnauthorized, response.StatusCode)
+	}
+
+	assert.Empty(t, service.clients)
+}
+
+func TestHandleWs_givenClientStopsAnsweringPings_shouldDropConnection(t *testing.T) {
+	mockMMService := new(MockMatchmakingService)
+	mockGameService := new(MockGameService)
+	service := NewWebsocketService(mockMMService, mockGameService, NewBrokerBackplane("instance1", NewInProcessBroker()), SessionPolicyMultiple)
+	tokenService := auth.NewJwtTokenService([]byte("test-secret"), time.Hour)
+	heartbeat := HeartbeatConfig{PingInterval: 50 * time.Millisecond, PongWait: 150 * time.Millisecond, WriteWait: 50 * time.Millisecond}
+	metrics := NewConnectionMetrics()
+	server := newTestServerWithHeartbeat(t, service, tokenService, heartbeat, metrics)
+
+	mockMMService.On("RemoveUser", "user1").Return()
+
+	token, err := tokenService.IssueToken("user1", false)
+	assert.NoError(t, err)
+
+	// the connection is never read, so the pings are not answered
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/v1/ws?token=" + token.Token
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	assert.Eventually(t, func() bool {
+		return metrics.Stats().Dropped == 1
+	}, time.Second, 10*time.Millisecond)
+
+	assert.Eventually(t, func() bool {
+		service.mutex.Lock()
+		defer service.mutex.Unlock()
+		_, ok := service.clients["user1"]
+		return !ok
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, int64(0), metrics.Stats().Open)
+}
+
+func TestHandleWs_givenClientAnswersPings_shouldKeepConnection(t *testing.T) {
+	mockMMService := new(MockMatchmakingService)
+	mockGameService := new(MockGameService)
+	service := NewWebsocketService(mockMMService, mockGameService, NewBrokerBackplane("instance1", NewInProcessBroker()), SessionPolicyMultiple)
+	tokenService := auth.NewJwtTokenService([]byte("test-secret"), time.Hour)
+	heartbeat := HeartbeatConfig{PingInterval: 50 * time.Millisecond, PongWait: 150 * time.Millisecond, WriteWait: 50 * time.Millisecond}
+	metrics := NewConnectionMetrics()
+	server := newTestServerWithHeartbeat(t, service, tokenService, heartbeat, metrics)
+
+	mockMMService.On("RemoveUser", "user1").Return()
+
+	token, err := tokenService.IssueToken("user1", false)
+	assert.NoError(t, err)
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/v1/ws?token=" + token.Token
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	// the default ping handler of the client answers with a pong while reading
+	go func() {
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	time.Sleep(4 * heartbeat.PongWait)
+
+	stats := metrics.Stats()
+	assert.Equal(t, int64(1), stats.Open)
+	assert.Equal(t, int64(0), stats.Dropped)
+}
+
+func TestHandleWs_givenProtocolVersion_shouldUseItForConnection(t *testing.T) {
+	mockMMService := new(MockMatchmakingService)
+	mockGameService := new(MockGameService)
+	service := NewWebsocketService(mockMMService, mockGameService, NewBrokerBackplane("instance1", NewInProcessBroker()), SessionPolicyMultiple)
+	tokenService := auth.NewJwtTokenService([]byte("test-secret"), time.Hour)
+	server := newTestServer(t, service, tokenService)
+
+	mockMMService.On("RemoveUser", "user1").Return()
+
+	token, err := tokenService.IssueToken("user1", false)
+	assert.NoError(t, err)
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/v1/ws?token=" + token.Token + "&v=2"
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	assert.Eventually(t, func() bool {
+		service.mutex.Lock()
+		defer service.mutex.Unlock()
+		for _, client := range service.clients["user1"] {
+			return client.Protocol().Version() == PROTOCOL_VERSION_2
+		}
+		return false
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestHandleWs_givenUnsupportedProtocolVersion_shouldRejectUpgrade(t *testing.T) {
+	mockMMService := new(MockMatchmakingService)
+	mockGameService := new(MockGameService)
+	service := NewWebsocketService(mockMMService, mockGameService, NewBrokerBackplane("instance1", NewInProcessBroker()), SessionPolicyMultiple)
+	tokenService := auth.NewJwtTokenService([]byte("test-secret"), time.Hour)
+	server := newTestServer(t, service, tokenService)
+
+	token, err := tokenService.IssueToken("user1", false)
+	assert.NoError(t, err)
+
+	for _, version := range []string{"0", "3", "latest"} {
+		url := "ws" + strings.TrimPrefix(server.URL, "http") + "/v1/ws?token=" + token.Token + "&v=" + version
+		_, response, err := websocket.DefaultDialer.Dial(url, nil)
+
+		assert.ErrorIs(t, err, websocket.ErrBadHandshake)
+		assert.Equal(t, http.StatusBadRequest, response.StatusCode)
+
+		body := UnsupportedVersionResponse{}
+		assert.NoError(t, json.NewDecoder(response.Body).Decode(&body))
+		assert.Equal(t, errors.ErrUnsupportedVersion.Error(), body.ErrorType)
+		assert.Equal(t, SupportedProtocolVersions, body.SupportedVersions)
+	}
+
+	assert.Empty(t, service.clients)
+}
+
+func TestHandleWs_givenHello_shouldSwitchProtocolVersion(t *testing.T) {
+	mockMMService := new(MockMatchmakingService)
+	mockGameService := new(MockGameService)
+	service := NewWebsocketService(mockMMService, mockGameService, NewBrokerBackplane("instance1", NewInProcessBroker()), SessionPolicyMultiple)
+	tokenService := auth.NewJwtTokenService([]byte("test-secret"), time.Hour)
+	server := newTestServer(t, service, tokenService)
+
+	mockMMService.On("RemoveUser", "user1").Return()
+
+	token, err := tokenService.IssueToken("user1", false)
+	assert.NoError(t, err)
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/v1/ws?token=" + token.Token
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	assert.NoError(t, err)
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+
+	// the hello is sent with the default version, the reply comes with the new one
+	assert.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`{"event":"hello","payload":{"version":2},"request_id":"r1"}`)))
+
+	_, data, err := conn.ReadMessage()
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"type":"hello","data":{"version":2,"supported_versions":[1,2]},"id":"r1"}`, string(data))
+
+	assert.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`{"type":"hello","data":{"version":3},"id":"r2"}`)))
+
+	_, data, err = conn.ReadMessage()
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"type":"error","data":{"error_type":"unsupported_version","supported_versions":[1,2]},"id":"r2"}`, string(data))
+}
+
+func TestHandleWs_givenMsgpackSubprotocol_shouldExchangeBinaryMessages(t *testing.T) {
+	mockMMService := new(MockMatchmakingService)
+	mockGameService := new(MockGameService)
+	service := NewWebsocketService(mockMMService, mockGameService, NewBrokerBackplane("instance1", NewInProcessBroker()), SessionPolicyMultiple)
+	tokenService := auth.NewJwtTokenService([]byte("test-secret"), time.Hour)
+	server := newTestServer(t, service, tokenService)
+
+	mockMMService.On("RemoveUser", "user1").Return()
+
+	token, err := tokenService.IssueToken("user1", false)
+	assert.NoError(t, err)
+
+	dialer := websocket.Dialer{Subprotocols: []string{SUBPROTOCOL_MSGPACK}}
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/v1/ws?token=" + token.Token
+	conn, _, err := dialer.Dial(url, nil)
+	assert.NoError(t, err)
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+
+	assert.Equal(t, SUBPROTOCOL_MSGPACK, conn.Subprotocol())
+
+	hello := map[string]interface{}{"event": "hello", "payload": map[string]interface{}{"version": 1}, "request_id": "r1"}
+	assert.NoError(t, conn.WriteMessage(websocket.BinaryMessage, encodeMsgpack(t, hello)))
+
+	messageType, data, err := conn.ReadMessage()
+	assert.NoError(t, err)
+	assert.Equal(t, websocket.BinaryMessage, messageType)
+	assert.JSONEq(t, `{"event":"hello","payload":{"version":1,"supported_versions":[1,2]},"request_id":"r1"}`, decodeMsgpack(t, data))
+}
+
+func TestHandleWs_givenClientSpamsMessages_shouldThrottleAndThenDisconnect(t *testing.T) {
+	mockMMService := new(MockMatchmakingService)
+	mockGameService := new(MockGameService)
+	service := NewWebsocketService(mockMMService, mockGameService, NewBrokerBackplane("instance1", NewInProcessBroker()), SessionPolicyMultiple)
+	tokenService := auth.NewJwtTokenService([]byte("test-secret"), time.Hour)
+	metrics := NewConnectionMetrics()
+	rateLimit := RateLimitConfig{MessagesPerSecond: 0.001, Burst: 1, UserMessagesPerSecond: 0.001, UserBurst: 10, MaxViolations: 1, MaxMessageSize: 4096}
+	server := newTestServerWithRateLimit(t, service, tokenService, testHeartbeat, rateLimit, metrics)
+
+	mockMMService.On("RemoveUser", "user1").Return()
+
+	token, err := tokenService.IssueToken("user1", false)
+	assert.NoError(t, err)
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/v1/ws?token=" + token.Token
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	assert.NoError(t, err)
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+
+	hello := []byte(`{"event":"hello","payload":{"version":1},"request_id":"r1"}`)
+	assert.NoError(t, conn.WriteMessage(websocket.TextMessage, hello))
+	_, data, err := conn.ReadMessage()
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"event":"hello","payload":{"version":1,"supported_versions":[1,2]},"request_id":"r1"}`, string(data))
+
+	assert.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`{"event":"hello","payload":{"version":1},"request_id":"r2"}`)))
+	_, data, err = conn.ReadMessage()
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"event":"error","payload":{"error_type":"rate_limited"},"request_id":"r2"}`, string(data))
+
+	assert.NoError(t, conn.WriteMessage(websocket.TextMessage, hello))
+	_, _, err = conn.ReadMessage()
+	assert.True(t, websocket.IsCloseError(err, websocket.ClosePolicyViolation))
+
+	assert.Eventually(t, func() bool { return metrics.Stats().Open == 0 }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, int64(1), metrics.Stats().Throttled)
+	assert.Equal(t, int64(1), metrics.Stats().RateLimited)
+}
+
+func TestHandleWs_givenMessageTooBig_shouldDisconnect(t *testing.T) {
+	mockMMService := new(MockMatchmakingService)
+	mockGameService := new(MockGameService)
+	service := NewWebsocketService(mockMMService, mockGameService, NewBrokerBackplane("instance1", NewInProcessBroker()), SessionPolicyMultiple)
+	tokenService := auth.NewJwtTokenService([]byte("test-secret"), time.Hour)
+	rateLimit := testRateLimit
+	rateLimit.MaxMessageSize = 64
+	server := newTestServerWithRateLimit(t, service, tokenService, testHeartbeat, rateLimit, NewConnectionMetrics())
+
+	mockMMService.On("RemoveUser", "user1").Return()
+
+	token, err := tokenService.IssueToken("user1", false)
+	assert.NoError(t, err)
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/v1/ws?token=" + token.Token
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	assert.NoError(t, err)
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+
+	message := `{"event":"start_game","payload":{"rematch_with":"` + strings.Repeat("a", 64) + `"}}`
+	assert.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(message)))
+
+	_, _, err = conn.ReadMessage()
+	assert.True(t, websocket.IsCloseError(err, websocket.CloseMessageTooBig))
+	mockMMService.AssertNotCalled(t, "AddUser")
+}
+
+func TestHandleWs_givenOrigin_shouldAcceptOnlyAllowedOrigins(t *testing.T) {
+	mockMMService := new(MockMatchmakingService)
+	mockGameService := new(MockGameService)
+	service := NewWebsocketService(mockMMService, mockGameService, NewBrokerBackplane("instance1", NewInProcessBroker()), SessionPolicyMultiple)
+	tokenService := auth.NewJwtTokenService([]byte("test-secret"), time.Hour)
+	server := newTestServer(t, service, tokenService)
+
+	mockMMService.On("RemoveUser", "user1").Return()
+
+	token, err := tokenService.IssueToken("user1", false)
+	assert.NoError(t, err)
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/v1/ws?token=" + token.Token
+
+	_, response, err := websocket.DefaultDialer.Dial(url, http.Header{"Origin": {"https://evil.example.com"}})
+	assert.ErrorIs(t, err, websocket.ErrBadHandshake)
+	assert.Equal(t, http.StatusForbidden, response.StatusCode)
+
+	// mobile clients don't send an origin
+	for _, header := range []http.Header{{"Origin": {testOrigin}}, nil} {
+		conn, _, err := websocket.DefaultDialer.Dial(url, header)
+		assert.NoError(t, err)
+		conn.Close()
+	}
+}
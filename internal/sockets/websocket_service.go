@@ -7,55 +7,220 @@ import (
 	"quoridor/internal/events"
 	"quoridor/internal/game"
 	"quoridor/internal/matchmaking"
+	"strconv"
 	"sync"
 )
 
 type WebsocketService interface {
 	RegisterClient(client *Client)
-	UnregisterClient(userId string)
-	HandleMessage(userId string, message *WebsocketMessage)
+	UnregisterClient(userId, sessionId string)
+	HandleMessage(userId, sessionId string, message *WebsocketMessage)
 	HandleMatchFound(event *events.Event)
+	HandleBotMatchFound(event *events.Event)
+	HandleQueueStatus(event *events.Event)
+	ResumeGames() error
 }
 
+type SessionPolicy string
+
+const (
+	SessionPolicyMultiple  SessionPolicy = "multiple"   // every connection of the user stays open
+	SessionPolicyKickOlder SessionPolicy = "kick_older" // a new connection closes the older ones
+)
+
 type WebsocketServiceImpl struct {
-	mutex       sync.Mutex
-	clients     map[string]*Client
-	mmService   matchmaking.MatchmakingService
-	gameService game.GameService
+	mutex         sync.Mutex
+	clients       map[string]map[string]*Client // user id -> session id -> connection of the user
+	sessionPolicy SessionPolicy
+	resumedGames  map[string]string // user id -> id of the game that was in progress when the server started
+	mmService     matchmaking.MatchmakingService
+	gameService   game.GameService
+	backplane     Backplane
+	gameStreams   *GameStreams
 }
 
-func NewWebsocketService(mmService matchmaking.MatchmakingService, gameService game.GameService) *WebsocketServiceImpl {
-	return &WebsocketServiceImpl{
-		clients:     map[string]*Client{},
-		mmService:   mmService,
-		gameService: gameService,
+// messages for users connected to other instances of the server go through the backplane
+func NewWebsocketService(mmService matchmaking.MatchmakingService, gameService game.GameService, backplane Backplane, sessionPolicy SessionPolicy) *WebsocketServiceImpl {
+	service := &WebsocketServiceImpl{
+		clients:       map[string]map[string]*Client{},
+		sessionPolicy: sessionPolicy,
+		resumedGames:  map[string]string{},
+		mmService:     mmService,
+		gameService:   gameService,
+		backplane:     backplane,
+		gameStreams:   NewGameStreams(),
 	}
+
+	if err := backplane.Subscribe(service.handleBackplaneMessage); err != nil {
+		log.Printf("Failed to subscribe to the backplane: err=%v", err)
+	}
+
+	return service
 }
 
 func (service *WebsocketServiceImpl) RegisterClient(client *Client) {
-	log.Printf("Registering client: userId=%v", client.userId)
+	log.Printf("Registering client: userId=%v, sessionId=%v", client.userId, client.sessionId)
+
+	service.mutex.Lock()
+	sessions, ok := service.clients[client.userId]
+	if !ok {
+		sessions = map[string]*Client{}
+		service.clients[client.userId] = sessions
+	}
+
+	kicked := []*Client{}
+	if service.sessionPolicy == SessionPolicyKickOlder {
+		for sessionId, session := range sessions {
+			kicked = append(kicked, session)
+			delete(sessions, sessionId)
+		}
+	}
+	sessions[client.sessionId] = client
+
+	gameId, resumed := service.resumedGames[client.userId]
+	delete(service.resumedGames, client.userId)
+	service.mutex.Unlock()
+
+	// the kicked connections are closed by the client once the message is written
+	for _, session := range kicked {
+		log.Printf("Replacing older session: userId=%v, sessionId=%v", session.userId, session.sessionId)
+		session.Send(&WebsocketMessage{Type: EventTypeSessionReplaced})
+	}
+
+	if resumed {
+		service.pushResumedGame(client.userId, gameId)
+	}
+}
+
+/*
+loads games that were in progress before the server (re)started,
+so players get the game state as soon as they reconnect instead of having to find their game again
+*/
+func (service *WebsocketServiceImpl) ResumeGames() error {
+	log.Println("Resuming games in progress...")
+
+	games, err := service.gameService.GetGamesInProgress()
+	if err != nil {
+		log.Printf("Failed to load games in progress: err=%v", err)
+		return err
+	}
 
 	service.mutex.Lock()
 	defer service.mutex.Unlock()
 
-	service.clients[client.userId] = client
+	for _, game := range games {
+		service.resumedGames[game.Player1.UserId] = game.GameId
+		service.resumedGames[game.Player2.UserId] = game.GameId
+	}
+
+	log.Printf("Resumed %d games in progress", len(games))
+	return nil
 }
 
-func (service *WebsocketServiceImpl) UnregisterClient(userId string) {
-	log.Printf("Unregistering client: userId=%v", userId)
+// the user leaves the matchmaking only when their last connection is closed
+func (service *WebsocketServiceImpl) UnregisterClient(userId, sessionId string) {
+	log.Printf("Unregistering client: userId=%v, sessionId=%v", userId, sessionId)
 
 	service.mutex.Lock()
 	defer service.mutex.Unlock()
 
-	delete(service.clients, userId)
-	service.mmService.RemoveUser(userId)
+	sessions, ok := service.clients[userId]
+	if !ok {
+		return
+	}
+
+	delete(sessions, sessionId)
+	if len(sessions) == 0 {
+		delete(service.clients, userId)
+		service.mmService.RemoveUser(userId)
+	}
 }
 
+func (service *WebsocketServiceImpl) pushResumedGame(userId, gameId string) {
+	log.Printf("Pushing resumed game state: userId=%v, gameId=%v", userId, gameId)
+
+	state, err := service.gameService.GetGameById(gameId)
+	if err != nil {
+		service.sendErrorMessage(userId, err)
+		return
+	}
+
+	// the game could have been finished by the opponent in the meantime
+	if state.GameStatus != game.GameStatusInProgress {
+		return
+	}
+
+	if message := service.gameSnapshotMessage(state); message != nil {
+		service.sendMessage(userId, message)
+	}
+}
+
+// the full state of the game numbered with the last sequence of the game, the client continues from there
+func (service *WebsocketServiceImpl) gameSnapshotMessage(state *game.Game) *WebsocketMessage {
+	payload, err := json.Marshal(state)
+	if err != nil {
+		log.Printf("Failed to marshal game state: err=%v", err)
+		return nil
+	}
+
+	return &WebsocketMessage{
+		Type:     EventTypeGameState,
+		Payload:  payload,
+		GameId:   state.GameId,
+		Sequence: service.gameStreams.LastSequence(state.GameId),
+	}
+}
+
+func (service *WebsocketServiceImpl) sendPayload(userId string, eventType EventType, payload interface{}) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		log.Printf("Failed to marshal %v payload: err=%v", eventType, err)
+		return
+	}
+
+	service.sendMessage(userId, &WebsocketMessage{Type: eventType, Payload: data})
+}
+
+// the message is published to the other instances as well, the user may have connections there too
 func (service *WebsocketServiceImpl) sendMessage(userId string, message *WebsocketMessage) {
 	log.Printf("Sending websocket message: userId=%v, type=%v", userId, message.Type)
 
-	if client, ok := service.clients[userId]; ok {
-		client.messages <- message
+	service.sendLocalMessage(userId, message)
+
+	if err := service.backplane.Publish(userId, message); err != nil {
+		log.Printf("Failed to publish websocket message: userId=%v, err=%v", userId, err)
+	}
+}
+
+// sends the message to every connection of the user, returns false when the user is not connected to this instance
+func (service *WebsocketServiceImpl) sendLocalMessage(userId string, message *WebsocketMessage) bool {
+	service.mutex.Lock()
+	sessions := make([]*Client, 0, len(service.clients[userId]))
+	for _, client := range service.clients[userId] {
+		sessions = append(sessions, client)
+	}
+	service.mutex.Unlock()
+
+	for _, client := range sessions {
+		client.Send(message)
+	}
+	return len(sessions) > 0
+}
+
+/*
+messages from the other instances are dropped when the user is not connected here either.
+the stream of a game that ended on another instance is removed like the streams of the games that end here
+*/
+func (service *WebsocketServiceImpl) handleBackplaneMessage(userId string, message *WebsocketMessage) {
+	if message.GameId != "" && message.Sequence > 0 {
+		service.gameStreams.Record(message)
+		if gameFinished(message) {
+			service.gameStreams.Remove(message.GameId)
+		}
+	}
+
+	if service.sendLocalMessage(userId, message) {
+		log.Printf("Delivered websocket message from the backplane: userId=%v, type=%v", userId, message.Type)
 	}
 }
 
@@ -73,35 +238,152 @@ func (service *WebsocketServiceImpl) sendErrorMessage(userId string, err error)
 	service.sendMessage(userId, &message)
 }
 
-func (service *WebsocketServiceImpl) broadcastGameState(game *game.Game) {
-	payload, err := json.Marshal(game)
+// acks are sent only for requests with an id, older clients don't expect them
+func (service *WebsocketServiceImpl) replyAck(userId, sessionId string, request *WebsocketMessage) {
+	if request.RequestId == "" {
+		return
+	}
+	service.replyPayload(userId, sessionId, request, EventTypeAck, AckPayload{Event: request.Type})
+}
+
+func (service *WebsocketServiceImpl) replyError(userId, sessionId string, request *WebsocketMessage, err error) {
+	log.Printf("Error: userId=%v, requestId=%v, err=%v", userId, request.RequestId, err)
+	service.replyPayload(userId, sessionId, request, EventTypeError, ErrorMessagePayload{ErrorType: err.Error()})
+}
+
+func (service *WebsocketServiceImpl) replyPayload(userId, sessionId string, request *WebsocketMessage, eventType EventType, payload interface{}) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		log.Printf("Failed to marshal %v payload: err=%v", eventType, err)
+		return
+	}
+
+	service.replyMessage(userId, sessionId, request, &WebsocketMessage{Type: eventType, Payload: data})
+}
+
+// the request id of the request is echoed in the reply
+func (service *WebsocketServiceImpl) replyMessage(userId, sessionId string, request *WebsocketMessage, message *WebsocketMessage) {
+	message.RequestId = request.RequestId
+
+	service.mutex.Lock()
+	client, ok := service.clients[userId][sessionId]
+	service.mutex.Unlock()
+
+	if ok {
+		client.Send(message)
+	}
+}
+
+func (service *WebsocketServiceImpl) broadcastGameState(state *game.Game) {
+	payload, err := json.Marshal(state)
+	if err != nil {
+		log.Printf("Failed to marshal game state: err=%v", err)
+		return
+	}
+
+	service.broadcastGameMessage(state, &WebsocketMessage{Type: EventTypeGameState, Payload: payload})
+}
+
+/*
+sends the game state with the moves made by the user's command attached as a delta.
+the moves start with the last move of the user, the reply of the bot follows it in bot games.
+the end of the game is sent as the full state, it carries the rating changes
+*/
+func (service *WebsocketServiceImpl) broadcastMoveApplied(state *game.Game, userId string) {
+	if state.GameStatus != game.GameStatusInProgress {
+		service.broadcastGameState(state)
+		return
+	}
+
+	first := len(state.Moves)
+	for first > 0 && state.Moves[first-1].UserId != userId {
+		first--
+	}
+	if first > 0 {
+		first--
+	}
+
+	delta := MoveAppliedPayload{
+		GameId:       state.GameId,
+		Moves:        state.Moves[first:],
+		MovesCount:   len(state.Moves),
+		Turn:         state.Turn,
+		Player1Walls: state.Player1.Walls,
+		Player2Walls: state.Player2.Walls,
+		Status:       state.GameStatus,
+	}
+
+	payload, err := json.Marshal(state)
 	if err != nil {
 		log.Printf("Failed to marshal game state: err=%v", err)
 		return
 	}
 
-	message := WebsocketMessage{Type: EventTypeGameState, Payload: payload}
-	service.sendMessage(game.Player1.UserId, &message)
-	service.sendMessage(game.Player2.UserId, &message)
+	deltaPayload, err := json.Marshal(delta)
+	if err != nil {
+		log.Printf("Failed to marshal move applied payload: err=%v", err)
+		return
+	}
+
+	message := &WebsocketMessage{Type: EventTypeGameState, Payload: payload}
+	message.Delta = &WebsocketMessage{Type: EventTypeMoveApplied, Payload: deltaPayload}
+	service.broadcastGameMessage(state, message)
 }
 
-func (service *WebsocketServiceImpl) HandleMessage(userId string, message *WebsocketMessage) {
-	log.Printf("Handling websocket message: userId=%v, type=%v", userId, message.Type)
+// the delta gets the same sequence number as the full state, they are two versions of the same message
+func (service *WebsocketServiceImpl) broadcastGameMessage(state *game.Game, message *WebsocketMessage) {
+	service.gameStreams.Append(state.GameId, message)
+	if message.Delta != nil {
+		message.Delta.GameId = message.GameId
+		message.Delta.Sequence = message.Sequence
+	}
+
+	service.sendMessage(state.Player1.UserId, message)
+	service.sendMessage(state.Player2.UserId, message)
+
+	// the clients that missed the end of the game get the final state as a snapshot
+	if state.GameStatus != game.GameStatusInProgress {
+		service.gameStreams.Remove(state.GameId)
+	}
+}
+
+func gameFinished(message *WebsocketMessage) bool {
+	if message.Type != EventTypeGameState {
+		return false
+	}
+
+	var state struct {
+		GameStatus game.GameStatus `json:"status"`
+	}
+	if err := json.Unmarshal(message.Payload, &state); err != nil {
+		log.Printf("Failed to unmarshal game state: gameId=%v, err=%v", message.GameId, err)
+		return false
+	}
+	return state.GameStatus != "" && state.GameStatus != game.GameStatusInProgress
+}
+
+// replies to the message go only to the connection it came from, the game state goes to every connection of both players
+func (service *WebsocketServiceImpl) HandleMessage(userId, sessionId string, message *WebsocketMessage) {
+	log.Printf("Handling websocket message: userId=%v, type=%v, requestId=%v", userId, message.Type, message.RequestId)
 
 	switch message.Type {
 	case EventTypeStartGame:
-		service.handStartGame(userId, message)
+		service.handStartGame(userId, sessionId, message)
+	case EventTypeCancelMatchmaking:
+		service.handleCancelMatchmaking(userId, sessionId, message)
 	case EventTypeMakeMove:
-		service.handleMove(userId, message)
+		service.handleMove(userId, sessionId, message)
 	case EventTypePlaceWall:
-		service.handlePlaceWall(userId, message)
+		service.handlePlaceWall(userId, sessionId, message)
 	case EventTypeResign:
-		service.handleResign(userId, message)
+		service.handleResign(userId, sessionId, message)
 	case EventTypeReconnect:
-		service.handleReconnect(userId, message)
+		service.handleReconnect(userId, sessionId, message)
+	case EventTypeResume:
+		service.handleResume(userId, sessionId, message)
 	default:
 		log.Printf("Unknown message type: %v", message.Type)
-		service.sendErrorMessage(userId, errors.ErrBadRequest)
+		service.replyError(userId, sessionId, message, errors.ErrBadRequest)
 	}
 }
 
@@ -109,10 +391,11 @@ func (service *WebsocketServiceImpl) HandleMatchFound(event *events.Event) {
 	data := event.Data.(map[string]string)
 	user1Id := data["user1Id"]
 	user2Id := data["user2Id"]
+	settings := gameSettingsFromEventData(data)
 
-	log.Printf("Handling match found: user1Id=%v, user2Id=%v", user1Id, user2Id)
+	log.Printf("Handling match found: user1Id=%v, user2Id=%v, settings=%+v", user1Id, user2Id, settings)
 
-	game, err := service.gameService.CreateGame(user1Id, user2Id)
+	game, err := service.gameService.CreateGame(user1Id, user2Id, &settings)
 	if err != nil {
 		service.sendErrorMessage(user1Id, err)
 		service.sendErrorMessage(user2Id, err)
@@ -122,103 +405,280 @@ func (service *WebsocketServiceImpl) HandleMatchFound(event *events.Event) {
 	service.broadcastGameState(game)
 }
 
-func (service *WebsocketServiceImpl) handStartGame(userId string, _ *WebsocketMessage) {
+func (service *WebsocketServiceImpl) HandleBotMatchFound(event *events.Event) {
+	data := event.Data.(map[string]string)
+	userId := data["userId"]
+	botRating, _ := strconv.ParseFloat(data["botRating"], 64)
+	settings := gameSettingsFromEventData(data)
+
+	log.Printf("Handling bot match found: userId=%v, botRating=%v, settings=%+v", userId, botRating, settings)
+
+	game, err := service.gameService.CreateBotGame(userId, botRating, &settings)
+	if err != nil {
+		service.sendErrorMessage(userId, err)
+		return
+	}
+
+	service.broadcastGameState(game)
+}
+
+func (service *WebsocketServiceImpl) HandleQueueStatus(event *events.Event) {
+	data := event.Data.(map[string]string)
+	userId := data["userId"]
+	settings := gameSettingsFromEventData(data)
+
+	payload := QueueStatusPayload{
+		Ranked:      settings.Ranked,
+		Variant:     settings.Variant,
+		TimeControl: settings.TimeControl,
+	}
+	payload.WaitedSeconds, _ = strconv.Atoi(data["waited"])
+	payload.PlayersInPool, _ = strconv.Atoi(data["playersInPool"])
+	if estimatedWait, err := strconv.Atoi(data["estimatedWait"]); err == nil {
+		payload.EstimatedWaitSeconds = &estimatedWait
+	}
+
+	// every instance publishes the statuses of the whole queue, so each one only notifies its own clients
+	encoded, err := json.Marshal(payload)
+	if err != nil {
+		log.Printf("Failed to marshal %v payload: err=%v", EventTypeQueueStatus, err)
+		return
+	}
+	service.sendLocalMessage(userId, &WebsocketMessage{Type: EventTypeQueueStatus, Payload: encoded})
+}
+
+// the settings are passed as strings by the matchmaking, the defaults are used for the missing ones
+func gameSettingsFromEventData(data map[string]string) game.GameSettings {
+	settings := game.DefaultGameSettings()
+	settings.Ranked, _ = strconv.ParseBool(data["ranked"])
+	if variant, ok := data["variant"]; ok {
+		settings.Variant = game.GameVariant(variant)
+	}
+	if timeControl, ok := data["timeControl"]; ok {
+		settings.TimeControl = game.TimeControl(timeControl)
+	}
+	return settings
+}
+
+func (service *WebsocketServiceImpl) handStartGame(userId, sessionId string, message *WebsocketMessage) {
 	log.Printf("Handling start game: userId=%v", userId)
 
+	payload, err := service.parseStartGamePayload(message)
+	if err != nil {
+		log.Printf("Invalid start game request: userId=%v, err=%v", userId, err)
+		service.replyError(userId, sessionId, message, err)
+		return
+	}
+	settings := game.GameSettings{Ranked: payload.Ranked, Variant: payload.Variant, TimeControl: payload.TimeControl}
+
+	// guests can only play casual games until they upgrade their account
+	if settings.Ranked && service.isGuest(userId) {
+		log.Printf("Guest can't join ranked queue: userId=%v", userId)
+		service.replyError(userId, sessionId, message, errors.ErrRankedNotAllowed)
+		return
+	}
+
 	activeGame, err := service.gameService.GetActiveGameByUserId(userId)
 	if err != nil {
-		service.sendErrorMessage(userId, errors.ErrInternalError)
+		service.replyError(userId, sessionId, message, errors.ErrInternalError)
 		return
 	}
 
+	// queue_joined tells the client that it was queued instead of joining an active game
 	if activeGame == nil {
 		log.Printf("Adding user to matchmaking queue: userId=%v.", userId)
-		service.mmService.AddUser(userId)
+		status := service.mmService.AddUser(userId, settings, payload.RematchWith)
+
+		service.replyAck(userId, sessionId, message)
+		if status != nil {
+			service.replyPayload(userId, sessionId, message, EventTypeQueueJoined, newQueueStatusPayload(status))
+		}
 		return
 	}
 
-	payload, err := json.Marshal(activeGame)
-	if err != nil {
-		log.Printf("Failed to marshal game state: err=%v", err)
-		return
+	service.replyAck(userId, sessionId, message)
+	if snapshot := service.gameSnapshotMessage(activeGame); snapshot != nil {
+		service.sendMessage(userId, snapshot)
 	}
+}
 
-	message := WebsocketMessage{Type: EventTypeGameState, Payload: payload}
-	service.sendMessage(userId, &message)
+func newQueueStatusPayload(status *matchmaking.QueueStatus) *QueueStatusPayload {
+	payload := &QueueStatusPayload{
+		Ranked:        status.Settings.Ranked,
+		Variant:       status.Settings.Variant,
+		TimeControl:   status.Settings.TimeControl,
+		WaitedSeconds: int(status.Waited.Seconds()),
+		PlayersInPool: status.PlayersInPool,
+	}
+	if status.EstimatedWait != nil {
+		estimatedWait := int(status.EstimatedWait.Seconds())
+		payload.EstimatedWaitSeconds = &estimatedWait
+	}
+	return payload
 }
 
-func (service *WebsocketServiceImpl) handleMove(userId string, message *WebsocketMessage) {
+func (service *WebsocketServiceImpl) handleCancelMatchmaking(userId, sessionId string, message *WebsocketMessage) {
+	log.Printf("Handling cancel matchmaking: userId=%v", userId)
+
+	service.mmService.RemoveUser(userId)
+	service.replyAck(userId, sessionId, message)
+	service.replyMessage(userId, sessionId, message, &WebsocketMessage{Type: EventTypeMatchmakingCancelled})
+}
+
+// the payload of start_game is optional, older clients send none and get the default settings
+func (service *WebsocketServiceImpl) parseStartGamePayload(message *WebsocketMessage) (*StartGamePayload, error) {
+	payload := &StartGamePayload{}
+	if len(message.Payload) > 0 {
+		if err := json.Unmarshal(message.Payload, payload); err != nil {
+			return nil, errors.ErrBadRequest
+		}
+	}
+
+	defaults := game.DefaultGameSettings()
+	if payload.Variant == "" {
+		payload.Variant = defaults.Variant
+	}
+	if payload.TimeControl == "" {
+		payload.TimeControl = defaults.TimeControl
+	}
+
+	if !game.IsVariantSupported(payload.Variant) || !game.IsTimeControlSupported(payload.TimeControl) {
+		return nil, errors.ErrBadRequest
+	}
+
+	return payload, nil
+}
+
+func (service *WebsocketServiceImpl) isGuest(userId string) bool {
+	service.mutex.Lock()
+	defer service.mutex.Unlock()
+
+	// all connections of the user share the same token claims
+	for _, client := range service.clients[userId] {
+		return client.guest
+	}
+	return false
+}
+
+func (service *WebsocketServiceImpl) handleMove(userId, sessionId string, message *WebsocketMessage) {
 	log.Printf("Handling move: userId=%v", userId)
 
 	payload := MakeMovePayload{}
 	if err := json.Unmarshal(message.Payload, &payload); err != nil {
 		log.Printf("Failed to unmarshal move request: userId=%v, err=%v", userId, err)
-		service.sendErrorMessage(userId, errors.ErrBadRequest)
+		service.replyError(userId, sessionId, message, errors.ErrBadRequest)
 		return
 	}
 
 	game, err := service.gameService.MakeMove(payload.GameId, userId, &payload.Position)
 	if err != nil {
-		service.sendErrorMessage(userId, err)
+		service.replyError(userId, sessionId, message, err)
 		return
 	}
 
-	service.broadcastGameState(game)
+	service.replyAck(userId, sessionId, message)
+	service.broadcastMoveApplied(game, userId)
 }
 
-func (service *WebsocketServiceImpl) handlePlaceWall(userId string, message *WebsocketMessage) {
+func (service *WebsocketServiceImpl) handlePlaceWall(userId, sessionId string, message *WebsocketMessage) {
 	log.Printf("Handling wall placement: userId=%v", userId)
 
 	payload := PlaceWallPayload{}
 	if err := json.Unmarshal(message.Payload, &payload); err != nil {
 		log.Printf("Failed to unmarshal wall placement request: userId=%v, err=%v", userId, err)
-		service.sendErrorMessage(userId, errors.ErrBadRequest)
+		service.replyError(userId, sessionId, message, errors.ErrBadRequest)
 		return
 	}
 
 	game, err := service.gameService.PlaceWall(payload.GameId, userId, &payload.Wall)
 	if err != nil {
-		service.sendErrorMessage(userId, err)
+		service.replyError(userId, sessionId, message, err)
 		return
 	}
 
-	service.broadcastGameState(game)
+	service.replyAck(userId, sessionId, message)
+	service.broadcastMoveApplied(game, userId)
 }
 
-func (service *WebsocketServiceImpl) handleResign(userId string, message *WebsocketMessage) {
+func (service *WebsocketServiceImpl) handleResign(userId, sessionId string, message *WebsocketMessage) {
 	log.Printf("Handling resign: userId=%v", userId)
 
 	payload := ResignPayload{}
 	if err := json.Unmarshal(message.Payload, &payload); err != nil {
 		log.Printf("Failed to unmarshal resign request: userId=%v, err=%v", userId, err)
-		service.sendErrorMessage(userId, errors.ErrBadRequest)
+		service.replyError(userId, sessionId, message, errors.ErrBadRequest)
 		return
 	}
 
 	game, err := service.gameService.Resign(payload.GameId, userId)
 	if err != nil {
-		service.sendErrorMessage(userId, err)
+		service.replyError(userId, sessionId, message, err)
 		return
 	}
 
+	service.replyAck(userId, sessionId, message)
 	service.broadcastGameState(game)
 }
 
-func (service *WebsocketServiceImpl) handleReconnect(userId string, message *WebsocketMessage) {
+func (service *WebsocketServiceImpl) handleReconnect(userId, sessionId string, message *WebsocketMessage) {
 	log.Printf("Handling reconnect: userId=%v", userId)
 
 	payload := ReconnectPayload{}
 	if err := json.Unmarshal(message.Payload, &payload); err != nil {
 		log.Printf("Failed to unmarshal reconnect request: userId=%v, err=%v", userId, err)
-		service.sendErrorMessage(userId, errors.ErrBadRequest)
+		service.replyError(userId, sessionId, message, errors.ErrBadRequest)
 		return
 	}
 
 	game, err := service.gameService.Reconnect(payload.GameId, userId)
 	if err != nil {
-		service.sendErrorMessage(userId, err)
+		service.replyError(userId, sessionId, message, err)
 		return
 	}
 
+	service.replyAck(userId, sessionId, message)
 	service.broadcastGameState(game)
 }
+
+/*
+replays the messages of the game the client missed since the last sequence it saw.
+when some of them are no longer buffered, e.g. after a long disconnect or a restart, the full state is sent instead
+*/
+func (service *WebsocketServiceImpl) handleResume(userId, sessionId string, message *WebsocketMessage) {
+	log.Printf("Handling resume: userId=%v", userId)
+
+	payload := ResumePayload{}
+	if err := json.Unmarshal(message.Payload, &payload); err != nil {
+		log.Printf("Failed to unmarshal resume request: userId=%v, err=%v", userId, err)
+		service.replyError(userId, sessionId, message, errors.ErrBadRequest)
+		return
+	}
+
+	state, err := service.gameService.GetGameById(payload.GameId)
+	if err != nil {
+		service.replyError(userId, sessionId, message, err)
+		return
+	}
+
+	if state.Player1.UserId != userId && state.Player2.UserId != userId {
+		service.replyError(userId, sessionId, message, errors.ErrNotAPlayer)
+		return
+	}
+
+	service.replyAck(userId, sessionId, message)
+
+	missed, ok := service.gameStreams.Since(payload.GameId, payload.LastSequence)
+	if !ok {
+		log.Printf("Missed messages are not buffered, sending game snapshot: userId=%v, gameId=%v", userId, payload.GameId)
+		if snapshot := service.gameSnapshotMessage(state); snapshot != nil {
+			service.replyMessage(userId, sessionId, message, snapshot)
+		}
+		return
+	}
+
+	// the buffered messages are shared with the other connections, so the request id is set on copies
+	for _, missedMessage := range missed {
+		replay := *missedMessage
+		service.replyMessage(userId, sessionId, message, &replay)
+	}
+}
@@ -3,20 +3,23 @@ package sockets
 import (
 	"encoding/json"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"quoridor/internal/errors"
 	"quoridor/internal/events"
 	"quoridor/internal/game"
+	"quoridor/internal/matchmaking"
 )
 
 type MockMatchmakingService struct {
 	mock.Mock
 }
 
-func (m *MockMatchmakingService) AddUser(userId string) {
-	m.Called(userId)
+func (m *MockMatchmakingService) AddUser(userId string, settings game.GameSettings, rematchUserId string) *matchmaking.QueueStatus {
+	args := m.Called(userId, settings, rematchUserId)
+	return args.Get(0).(*matchmaking.QueueStatus)
 }
 
 func (m *MockMatchmakingService) RemoveUser(userId string) {
@@ -27,12 +30,21 @@ func (m *MockMatchmakingService) StartMatchmaking() {
 	m.Called()
 }
 
+func (m *MockMatchmakingService) StopMatchmaking() {
+	m.Called()
+}
+
 type MockGameService struct {
 	mock.Mock
 }
 
-func (m *MockGameService) CreateGame(user1Id, user2Id string) (*game.Game, error) {
-	args := m.Called(user1Id, user2Id)
+func (m *MockGameService) CreateGame(user1Id, user2Id string, settings *game.GameSettings) (*game.Game, error) {
+	args := m.Called(user1Id, user2Id, settings)
+	return args.Get(0).(*game.Game), args.Error(1)
+}
+
+func (m *MockGameService) CreateBotGame(userId string, botRating float64, settings *game.GameSettings) (*game.Game, error) {
+	args := m.Called(userId, botRating, settings)
 	return args.Get(0).(*game.Game), args.Error(1)
 }
 
@@ -46,6 +58,16 @@ func (m *MockGameService) GetActiveGameByUserId(userId string) (*game.Game, erro
 	return args.Get(0).(*game.Game), args.Error(1)
 }
 
+func (m *MockGameService) GetGameHistory(filter *game.GameHistoryFilter) (*game.GameHistoryPage, error) {
+	args := m.Called(filter)
+	return args.Get(0).(*game.GameHistoryPage), args.Error(1)
+}
+
+func (m *MockGameService) GetGamesInProgress() ([]*game.Game, error) {
+	args := m.Called()
+	return args.Get(0).([]*game.Game), args.Error(1)
+}
+
 func (m *MockGameService) MakeMove(gameId, userId string, newPos *game.Position) (*game.Game, error) {
 	args := m.Called(gameId, userId, newPos)
 	return args.Get(0).(*game.Game), args.Error(1)
@@ -69,36 +91,113 @@ func (m *MockGameService) Reconnect(gameId, userId string) (*game.Game, error) {
 func TestRegisterClient(t *testing.T) {
 	mockMMService := new(MockMatchmakingService)
 	mockGameService := new(MockGameService)
-	service := NewWebsocketService(mockMMService, mockGameService)
+	service := NewWebsocketService(mockMMService, mockGameService, NewBrokerBackplane("instance1", NewInProcessBroker()), SessionPolicyMultiple)
 
 	client := &Client{
-		userId:   "user1",
-		messages: make(chan *WebsocketMessage, 8),
+		userId:    "user1",
+		sessionId: "session1",
+		messages:  make(chan *WebsocketMessage, 8),
 	}
 	service.RegisterClient(client)
 
 	service.mutex.Lock()
-	registeredClient, ok := service.clients["user1"]
+	registeredClient, ok := service.clients["user1"]["session1"]
 	service.mutex.Unlock()
 
 	assert.True(t, ok)
 	assert.Equal(t, client, registeredClient)
 }
 
-func TestUnregisterClient(t *testing.T) {
+func TestResumeGames(t *testing.T) {
 	mockMMService := new(MockMatchmakingService)
 	mockGameService := new(MockGameService)
-	service := NewWebsocketService(mockMMService, mockGameService)
+	service := NewWebsocketService(mockMMService, mockGameService, NewBrokerBackplane("instance1", NewInProcessBroker()), SessionPolicyMultiple)
+
+	resumedGame := &game.Game{
+		GameId: "game1",
+		Player1: &game.Player{
+			UserId: "user1",
+		},
+		Player2: &game.Player{
+			UserId: "user2",
+		},
+		GameStatus: game.GameStatusInProgress,
+	}
+	mockGameService.On("GetGamesInProgress").Return([]*game.Game{resumedGame}, nil)
+	mockGameService.On("GetGameById", "game1").Return(resumedGame, nil)
+
+	err := service.ResumeGames()
+	assert.NoError(t, err)
 
 	client := &Client{
 		userId:   "user1",
-		messages: make(chan *WebsocketMessage, 8),
+		messages: make(chan *WebsocketMessage, 1),
+	}
+	service.RegisterClient(client)
+
+	receivedMessage := <-client.messages
+	assert.Equal(t, EventTypeGameState, receivedMessage.Type)
+
+	var receivedGame game.Game
+	err = json.Unmarshal(receivedMessage.Payload, &receivedGame)
+	assert.NoError(t, err)
+	assert.Equal(t, "game1", receivedGame.GameId)
+
+	// the game state is pushed only on the first connection after the restart
+	service.RegisterClient(client)
+	assert.Len(t, client.messages, 0)
+	mockGameService.AssertNumberOfCalls(t, "GetGameById", 1)
+}
+
+func TestResumeGames_givenGameFinished_shouldNotPushState(t *testing.T) {
+	mockMMService := new(MockMatchmakingService)
+	mockGameService := new(MockGameService)
+	service := NewWebsocketService(mockMMService, mockGameService, NewBrokerBackplane("instance1", NewInProcessBroker()), SessionPolicyMultiple)
+
+	resumedGame := &game.Game{
+		GameId: "game1",
+		Player1: &game.Player{
+			UserId: "user1",
+		},
+		Player2: &game.Player{
+			UserId: "user2",
+		},
+		GameStatus: game.GameStatusInProgress,
+	}
+	finishedGame := *resumedGame
+	finishedGame.GameStatus = game.GameStatusCompleted
+
+	mockGameService.On("GetGamesInProgress").Return([]*game.Game{resumedGame}, nil)
+	mockGameService.On("GetGameById", "game1").Return(&finishedGame, nil)
+
+	err := service.ResumeGames()
+	assert.NoError(t, err)
+
+	client := &Client{
+		userId:   "user2",
+		messages: make(chan *WebsocketMessage, 1),
+	}
+	service.RegisterClient(client)
+
+	assert.Len(t, client.messages, 0)
+	mockGameService.AssertCalled(t, "GetGameById", "game1")
+}
+
+func TestUnregisterClient(t *testing.T) {
+	mockMMService := new(MockMatchmakingService)
+	mockGameService := new(MockGameService)
+	service := NewWebsocketService(mockMMService, mockGameService, NewBrokerBackplane("instance1", NewInProcessBroker()), SessionPolicyMultiple)
+
+	client := &Client{
+		userId:    "user1",
+		sessionId: "session1",
+		messages:  make(chan *WebsocketMessage, 8),
 	}
 
 	mockMMService.On("RemoveUser", "user1").Return()
 
 	service.RegisterClient(client)
-	service.UnregisterClient("user1")
+	service.UnregisterClient("user1", "session1")
 
 	service.mutex.Lock()
 	_, ok := service.clients["user1"]
@@ -108,10 +207,69 @@ func TestUnregisterClient(t *testing.T) {
 	mockMMService.AssertCalled(t, "RemoveUser", "user1")
 }
 
+func TestUnregisterClient_givenAnotherSession_shouldKeepIt(t *testing.T) {
+	mockMMService := new(MockMatchmakingService)
+	mockGameService := new(MockGameService)
+	service := NewWebsocketService(mockMMService, mockGameService, NewBrokerBackplane("instance1", NewInProcessBroker()), SessionPolicyMultiple)
+
+	client1 := &Client{
+		userId:    "user1",
+		sessionId: "session1",
+		messages:  make(chan *WebsocketMessage, 1),
+	}
+	client2 := &Client{
+		userId:    "user1",
+		sessionId: "session2",
+		messages:  make(chan *WebsocketMessage, 1),
+	}
+	service.RegisterClient(client1)
+	service.RegisterClient(client2)
+
+	service.UnregisterClient("user1", "session1")
+
+	message := &WebsocketMessage{Type: "test", Payload: []byte("test")}
+	service.sendMessage("user1", message)
+
+	assert.Equal(t, message, <-client2.messages)
+	assert.Len(t, client1.messages, 0)
+	mockMMService.AssertNotCalled(t, "RemoveUser", "user1")
+}
+
+func TestRegisterClient_givenKickOlderPolicy_shouldReplaceOlderSession(t *testing.T) {
+	mockMMService := new(MockMatchmakingService)
+	mockGameService := new(MockGameService)
+	service := NewWebsocketService(mockMMService, mockGameService, NewBrokerBackplane("instance1", NewInProcessBroker()), SessionPolicyKickOlder)
+
+	client1 := &Client{
+		userId:    "user1",
+		sessionId: "session1",
+		messages:  make(chan *WebsocketMessage, 1),
+	}
+	client2 := &Client{
+		userId:    "user1",
+		sessionId: "session2",
+		messages:  make(chan *WebsocketMessage, 1),
+	}
+	service.RegisterClient(client1)
+	service.RegisterClient(client2)
+
+	assert.Equal(t, EventTypeSessionReplaced, (<-client1.messages).Type)
+
+	// the kicked connection unregisters itself once it is closed
+	service.UnregisterClient("user1", "session1")
+
+	service.mutex.Lock()
+	_, ok := service.clients["user1"]["session2"]
+	service.mutex.Unlock()
+
+	assert.True(t, ok)
+	mockMMService.AssertNotCalled(t, "RemoveUser", "user1")
+}
+
 func TestSendMessage(t *testing.T) {
 	mockMMService := new(MockMatchmakingService)
 	mockGameService := new(MockGameService)
-	service := NewWebsocketService(mockMMService, mockGameService)
+	service := NewWebsocketService(mockMMService, mockGameService, NewBrokerBackplane("instance1", NewInProcessBroker()), SessionPolicyMultiple)
 
 	client := &Client{
 		userId:   "user1",
@@ -126,11 +284,36 @@ func TestSendMessage(t *testing.T) {
 	assert.Equal(t, message, receivedMessage)
 }
 
+func TestSendMessage_givenSeveralSessions_shouldSendToEach(t *testing.T) {
+	mockMMService := new(MockMatchmakingService)
+	mockGameService := new(MockGameService)
+	service := NewWebsocketService(mockMMService, mockGameService, NewBrokerBackplane("instance1", NewInProcessBroker()), SessionPolicyMultiple)
+
+	client1 := &Client{
+		userId:    "user1",
+		sessionId: "session1",
+		messages:  make(chan *WebsocketMessage, 1),
+	}
+	client2 := &Client{
+		userId:    "user1",
+		sessionId: "session2",
+		messages:  make(chan *WebsocketMessage, 1),
+	}
+	service.RegisterClient(client1)
+	service.RegisterClient(client2)
+
+	message := &WebsocketMessage{Type: "test", Payload: []byte("test")}
+	service.sendMessage("user1", message)
+
+	assert.Equal(t, message, <-client1.messages)
+	assert.Equal(t, message, <-client2.messages)
+}
+
 
 func TestHandleMessage_UnknownType(t *testing.T) {
 	mockMMService := new(MockMatchmakingService)
 	mockGameService := new(MockGameService)
-	service := NewWebsocketService(mockMMService, mockGameService)
+	service := NewWebsocketService(mockMMService, mockGameService, NewBrokerBackplane("instance1", NewInProcessBroker()), SessionPolicyMultiple)
 
 	client := &Client{
 		userId:   "user1",
@@ -139,7 +322,7 @@ func TestHandleMessage_UnknownType(t *testing.T) {
 	service.RegisterClient(client)
 
 	message := &WebsocketMessage{Type: "unknown"}
-	service.HandleMessage("user1", message)
+	service.HandleMessage("user1", "", message)
 
 	errorMessage := <-client.messages
 	assert.Equal(t, EventTypeError, errorMessage.Type)
@@ -153,7 +336,7 @@ func TestHandleMessage_UnknownType(t *testing.T) {
 func TestHandleStartGame(t *testing.T) {
 	mockMMService := new(MockMatchmakingService)
 	mockGameService := new(MockGameService)
-	service := NewWebsocketService(mockMMService, mockGameService)
+	service := NewWebsocketService(mockMMService, mockGameService, NewBrokerBackplane("instance1", NewInProcessBroker()), SessionPolicyMultiple)
 
 	client := &Client{
 		userId:   "user1",
@@ -174,7 +357,7 @@ func TestHandleStartGame(t *testing.T) {
 	mockGameService.On("GetActiveGameByUserId", "user1").Return(activeGame, nil)
 
 	message := &WebsocketMessage{Type: EventTypeStartGame}
-	service.handStartGame("user1", message)
+	service.handStartGame("user1", "", message)
 
 	receivedMessage := <-client.messages
 	assert.Equal(t, EventTypeGameState, receivedMessage.Type)
@@ -190,36 +373,41 @@ func TestHandleStartGame(t *testing.T) {
 func TestHandleStartGame_NoActiveGame(t *testing.T) {
 	mockMMService := new(MockMatchmakingService)
 	mockGameService := new(MockGameService)
-	service := NewWebsocketService(mockMMService, mockGameService)
+	service := NewWebsocketService(mockMMService, mockGameService, NewBrokerBackplane("instance1", NewInProcessBroker()), SessionPolicyMultiple)
 
 	client := &Client{
 		userId:   "user1",
-		messages: make(chan *WebsocketMessage, 1),
+		messages: make(chan *WebsocketMessage, 2),
 	}
 	service.RegisterClient(client)
 
+	status := &matchmaking.QueueStatus{UserId: "user1", Settings: game.DefaultGameSettings(), PlayersInPool: 1}
 	mockGameService.On("GetActiveGameByUserId", "user1").Return((*game.Game)(nil), nil)
-	mockMMService.On("AddUser", "user1").Return()
+	mockMMService.On("AddUser", "user1", game.DefaultGameSettings(), "").Return(status)
 
 	message := &WebsocketMessage{Type: EventTypeStartGame}
-	service.handStartGame("user1", message)
+	service.handStartGame("user1", "", message)
 
 	receivedMessage := <-client.messages
-	assert.Equal(t, EventTypeGameState, receivedMessage.Type)
+	assert.Equal(t, EventTypeQueueJoined, receivedMessage.Type)
+	assert.Empty(t, client.messages)
 
-	var receivedGame *game.Game
-	err := json.Unmarshal(receivedMessage.Payload, &receivedGame)
+	var queuePayload QueueStatusPayload
+	err := json.Unmarshal(receivedMessage.Payload, &queuePayload)
 	assert.NoError(t, err)
-	assert.Nil(t, receivedGame)
+	assert.Equal(t, game.GameVariantStandard, queuePayload.Variant)
+	assert.Equal(t, game.TimeControlRapid, queuePayload.TimeControl)
+	assert.Equal(t, 1, queuePayload.PlayersInPool)
+	assert.Nil(t, queuePayload.EstimatedWaitSeconds)
 
 	mockGameService.AssertCalled(t, "GetActiveGameByUserId", "user1")
-	mockMMService.AssertCalled(t, "AddUser", "user1")
+	mockMMService.AssertCalled(t, "AddUser", "user1", game.DefaultGameSettings(), "")
 }
 
 func TestHandleStartGame_ErrorFetchingGame(t *testing.T) {
 	mockMMService := new(MockMatchmakingService)
 	mockGameService := new(MockGameService)
-	service := NewWebsocketService(mockMMService, mockGameService)
+	service := NewWebsocketService(mockMMService, mockGameService, NewBrokerBackplane("instance1", NewInProcessBroker()), SessionPolicyMultiple)
 
 	client := &Client{
 		userId:   "user1",
@@ -230,7 +418,7 @@ func TestHandleStartGame_ErrorFetchingGame(t *testing.T) {
 	mockGameService.On("GetActiveGameByUserId", "user1").Return((*game.Game)(nil), errors.ErrInternalError)
 
 	message := &WebsocketMessage{Type: EventTypeStartGame}
-	service.handStartGame("user1", message)
+	service.handStartGame("user1", "", message)
 
 	receivedMessage := <-client.messages
 	assert.Equal(t, EventTypeError, receivedMessage.Type)
@@ -243,13 +431,91 @@ func TestHandleStartGame_ErrorFetchingGame(t *testing.T) {
 	mockGameService.AssertCalled(t, "GetActiveGameByUserId", "user1")
 }
 
+func TestHandleStartGame_givenRankedSettings_shouldJoinRankedPool(t *testing.T) {
+	mockMMService := new(MockMatchmakingService)
+	mockGameService := new(MockGameService)
+	service := NewWebsocketService(mockMMService, mockGameService, NewBrokerBackplane("instance1", NewInProcessBroker()), SessionPolicyMultiple)
+
+	client := &Client{
+		userId:   "user1",
+		messages: make(chan *WebsocketMessage, 2),
+	}
+	service.RegisterClient(client)
+
+	settings := game.GameSettings{Ranked: true, Variant: game.GameVariantStandard, TimeControl: game.TimeControlBlitz}
+	mockGameService.On("GetActiveGameByUserId", "user1").Return((*game.Game)(nil), nil)
+	mockMMService.On("AddUser", "user1", settings, "user2").Return(&matchmaking.QueueStatus{UserId: "user1", Settings: settings})
+
+	message := &WebsocketMessage{Type: EventTypeStartGame, Payload: []byte(`{"ranked":true,"time_control":"blitz","rematch_with":"user2"}`)}
+	service.handStartGame("user1", "", message)
+
+	receivedMessage := <-client.messages
+	assert.Equal(t, EventTypeQueueJoined, receivedMessage.Type)
+
+	mockMMService.AssertCalled(t, "AddUser", "user1", settings, "user2")
+}
+
+func TestHandleStartGame_givenGuestRequestsRankedGame_shouldReturnError(t *testing.T) {
+	mockMMService := new(MockMatchmakingService)
+	mockGameService := new(MockGameService)
+	service := NewWebsocketService(mockMMService, mockGameService, NewBrokerBackplane("instance1", NewInProcessBroker()), SessionPolicyMultiple)
+
+	client := &Client{
+		userId:   "guest1",
+		guest:    true,
+		messages: make(chan *WebsocketMessage, 1),
+	}
+	service.RegisterClient(client)
+
+	message := &WebsocketMessage{Type: EventTypeStartGame, Payload: []byte(`{"ranked":true}`)}
+	service.handStartGame("guest1", "", message)
+
+	receivedMessage := <-client.messages
+	assert.Equal(t, EventTypeError, receivedMessage.Type)
+
+	var errorPayload ErrorMessagePayload
+	err := json.Unmarshal(receivedMessage.Payload, &errorPayload)
+	assert.NoError(t, err)
+	assert.Equal(t, errors.ErrRankedNotAllowed.Error(), errorPayload.ErrorType)
+
+	mockGameService.AssertNotCalled(t, "GetActiveGameByUserId", mock.Anything)
+	mockMMService.AssertNotCalled(t, "AddUser", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestHandleStartGame_givenUnsupportedSettings_shouldReturnError(t *testing.T) {
+	mockMMService := new(MockMatchmakingService)
+	mockGameService := new(MockGameService)
+	service := NewWebsocketService(mockMMService, mockGameService, NewBrokerBackplane("instance1", NewInProcessBroker()), SessionPolicyMultiple)
+
+	client := &Client{
+		userId:   "user1",
+		messages: make(chan *WebsocketMessage, 3),
+	}
+	service.RegisterClient(client)
+
+	payloads := []string{`{"variant":"hexagonal"}`, `{"time_control":"bullet"}`, `not json`}
+	for _, payload := range payloads {
+		message := &WebsocketMessage{Type: EventTypeStartGame, Payload: []byte(payload)}
+		service.handStartGame("user1", "", message)
+
+		receivedMessage := <-client.messages
+		assert.Equal(t, EventTypeError, receivedMessage.Type)
+
+		var errorPayload ErrorMessagePayload
+		err := json.Unmarshal(receivedMessage.Payload, &errorPayload)
+		assert.NoError(t, err)
+		assert.Equal(t, errors.ErrBadRequest.Error(), errorPayload.ErrorType)
+	}
+
+	mockMMService.AssertNotCalled(t, "AddUser", mock.Anything, mock.Anything, mock.Anything)
+}
 
 func TestHandleMatchFound(t *testing.T) {
 	mockMMService := new(MockMatchmakingService)
 	mockGameService := new(MockGameService)
-	service := NewWebsocketService(mockMMService, mockGameService)
+	service := NewWebsocketService(mockMMService, mockGameService, NewBrokerBackplane("instance1", NewInProcessBroker()), SessionPolicyMultiple)
 
-	game := &game.Game{
+	createdGame := &game.Game{
 		GameId: "game1",
 		Player1: &game.Player{
 			UserId: "user1",
@@ -258,7 +524,7 @@ func TestHandleMatchFound(t *testing.T) {
 			UserId: "user2",
 		},
 	}
-	mockGameService.On("CreateGame", "user1", "user2").Return(game, nil)
+	mockGameService.On("CreateGame", "user1", "user2", mock.Anything).Return(createdGame, nil)
 
 	client1 := &Client{
 		userId:   "user1",
@@ -274,16 +540,19 @@ func TestHandleMatchFound(t *testing.T) {
 	event := &events.Event{
 		Type: events.EventTypeMatchFound,
 		Data: map[string]string{
-			"user1Id": "user1",
-			"user2Id": "user2",
+			"user1Id":     "user1",
+			"user2Id":     "user2",
+			"ranked":      "true",
+			"variant":     "standard",
+			"timeControl": "blitz",
 		},
 	}
 	service.HandleMatchFound(event)
 
-	payload, err := json.Marshal(game)
+	payload, err := json.Marshal(createdGame)
 	assert.NoError(t, err)
 
-	expectedMessage := &WebsocketMessage{Type: EventTypeGameState, Payload: payload}
+	expectedMessage := &WebsocketMessage{Type: EventTypeGameState, Payload: payload, GameId: "game1", Sequence: 1}
 
 	receivedMessage1 := <-client1.messages
 	receivedMessage2 := <-client2.messages
@@ -291,15 +560,73 @@ func TestHandleMatchFound(t *testing.T) {
 	assert.Equal(t, expectedMessage, receivedMessage1)
 	assert.Equal(t, expectedMessage, receivedMessage2)
 
-	mockGameService.AssertCalled(t, "CreateGame", "user1", "user2")
+	mockGameService.AssertCalled(t, "CreateGame", "user1", "user2", mock.MatchedBy(func(settings *game.GameSettings) bool {
+		return settings.Ranked && settings.Variant == game.GameVariantStandard && settings.TimeControl == game.TimeControlBlitz
+	}))
+}
+
+func TestHandleMatchFound_givenOpponentOnAnotherInstance_shouldDeliverGameStateThroughBackplane(t *testing.T) {
+	broker := NewInProcessBroker()
+	mockMMService := new(MockMatchmakingService)
+	mockGameService := new(MockGameService)
+	service1 := NewWebsocketService(mockMMService, mockGameService, NewBrokerBackplane("instance1", broker), SessionPolicyMultiple)
+	service2 := NewWebsocketService(mockMMService, mockGameService, NewBrokerBackplane("instance2", broker), SessionPolicyMultiple)
+
+	createdGame := &game.Game{
+		GameId:  "game1",
+		Player1: &game.Player{UserId: "user1"},
+		Player2: &game.Player{UserId: "user2"},
+	}
+	mockGameService.On("CreateGame", "user1", "user2", mock.Anything).Return(createdGame, nil)
+
+	client1 := &Client{
+		userId:   "user1",
+		messages: make(chan *WebsocketMessage, 1),
+	}
+	client2 := &Client{
+		userId:   "user2",
+		messages: make(chan *WebsocketMessage, 1),
+	}
+	service1.RegisterClient(client1)
+	service2.RegisterClient(client2)
+
+	event := &events.Event{
+		Type: events.EventTypeMatchFound,
+		Data: map[string]string{"user1Id": "user1", "user2Id": "user2"},
+	}
+	service1.HandleMatchFound(event)
+
+	payload, err := json.Marshal(createdGame)
+	assert.NoError(t, err)
+
+	expectedMessage := &WebsocketMessage{Type: EventTypeGameState, Payload: payload, GameId: "game1", Sequence: 1}
+	assert.Equal(t, expectedMessage, <-client1.messages)
+	assert.Equal(t, expectedMessage, <-client2.messages)
+	assert.Len(t, client1.messages, 0)
+	assert.Equal(t, int64(1), service2.gameStreams.LastSequence("game1"))
+}
+
+func TestHandleBackplaneMessage_givenFinishedGame_shouldRemoveGameStream(t *testing.T) {
+	service := NewWebsocketService(new(MockMatchmakingService), new(MockGameService), NewBrokerBackplane("instance1", NewInProcessBroker()), SessionPolicyMultiple)
+
+	inProgress, err := json.Marshal(&game.Game{GameId: "game1", GameStatus: game.GameStatusInProgress})
+	assert.NoError(t, err)
+	service.handleBackplaneMessage("user1", &WebsocketMessage{Type: EventTypeGameState, Payload: inProgress, GameId: "game1", Sequence: 1})
+	assert.Equal(t, int64(1), service.gameStreams.LastSequence("game1"))
+
+	completed, err := json.Marshal(&game.Game{GameId: "game1", GameStatus: game.GameStatusCompleted})
+	assert.NoError(t, err)
+	service.handleBackplaneMessage("user1", &WebsocketMessage{Type: EventTypeGameState, Payload: completed, GameId: "game1", Sequence: 2})
+
+	assert.Equal(t, int64(0), service.gameStreams.LastSequence("game1"))
 }
 
 func TestHandleMatchFound_GameCreationError(t *testing.T) {
 	mockMMService := new(MockMatchmakingService)
 	mockGameService := new(MockGameService)
-	service := NewWebsocketService(mockMMService, mockGameService)
+	service := NewWebsocketService(mockMMService, mockGameService, NewBrokerBackplane("instance1", NewInProcessBroker()), SessionPolicyMultiple)
 
-	mockGameService.On("CreateGame", "user1", "user2").Return((*game.Game)(nil), errors.ErrInternalError)
+	mockGameService.On("CreateGame", "user1", "user2", mock.Anything).Return((*game.Game)(nil), errors.ErrInternalError)
 
 	client1 := &Client{
 		userId:   "user1",
@@ -327,13 +654,13 @@ func TestHandleMatchFound_GameCreationError(t *testing.T) {
 	assert.Equal(t, EventTypeError, errorMessage1.Type)
 	assert.Equal(t, EventTypeError, errorMessage2.Type)
 
-	mockGameService.AssertCalled(t, "CreateGame", "user1", "user2")
+	mockGameService.AssertCalled(t, "CreateGame", "user1", "user2", mock.Anything)
 }
 
 func TestHandleMove_InvalidPayload(t *testing.T) {
 	mockMMService := new(MockMatchmakingService)
 	mockGameService := new(MockGameService)
-	service := NewWebsocketService(mockMMService, mockGameService)
+	service := NewWebsocketService(mockMMService, mockGameService, NewBrokerBackplane("instance1", NewInProcessBroker()), SessionPolicyMultiple)
 
 	client := &Client{
 		userId:   "user1",
@@ -342,7 +669,7 @@ func TestHandleMove_InvalidPayload(t *testing.T) {
 	service.RegisterClient(client)
 
 	message := &WebsocketMessage{Type: EventTypeMakeMove, Payload: []byte("invalid")}
-	service.HandleMessage("user1", message)
+	service.HandleMessage("user1", "", message)
 
 	errorMessage := <-client.messages
 	assert.Equal(t, EventTypeError, errorMessage.Type)
@@ -356,7 +683,7 @@ func TestHandleMove_InvalidPayload(t *testing.T) {
 func TestHandleMove_MakeMoveError(t *testing.T) {
 	mockMMService := new(MockMatchmakingService)
 	mockGameService := new(MockGameService)
-	service := NewWebsocketService(mockMMService, mockGameService)
+	service := NewWebsocketService(mockMMService, mockGameService, NewBrokerBackplane("instance1", NewInProcessBroker()), SessionPolicyMultiple)
 
 	client := &Client{
 		userId:   "user1",
@@ -372,7 +699,7 @@ func TestHandleMove_MakeMoveError(t *testing.T) {
 	}
 	payloadBytes, _ := json.Marshal(payload)
 	message := &WebsocketMessage{Type: EventTypeMakeMove, Payload: payloadBytes}
-	service.HandleMessage("user1", message)
+	service.HandleMessage("user1", "", message)
 
 	errorMessage := <-client.messages
 	assert.Equal(t, EventTypeError, errorMessage.Type)
@@ -383,10 +710,77 @@ func TestHandleMove_MakeMoveError(t *testing.T) {
 	assert.Equal(t, errors.ErrInvalidMove.Error(), errorPayload.ErrorType)
 }
 
+func TestHandleMove_givenRequestId_shouldAckBeforeGameState(t *testing.T) {
+	mockMMService := new(MockMatchmakingService)
+	mockGameService := new(MockGameService)
+	service := NewWebsocketService(mockMMService, mockGameService, NewBrokerBackplane("instance1", NewInProcessBroker()), SessionPolicyMultiple)
+
+	client := &Client{
+		userId:    "user1",
+		sessionId: "session1",
+		messages:  make(chan *WebsocketMessage, 2),
+	}
+	service.RegisterClient(client)
+
+	updatedGame := &game.Game{
+		GameId:  "game1",
+		Player1: &game.Player{UserId: "user1"},
+		Player2: &game.Player{UserId: "user2"},
+	}
+	mockGameService.On("MakeMove", "game1", "user1", mock.Anything).Return(updatedGame, nil)
+
+	payloadBytes, _ := json.Marshal(MakeMovePayload{GameId: "game1", Position: game.Position{X: 4, Y: 1}})
+	message := &WebsocketMessage{Type: EventTypeMakeMove, Payload: payloadBytes, RequestId: "request1"}
+	service.HandleMessage("user1", "session1", message)
+
+	ackMessage := <-client.messages
+	assert.Equal(t, EventTypeAck, ackMessage.Type)
+	assert.Equal(t, "request1", ackMessage.RequestId)
+
+	var ackPayload AckPayload
+	err := json.Unmarshal(ackMessage.Payload, &ackPayload)
+	assert.NoError(t, err)
+	assert.Equal(t, EventTypeMakeMove, ackPayload.Event)
+
+	stateMessage := <-client.messages
+	assert.Equal(t, EventTypeGameState, stateMessage.Type)
+	assert.Empty(t, stateMessage.RequestId)
+}
+
+func TestHandleMove_givenRequestIdAndError_shouldReplyOnlyToRequestingSession(t *testing.T) {
+	mockMMService := new(MockMatchmakingService)
+	mockGameService := new(MockGameService)
+	service := NewWebsocketService(mockMMService, mockGameService, NewBrokerBackplane("instance1", NewInProcessBroker()), SessionPolicyMultiple)
+
+	client1 := &Client{
+		userId:    "user1",
+		sessionId: "session1",
+		messages:  make(chan *WebsocketMessage, 1),
+	}
+	client2 := &Client{
+		userId:    "user1",
+		sessionId: "session2",
+		messages:  make(chan *WebsocketMessage, 1),
+	}
+	service.RegisterClient(client1)
+	service.RegisterClient(client2)
+
+	mockGameService.On("MakeMove", "game1", "user1", mock.Anything).Return((*game.Game)(nil), errors.ErrInvalidMove)
+
+	payloadBytes, _ := json.Marshal(MakeMovePayload{GameId: "game1", Position: game.Position{X: 1, Y: 1}})
+	message := &WebsocketMessage{Type: EventTypeMakeMove, Payload: payloadBytes, RequestId: "request1"}
+	service.HandleMessage("user1", "session2", message)
+
+	errorMessage := <-client2.messages
+	assert.Equal(t, EventTypeError, errorMessage.Type)
+	assert.Equal(t, "request1", errorMessage.RequestId)
+	assert.Len(t, client1.messages, 0)
+}
+
 func TestHandlePlaceWall_InvalidPayload(t *testing.T) {
 	mockMMService := new(MockMatchmakingService)
 	mockGameService := new(MockGameService)
-	service := NewWebsocketService(mockMMService, mockGameService)
+	service := NewWebsocketService(mockMMService, mockGameService, NewBrokerBackplane("instance1", NewInProcessBroker()), SessionPolicyMultiple)
 
 	client := &Client{
 		userId:   "user1",
@@ -395,7 +789,7 @@ func TestHandlePlaceWall_InvalidPayload(t *testing.T) {
 	service.RegisterClient(client)
 
 	message := &WebsocketMessage{Type: EventTypePlaceWall, Payload: []byte("invalid")}
-	service.HandleMessage("user1", message)
+	service.HandleMessage("user1", "", message)
 
 	errorMessage := <-client.messages
 	assert.Equal(t, EventTypeError, errorMessage.Type)
@@ -409,7 +803,7 @@ func TestHandlePlaceWall_InvalidPayload(t *testing.T) {
 func TestHandlePlaceWall_PlaceWallError(t *testing.T) {
 	mockMMService := new(MockMatchmakingService)
 	mockGameService := new(MockGameService)
-	service := NewWebsocketService(mockMMService, mockGameService)
+	service := NewWebsocketService(mockMMService, mockGameService, NewBrokerBackplane("instance1", NewInProcessBroker()), SessionPolicyMultiple)
 
 	client := &Client{
 		userId:   "user1",
@@ -429,7 +823,7 @@ func TestHandlePlaceWall_PlaceWallError(t *testing.T) {
 	}
 	payloadBytes, _ := json.Marshal(payload)
 	message := &WebsocketMessage{Type: EventTypePlaceWall, Payload: payloadBytes}
-	service.HandleMessage("user1", message)
+	service.HandleMessage("user1", "", message)
 
 	errorMessage := <-client.messages
 	assert.Equal(t, EventTypeError, errorMessage.Type)
@@ -443,7 +837,7 @@ func TestHandlePlaceWall_PlaceWallError(t *testing.T) {
 func TestHandleReconnect(t *testing.T) {
 	mockMMService := new(MockMatchmakingService)
 	mockGameService := new(MockGameService)
-	service := NewWebsocketService(mockMMService, mockGameService)
+	service := NewWebsocketService(mockMMService, mockGameService, NewBrokerBackplane("instance1", NewInProcessBroker()), SessionPolicyMultiple)
 
 	client := &Client{
 		userId:   "user1",
@@ -468,10 +862,314 @@ func TestHandleReconnect(t *testing.T) {
 	}
 	payloadBytes, _ := json.Marshal(payload)
 	message := &WebsocketMessage{Type: EventTypeReconnect, Payload: payloadBytes}
-	service.HandleMessage("user1", message)
+	service.HandleMessage("user1", "", message)
 
 	receivedMessage := <-client.messages
 	assert.Equal(t, EventTypeGameState, receivedMessage.Type)
 
 	mockGameService.AssertCalled(t, "Reconnect", "game1", "user1")
 }
+
+func TestHandleResume_givenMissedMessagesBuffered_shouldReplayThem(t *testing.T) {
+	mockMMService := new(MockMatchmakingService)
+	mockGameService := new(MockGameService)
+	service := NewWebsocketService(mockMMService, mockGameService, NewBrokerBackplane("instance1", NewInProcessBroker()), SessionPolicyMultiple)
+
+	state := &game.Game{
+		GameId:     "game1",
+		GameStatus: game.GameStatusInProgress,
+		Player1:    &game.Player{UserId: "user1"},
+		Player2:    &game.Player{UserId: "user2"},
+	}
+	mockGameService.On("GetGameById", "game1").Return(state, nil)
+
+	for i := 0; i < 3; i++ {
+		service.broadcastGameState(state)
+	}
+
+	client := &Client{
+		userId:   "user1",
+		messages: make(chan *WebsocketMessage, 3),
+	}
+	service.RegisterClient(client)
+
+	payload, _ := json.Marshal(ResumePayload{GameId: "game1", LastSequence: 1})
+	service.HandleMessage("user1", "", &WebsocketMessage{Type: EventTypeResume, Payload: payload, RequestId: "request1"})
+
+	assert.Equal(t, EventTypeAck, (<-client.messages).Type)
+
+	replay1 := <-client.messages
+	replay2 := <-client.messages
+	assert.Equal(t, int64(2), replay1.Sequence)
+	assert.Equal(t, int64(3), replay2.Sequence)
+	assert.Equal(t, "request1", replay2.RequestId)
+
+	// the buffered messages are not changed by the replay
+	buffered, _ := service.gameStreams.Since("game1", 2)
+	assert.Empty(t, buffered[0].RequestId)
+}
+
+func TestHandleResume_givenMissedMessagesNotBuffered_shouldSendSnapshot(t *testing.T) {
+	mockMMService := new(MockMatchmakingService)
+	mockGameService := new(MockGameService)
+	service := NewWebsocketService(mockMMService, mockGameService, NewBrokerBackplane("instance1", NewInProcessBroker()), SessionPolicyMultiple)
+
+	state := &game.Game{
+		GameId:     "game1",
+		GameStatus: game.GameStatusInProgress,
+		Player1:    &game.Player{UserId: "user1"},
+		Player2:    &game.Player{UserId: "user2"},
+	}
+	mockGameService.On("GetGameById", "game1").Return(state, nil)
+
+	for i := 0; i < GAME_STREAM_BUFFER+5; i++ {
+		service.broadcastGameState(state)
+	}
+
+	client := &Client{
+		userId:   "user1",
+		messages: make(chan *WebsocketMessage, 1),
+	}
+	service.RegisterClient(client)
+
+	payload, _ := json.Marshal(ResumePayload{GameId: "game1", LastSequence: 2})
+	service.HandleMessage("user1", "", &WebsocketMessage{Type: EventTypeResume, Payload: payload})
+
+	snapshot := <-client.messages
+	assert.Equal(t, EventTypeGameState, snapshot.Type)
+	assert.Equal(t, int64(GAME_STREAM_BUFFER+5), snapshot.Sequence)
+	assert.Len(t, client.messages, 0)
+}
+
+func TestHandleResume_givenNotAPlayer_shouldReturnError(t *testing.T) {
+	mockMMService := new(MockMatchmakingService)
+	mockGameService := new(MockGameService)
+	service := NewWebsocketService(mockMMService, mockGameService, NewBrokerBackplane("instance1", NewInProcessBroker()), SessionPolicyMultiple)
+
+	state := &game.Game{
+		GameId:  "game1",
+		Player1: &game.Player{UserId: "user1"},
+		Player2: &game.Player{UserId: "user2"},
+	}
+	mockGameService.On("GetGameById", "game1").Return(state, nil)
+
+	client := &Client{
+		userId:   "user3",
+		messages: make(chan *WebsocketMessage, 1),
+	}
+	service.RegisterClient(client)
+
+	payload, _ := json.Marshal(ResumePayload{GameId: "game1", LastSequence: 0})
+	service.HandleMessage("user3", "", &WebsocketMessage{Type: EventTypeResume, Payload: payload})
+
+	errorMessage := <-client.messages
+	assert.Equal(t, EventTypeError, errorMessage.Type)
+
+	var errorPayload ErrorMessagePayload
+	err := json.Unmarshal(errorMessage.Payload, &errorPayload)
+	assert.NoError(t, err)
+	assert.Equal(t, errors.ErrNotAPlayer.Error(), errorPayload.ErrorType)
+}
+
+func TestBroadcastMoveApplied_shouldSendDeltaOnlyToConnectionsThatAskedForIt(t *testing.T) {
+	mockMMService := new(MockMatchmakingService)
+	mockGameService := new(MockGameService)
+	service := NewWebsocketService(mockMMService, mockGameService, NewBrokerBackplane("instance1", NewInProcessBroker()), SessionPolicyMultiple)
+
+	fullClient := &Client{
+		userId:    "user1",
+		sessionId: "session1",
+		messages:  make(chan *WebsocketMessage, 1),
+	}
+	deltaClient := &Client{
+		userId:       "user1",
+		sessionId:    "session2",
+		deltaUpdates: true,
+		messages:     make(chan *WebsocketMessage, 1),
+	}
+	service.RegisterClient(fullClient)
+	service.RegisterClient(deltaClient)
+
+	// a bot game, the bot replied to the move of the user
+	state := &game.Game{
+		GameId:     "game1",
+		GameStatus: game.GameStatusInProgress,
+		Turn:       "user1",
+		Player1:    &game.Player{UserId: "user1", Walls: 9},
+		Player2:    &game.Player{UserId: "bot-1", Walls: 10},
+		Moves: []*game.Move{
+			{UserId: "user1", Type: game.MoveTypeMove, Position: &game.Position{X: 4, Y: 1}},
+			{UserId: "bot-1", Type: game.MoveTypeMove, Position: &game.Position{X: 4, Y: 7}},
+			{UserId: "user1", Type: game.MoveTypePlaceWall, Wall: &game.Wall{Direction: game.Horizontal, Pos1: &game.Position{X: 3, Y: 6}, Pos2: &game.Position{X: 3, Y: 7}}},
+			{UserId: "bot-1", Type: game.MoveTypeMove, Position: &game.Position{X: 5, Y: 7}},
+		},
+	}
+	service.broadcastMoveApplied(state, "user1")
+
+	fullMessage := <-fullClient.messages
+	assert.Equal(t, EventTypeGameState, fullMessage.Type)
+
+	deltaMessage := <-deltaClient.messages
+	assert.Equal(t, EventTypeMoveApplied, deltaMessage.Type)
+	assert.Equal(t, fullMessage.Sequence, deltaMessage.Sequence)
+	assert.Equal(t, "game1", deltaMessage.GameId)
+
+	var delta MoveAppliedPayload
+	err := json.Unmarshal(deltaMessage.Payload, &delta)
+	assert.NoError(t, err)
+	assert.Len(t, delta.Moves, 2)
+	assert.Equal(t, game.MoveTypePlaceWall, delta.Moves[0].Type)
+	assert.Equal(t, "bot-1", delta.Moves[1].UserId)
+	assert.Equal(t, 4, delta.MovesCount)
+	assert.Equal(t, "user1", delta.Turn)
+	assert.Equal(t, 9, delta.Player1Walls)
+	assert.Equal(t, game.GameStatusInProgress, delta.Status)
+}
+
+func TestBroadcastMoveApplied_givenGameCompleted_shouldSendFullState(t *testing.T) {
+	mockMMService := new(MockMatchmakingService)
+	mockGameService := new(MockGameService)
+	service := NewWebsocketService(mockMMService, mockGameService, NewBrokerBackplane("instance1", NewInProcessBroker()), SessionPolicyMultiple)
+
+	client := &Client{
+		userId:       "user1",
+		deltaUpdates: true,
+		messages:     make(chan *WebsocketMessage, 1),
+	}
+	service.RegisterClient(client)
+
+	state := &game.Game{
+		GameId:     "game1",
+		GameStatus: game.GameStatusCompleted,
+		Winner:     "user1",
+		Player1:    &game.Player{UserId: "user1"},
+		Player2:    &game.Player{UserId: "user2"},
+		Moves:      []*game.Move{{UserId: "user1", Type: game.MoveTypeMove, Position: &game.Position{X: 4, Y: 8}}},
+	}
+	service.broadcastMoveApplied(state, "user1")
+
+	assert.Equal(t, EventTypeGameState, (<-client.messages).Type)
+}
+
+func TestHandleCancelMatchmaking(t *testing.T) {
+	mockMMService := new(MockMatchmakingService)
+	mockGameService := new(MockGameService)
+	service := NewWebsocketService(mockMMService, mockGameService, NewBrokerBackplane("instance1", NewInProcessBroker()), SessionPolicyMultiple)
+
+	client := &Client{
+		userId:   "user1",
+		messages: make(chan *WebsocketMessage, 1),
+	}
+	service.RegisterClient(client)
+
+	mockMMService.On("RemoveUser", "user1").Return()
+
+	message := &WebsocketMessage{Type: EventTypeCancelMatchmaking}
+	service.HandleMessage("user1", "", message)
+
+	receivedMessage := <-client.messages
+	assert.Equal(t, EventTypeMatchmakingCancelled, receivedMessage.Type)
+
+	mockMMService.AssertCalled(t, "RemoveUser", "user1")
+}
+
+func TestHandleQueueStatus(t *testing.T) {
+	mockMMService := new(MockMatchmakingService)
+	mockGameService := new(MockGameService)
+	service := NewWebsocketService(mockMMService, mockGameService, NewBrokerBackplane("instance1", NewInProcessBroker()), SessionPolicyMultiple)
+
+	client := &Client{
+		userId:   "user1",
+		messages: make(chan *WebsocketMessage, 1),
+	}
+	service.RegisterClient(client)
+
+	event := &events.Event{
+		Type: events.EventTypeQueueStatus,
+		Data: map[string]string{
+			"userId":        "user1",
+			"ranked":        "true",
+			"variant":       "standard",
+			"timeControl":   "blitz",
+			"waited":        "12",
+			"playersInPool": "4",
+			"estimatedWait": "30",
+		},
+	}
+	service.HandleQueueStatus(event)
+
+	receivedMessage := <-client.messages
+	assert.Equal(t, EventTypeQueueStatus, receivedMessage.Type)
+
+	var payload QueueStatusPayload
+	err := json.Unmarshal(receivedMessage.Payload, &payload)
+	assert.NoError(t, err)
+	assert.True(t, payload.Ranked)
+	assert.Equal(t, game.TimeControlBlitz, payload.TimeControl)
+	assert.Equal(t, 12, payload.WaitedSeconds)
+	assert.Equal(t, 4, payload.PlayersInPool)
+	assert.Equal(t, 30, *payload.EstimatedWaitSeconds)
+}
+
+func TestNewQueueStatusPayload(t *testing.T) {
+	estimatedWait := 45 * time.Second
+	status := &matchmaking.QueueStatus{
+		UserId:        "user1",
+		Settings:      game.DefaultGameSettings(),
+		Waited:        15 * time.Second,
+		PlayersInPool: 3,
+		EstimatedWait: &estimatedWait,
+	}
+
+	payload := newQueueStatusPayload(status)
+
+	assert.False(t, payload.Ranked)
+	assert.Equal(t, game.GameVariantStandard, payload.Variant)
+	assert.Equal(t, 15, payload.WaitedSeconds)
+	assert.Equal(t, 3, payload.PlayersInPool)
+	assert.Equal(t, 45, *payload.EstimatedWaitSeconds)
+}
+
+func TestHandleBotMatchFound(t *testing.T) {
+	mockMMService := new(MockMatchmakingService)
+	mockGameService := new(MockGameService)
+	service := NewWebsocketService(mockMMService, mockGameService, NewBrokerBackplane("instance1", NewInProcessBroker()), SessionPolicyMultiple)
+
+	botGame := &game.Game{
+		GameId:  "game1",
+		Bot:     true,
+		Player1: &game.Player{UserId: "user1"},
+		Player2: &game.Player{UserId: game.BOT_USER_ID_PREFIX + "1", Bot: true},
+	}
+	mockGameService.On("CreateBotGame", "user1", 1720.5, mock.Anything).Return(botGame, nil)
+
+	client := &Client{
+		userId:   "user1",
+		messages: make(chan *WebsocketMessage, 1),
+	}
+	service.RegisterClient(client)
+
+	event := &events.Event{
+		Type: events.EventTypeBotMatchFound,
+		Data: map[string]string{
+			"userId":      "user1",
+			"botRating":   "1720.5",
+			"ranked":      "false",
+			"variant":     "standard",
+			"timeControl": "blitz",
+		},
+	}
+	service.HandleBotMatchFound(event)
+
+	receivedMessage := <-client.messages
+	assert.Equal(t, EventTypeGameState, receivedMessage.Type)
+
+	var receivedGame game.Game
+	err := json.Unmarshal(receivedMessage.Payload, &receivedGame)
+	assert.NoError(t, err)
+	assert.True(t, receivedGame.Bot)
+
+	mockGameService.AssertCalled(t, "CreateBotGame", "user1", 1720.5, mock.MatchedBy(func(settings *game.GameSettings) bool {
+		return settings.TimeControl == game.TimeControlBlitz
+	}))
+}
@@ -0,0 +1,62 @@
+package users
+
+import "time"
+
+type User struct {
+	UserId       string       `bson:"_id" json:"user_id"`
+	Username     string       `bson:"username,omitempty" json:"username,omitempty"`
+	PasswordHash string       `bson:"password_hash,omitempty" json:"-"`
+	Guest        bool         `bson:"guest,omitempty" json:"guest,omitempty"` // guests have no credentials until they are upgraded
+	DisplayName  string       `bson:"display_name" json:"display_name"`
+	AvatarURL    string       `bson:"avatar_url,omitempty" json:"avatar_url,omitempty"`
+	Settings     UserSettings `bson:"settings" json:"settings"`
+	CreatedAt    time.Time    `bson:"created_at" json:"created_at"`
+	UpdatedAt    time.Time    `bson:"updated_at" json:"updated_at"`
+
+	// the user is never matched with these users
+	BlockedUserIds []string `bson:"blocked_user_ids,omitempty" json:"blocked_user_ids,omitempty"`
+}
+
+// what other users see, the settings and the blocked users are only returned to the user
+type UserProfile struct {
+	UserId      string    `json:"user_id"`
+	Username    string    `json:"username,omitempty"`
+	Guest       bool      `json:"guest,omitempty"`
+	DisplayName string    `json:"display_name"`
+	AvatarURL   string    `json:"avatar_url,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+func (user *User) Profile() *UserProfile {
+	return &UserProfile{
+		UserId:      user.UserId,
+		Username:    user.Username,
+		Guest:       user.Guest,
+		DisplayName: user.DisplayName,
+		AvatarURL:   user.AvatarURL,
+		CreatedAt:   user.CreatedAt,
+	}
+}
+
+type UserSettings struct {
+	SoundEnabled    bool   `bson:"sound_enabled" json:"sound_enabled"`
+	ShowCoordinates bool   `bson:"show_coordinates" json:"show_coordinates"`
+	BoardTheme      string `bson:"board_theme,omitempty" json:"board_theme,omitempty"`
+	Language        string `bson:"language,omitempty" json:"language,omitempty"`
+}
+
+type RegisterUserRequest struct {
+	Username    string `json:"username"`
+	Password    string `json:"password"`
+	DisplayName string `json:"display_name"`
+	AvatarURL   string `json:"avatar_url,omitempty"`
+}
+
+// only provided fields are updated
+type UpdateUserRequest struct {
+	DisplayName *string       `json:"display_name,omitempty"`
+	AvatarURL   *string       `json:"avatar_url,omitempty"`
+	Settings    *UserSettings `json:"settings,omitempty"`
+	// replaces the whole list
+	BlockedUserIds *[]string `json:"blocked_user_ids,omitempty"`
+}
@@ -0,0 +1,72 @@
+package users
+
+import (
+	"log"
+	"net/http"
+	"quoridor/internal/errors"
+
+	"github.com/gin-gonic/gin"
+)
+
+type UserHandler interface {
+	HandleRegisterUser(c *gin.Context)
+	HandleGetUser(c *gin.Context)
+	HandleUpdateUser(c *gin.Context)
+}
+
+type UserHandlerImpl struct {
+	service UserService
+}
+
+func NewUserHandler(service UserService) *UserHandlerImpl {
+	return &UserHandlerImpl{
+		service: service,
+	}
+}
+
+// POST https://quoridory.domain.io/v1/users
+func (handler *UserHandlerImpl) HandleRegisterUser(c *gin.Context) {
+	request := RegisterUserRequest{}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		log.Printf("Failed to unmarshal user registration request: err=%v", err)
+		c.JSON(http.StatusBadRequest, errors.ErrorResponse{ErrorType: errors.ErrBadRequest.Error()})
+		return
+	}
+
+	user, err := handler.service.RegisterUser(&request)
+	if err != nil {
+		c.JSON(errors.HttpStatus(err), errors.ErrorResponse{ErrorType: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, user)
+}
+
+// GET https://quoridory.domain.io/v1/users/1234
+func (handler *UserHandlerImpl) HandleGetUser(c *gin.Context) {
+	user, err := handler.service.GetUserById(c.Param("user_id"))
+	if err != nil {
+		c.JSON(errors.HttpStatus(err), errors.ErrorResponse{ErrorType: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, user.Profile())
+}
+
+// PATCH https://quoridory.domain.io/v1/users/1234, only the user can update their profile
+func (handler *UserHandlerImpl) HandleUpdateUser(c *gin.Context) {
+	request := UpdateUserRequest{}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		log.Printf("Failed to unmarshal user update request: err=%v", err)
+		c.JSON(http.StatusBadRequest, errors.ErrorResponse{ErrorType: errors.ErrBadRequest.Error()})
+		return
+	}
+
+	user, err := handler.service.UpdateUser(c.Param("user_id"), &request)
+	if err != nil {
+		c.JSON(errors.HttpStatus(err), errors.ErrorResponse{ErrorType: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
@@ -0,0 +1,129 @@
+package users
+
+import (
+	"context"
+	"log"
+	"quoridor/internal/errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type UserRepository interface {
+	SaveUser(user *User) error
+	GetUserById(userId string) (*User, error)
+	GetUserByUsername(username string) (*User, error)
+	GetUsersByIds(userIds []string) ([]*User, error)
+}
+
+type MongoUserRepository struct {
+	database   *mongo.Database
+	collection *mongo.Collection
+}
+
+func NewMongoUserRepository(database *mongo.Database, collectionName string) *MongoUserRepository {
+	collection := database.Collection(collectionName)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// usernames are unique, users without username are skipped by the sparse index
+	_, err := collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "username", Value: 1}},
+		Options: options.Index().SetUnique(true).SetSparse(true),
+	})
+	if err != nil {
+		log.Printf("Error creating username index: %v", err)
+	}
+
+	return &MongoUserRepository{
+		database:   database,
+		collection: collection,
+	}
+}
+
+// the unique index rejects a username taken by another user in the meantime, the service checks it only before saving
+func (r *MongoUserRepository) SaveUser(user *User) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := r.collection.ReplaceOne(
+		ctx,
+		bson.M{"_id": user.UserId},
+		user,
+		options.Replace().SetUpsert(true),
+	)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			log.Printf("Username is already taken: username=%v", user.Username)
+			return errors.ErrUsernameTaken
+		}
+		log.Printf("Error saving user: %v", err)
+		return err
+	}
+	return nil
+}
+
+func (r *MongoUserRepository) GetUserById(userId string) (*User, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var user User
+	err := r.collection.FindOne(ctx, bson.M{"_id": userId}).Decode(&user)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		log.Printf("Error loading user: %v", err)
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *MongoUserRepository) GetUserByUsername(username string) (*User, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var user User
+	err := r.collection.FindOne(ctx, bson.M{"username": username}).Decode(&user)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		log.Printf("Error loading user by username: %v", err)
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *MongoUserRepository) GetUsersByIds(userIds []string) ([]*User, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cursor, err := r.collection.Find(ctx, bson.M{"_id": bson.M{"$in": userIds}})
+	if err != nil {
+		log.Printf("Error loading users by ids: %v", err)
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	users := []*User{}
+	for cursor.Next(ctx) {
+		var user User
+		err := cursor.Decode(&user)
+		if err != nil {
+			log.Printf("Error decoding user: %v", err)
+			continue
+		}
+		users = append(users, &user)
+	}
+
+	if err := cursor.Err(); err != nil {
+		log.Printf("Cursor error: %v", err)
+		return nil, err
+	}
+
+	return users, nil
+}
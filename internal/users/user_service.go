@@ -0,0 +1,335 @@
+package users
+
+import (
+	"log"
+	"net/url"
+	"quoridor/internal/errors"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	MIN_DISPLAY_NAME_LENGTH = 3
+	MAX_DISPLAY_NAME_LENGTH = 32
+	MIN_PASSWORD_LENGTH     = 8
+	MAX_PASSWORD_LENGTH     = 72 // bcrypt ignores everything after 72 bytes
+	GUEST_NAME_PREFIX       = "Guest-"
+	MAX_BLOCKED_USERS       = 100
+)
+
+var usernamePattern = regexp.MustCompile(`^[a-zA-Z0-9_.-]{3,32}$`)
+
+type UserService interface {
+	RegisterUser(request *RegisterUserRequest) (*User, error)
+	RegisterGuest() (*User, error)
+	UpgradeGuest(userId string, request *RegisterUserRequest) (*User, error)
+	Authenticate(username, password string) (*User, error)
+	GetUserById(userId string) (*User, error)
+	GetUsersByIds(userIds []string) (map[string]*User, error)
+	UpdateUser(userId string, request *UpdateUserRequest) (*User, error)
+}
+
+type UserServiceImpl struct {
+	repository UserRepository
+}
+
+func NewUserService(repository UserRepository) *UserServiceImpl {
+	return &UserServiceImpl{
+		repository: repository,
+	}
+}
+
+func (service *UserServiceImpl) RegisterUser(request *RegisterUserRequest) (*User, error) {
+	log.Printf("Registering user: username=%v, displayName=%v", request.Username, request.DisplayName)
+
+	displayName := strings.TrimSpace(request.DisplayName)
+	if !usernamePattern.MatchString(request.Username) || !service.isPasswordValid(request.Password) ||
+		!service.isDisplayNameValid(displayName) || !service.isAvatarURLValid(request.AvatarURL) {
+		log.Printf("Invalid user registration request: username=%v, displayName=%v", request.Username, request.DisplayName)
+		return nil, errors.ErrBadRequest
+	}
+
+	passwordHash, err := service.hashCredentials(request.Username, request.Password)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	user := &User{
+		UserId:       uuid.NewString(),
+		Username:     request.Username,
+		PasswordHash: passwordHash,
+		DisplayName:  displayName,
+		AvatarURL:    request.AvatarURL,
+		Settings:     defaultSettings(),
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+
+	err = service.repository.SaveUser(user)
+	if err == errors.ErrUsernameTaken {
+		return nil, err
+	}
+	if err != nil {
+		log.Printf("Error while saving the user. err=%v", err)
+		return nil, errors.ErrInternalError
+	}
+
+	log.Printf("Registered user with id=%s", user.UserId)
+	return user, nil
+}
+
+// guests get a generated display name and can play right away without credentials
+func (service *UserServiceImpl) RegisterGuest() (*User, error) {
+	log.Println("Registering guest")
+
+	now := time.Now()
+	userId := uuid.NewString()
+	user := &User{
+		UserId:      userId,
+		Guest:       true,
+		DisplayName: GUEST_NAME_PREFIX + userId[:8],
+		Settings:    defaultSettings(),
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+
+	err := service.repository.SaveUser(user)
+	if err != nil {
+		log.Printf("Error while saving the guest. err=%v", err)
+		return nil, errors.ErrInternalError
+	}
+
+	log.Printf("Registered guest with id=%s", user.UserId)
+	return user, nil
+}
+
+/*
+turns the guest into a registered user with the same id, so the games played as a guest stay in the history.
+the display name and avatar are optional, the guest ones are kept when they are not provided
+*/
+func (service *UserServiceImpl) UpgradeGuest(userId string, request *RegisterUserRequest) (*User, error) {
+	log.Printf("Upgrading guest: userId=%v, username=%v", userId, request.Username)
+
+	displayName := strings.TrimSpace(request.DisplayName)
+	if !usernamePattern.MatchString(request.Username) || !service.isPasswordValid(request.Password) ||
+		(displayName != "" && !service.isDisplayNameValid(displayName)) || !service.isAvatarURLValid(request.AvatarURL) {
+		log.Printf("Invalid guest upgrade request: userId=%v, username=%v, displayName=%v", userId, request.Username, request.DisplayName)
+		return nil, errors.ErrBadRequest
+	}
+
+	user, err := service.GetUserById(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	if !user.Guest {
+		log.Printf("User with id=%s is not a guest", userId)
+		return nil, errors.ErrNotAGuest
+	}
+
+	passwordHash, err := service.hashCredentials(request.Username, request.Password)
+	if err != nil {
+		return nil, err
+	}
+
+	user.Guest = false
+	user.Username = request.Username
+	user.PasswordHash = passwordHash
+	if displayName != "" {
+		user.DisplayName = displayName
+	}
+	if request.AvatarURL != "" {
+		user.AvatarURL = request.AvatarURL
+	}
+	user.UpdatedAt = time.Now()
+
+	err = service.repository.SaveUser(user)
+	if err == errors.ErrUsernameTaken {
+		return nil, err
+	}
+	if err != nil {
+		log.Printf("Error while saving the user. userId=%v, err=%v", userId, err)
+		return nil, errors.ErrInternalError
+	}
+
+	log.Printf("Upgraded guest with id=%s to a registered user", userId)
+	return user, nil
+}
+
+// unknown username and wrong password result in the same error, so usernames can't be probed
+func (service *UserServiceImpl) Authenticate(username, password string) (*User, error) {
+	log.Printf("Authenticating user: username=%v", username)
+
+	user, err := service.repository.GetUserByUsername(username)
+	if err != nil {
+		log.Printf("Error while fetching user by username. err=%v", err)
+		return nil, errors.ErrInternalError
+	}
+
+	if user == nil || user.PasswordHash == "" {
+		log.Printf("User with username=%s not found", username)
+		return nil, errors.ErrInvalidCredentials
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
+		log.Printf("Invalid password for user with id=%s", user.UserId)
+		return nil, errors.ErrInvalidCredentials
+	}
+
+	return user, nil
+}
+
+func (service *UserServiceImpl) GetUserById(userId string) (*User, error) {
+	log.Printf("Fetching user by id: userId=%v", userId)
+
+	user, err := service.repository.GetUserById(userId)
+	if err != nil {
+		log.Printf("Error while fetching user. err=%v", err)
+		return nil, errors.ErrInternalError
+	}
+
+	if user == nil {
+		log.Printf("User with id=%s not found", userId)
+		return nil, errors.ErrUserNotFound
+	}
+
+	return user, nil
+}
+
+// users that don't exist are not included in the result
+func (service *UserServiceImpl) GetUsersByIds(userIds []string) (map[string]*User, error) {
+	log.Printf("Fetching users by ids: userIds=%v", userIds)
+
+	users, err := service.repository.GetUsersByIds(userIds)
+	if err != nil {
+		log.Printf("Error while fetching users. err=%v", err)
+		return nil, errors.ErrInternalError
+	}
+
+	usersById := make(map[string]*User, len(users))
+	for _, user := range users {
+		usersById[user.UserId] = user
+	}
+
+	return usersById, nil
+}
+
+func (service *UserServiceImpl) UpdateUser(userId string, request *UpdateUserRequest) (*User, error) {
+	log.Printf("Updating user: userId=%v", userId)
+
+	user, err := service.GetUserById(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	if request.DisplayName != nil {
+		displayName := strings.TrimSpace(*request.DisplayName)
+		if !service.isDisplayNameValid(displayName) {
+			log.Printf("Invalid display name: userId=%v, displayName=%v", userId, *request.DisplayName)
+			return nil, errors.ErrBadRequest
+		}
+		user.DisplayName = displayName
+	}
+
+	if request.AvatarURL != nil {
+		if !service.isAvatarURLValid(*request.AvatarURL) {
+			log.Printf("Invalid avatar url: userId=%v, avatarURL=%v", userId, *request.AvatarURL)
+			return nil, errors.ErrBadRequest
+		}
+		user.AvatarURL = *request.AvatarURL
+	}
+
+	if request.Settings != nil {
+		user.Settings = *request.Settings
+	}
+
+	if request.BlockedUserIds != nil {
+		if !service.isBlockedUsersListValid(userId, *request.BlockedUserIds) {
+			log.Printf("Invalid blocked users: userId=%v, blockedUserIds=%v", userId, *request.BlockedUserIds)
+			return nil, errors.ErrBadRequest
+		}
+		user.BlockedUserIds = *request.BlockedUserIds
+	}
+
+	user.UpdatedAt = time.Now()
+
+	err = service.repository.SaveUser(user)
+	if err != nil {
+		log.Printf("Error while saving the user. userId=%v, err=%v", userId, err)
+		return nil, errors.ErrInternalError
+	}
+
+	log.Printf("Updated user with id=%s", userId)
+	return user, nil
+}
+
+// checks that the username is not taken and returns the hash of the password
+func (service *UserServiceImpl) hashCredentials(username, password string) (string, error) {
+	existing, err := service.repository.GetUserByUsername(username)
+	if err != nil {
+		log.Printf("Error while fetching user by username. err=%v", err)
+		return "", errors.ErrInternalError
+	}
+
+	if existing != nil {
+		log.Printf("Username is already taken: username=%v", username)
+		return "", errors.ErrUsernameTaken
+	}
+
+	passwordHash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		log.Printf("Error while hashing the password. err=%v", err)
+		return "", errors.ErrInternalError
+	}
+
+	return string(passwordHash), nil
+}
+
+func defaultSettings() UserSettings {
+	return UserSettings{
+		SoundEnabled:    true,
+		ShowCoordinates: true,
+	}
+}
+
+func (service *UserServiceImpl) isDisplayNameValid(displayName string) bool {
+	length := utf8.RuneCountInString(displayName)
+	return length >= MIN_DISPLAY_NAME_LENGTH && length <= MAX_DISPLAY_NAME_LENGTH
+}
+
+func (service *UserServiceImpl) isBlockedUsersListValid(userId string, blockedUserIds []string) bool {
+	if len(blockedUserIds) > MAX_BLOCKED_USERS {
+		return false
+	}
+
+	for _, blockedUserId := range blockedUserIds {
+		if blockedUserId == "" || blockedUserId == userId {
+			return false
+		}
+	}
+	return true
+}
+
+func (service *UserServiceImpl) isPasswordValid(password string) bool {
+	return len(password) >= MIN_PASSWORD_LENGTH && len(password) <= MAX_PASSWORD_LENGTH
+}
+
+// avatar is optional, but has to be an absolute http(s) url when provided
+func (service *UserServiceImpl) isAvatarURLValid(avatarURL string) bool {
+	if avatarURL == "" {
+		return true
+	}
+
+	parsed, err := url.Parse(avatarURL)
+	if err != nil {
+		return false
+	}
+
+	return (parsed.Scheme == "http" || parsed.Scheme == "https") && parsed.Host != ""
+}
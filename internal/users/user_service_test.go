@@ -0,0 +1,326 @@
+package users
+
+import (
+	"strings"
+	"testing"
+
+	"quoridor/internal/errors"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type MockUserRepository struct {
+	mock.Mock
+}
+
+func (m *MockUserRepository) SaveUser(user *User) error {
+	args := m.Called(user)
+	return args.Error(0)
+}
+
+func (m *MockUserRepository) GetUserById(userId string) (*User, error) {
+	args := m.Called(userId)
+	return args.Get(0).(*User), args.Error(1)
+}
+
+func (m *MockUserRepository) GetUserByUsername(username string) (*User, error) {
+	args := m.Called(username)
+	return args.Get(0).(*User), args.Error(1)
+}
+
+func (m *MockUserRepository) GetUsersByIds(userIds []string) ([]*User, error) {
+	args := m.Called(userIds)
+	return args.Get(0).([]*User), args.Error(1)
+}
+
+func TestRegisterUser(t *testing.T) {
+	repo := new(MockUserRepository)
+	service := NewUserService(repo)
+
+	repo.On("GetUserByUsername", "alice").Return((*User)(nil), nil)
+	repo.On("SaveUser", mock.Anything).Return(nil)
+
+	request := &RegisterUserRequest{Username: "alice", Password: "password123", DisplayName: "  Alice ", AvatarURL: "https://cdn.domain.io/alice.png"}
+	user, err := service.RegisterUser(request)
+
+	assert.NoError(t, err)
+	assert.NotEmpty(t, user.UserId)
+	assert.Equal(t, "alice", user.Username)
+	assert.NoError(t, bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte("password123")))
+	assert.Equal(t, "Alice", user.DisplayName)
+	assert.Equal(t, "https://cdn.domain.io/alice.png", user.AvatarURL)
+	assert.True(t, user.Settings.SoundEnabled)
+	assert.NotEmpty(t, user.CreatedAt)
+
+	repo.AssertCalled(t, "SaveUser", user)
+}
+
+func TestRegisterUser_givenInvalidRequest_shouldReturnError(t *testing.T) {
+	repo := new(MockUserRepository)
+	service := NewUserService(repo)
+
+	requests := []*RegisterUserRequest{
+		{Username: "alice", Password: "password123", DisplayName: "Al"},
+		{Username: "alice", Password: "password123", DisplayName: "   "},
+		{Username: "alice", Password: "password123", DisplayName: "A display name that is way too long"},
+		{Username: "alice", Password: "password123", DisplayName: "Alice", AvatarURL: "javascript:alert(1)"},
+		{Username: "alice", Password: "password123", DisplayName: "Alice", AvatarURL: "/relative.png"},
+		{Username: "", Password: "password123", DisplayName: "Alice"},
+		{Username: "al ice", Password: "password123", DisplayName: "Alice"},
+		{Username: "alice", Password: "short", DisplayName: "Alice"},
+	}
+
+	for _, request := range requests {
+		_, err := service.RegisterUser(request)
+		assert.ErrorIs(t, err, errors.ErrBadRequest)
+	}
+
+	repo.AssertNotCalled(t, "SaveUser", mock.Anything)
+}
+
+func TestRegisterUser_givenSaveError_shouldReturnError(t *testing.T) {
+	repo := new(MockUserRepository)
+	service := NewUserService(repo)
+
+	repo.On("GetUserByUsername", "alice").Return((*User)(nil), nil)
+	repo.On("SaveUser", mock.Anything).Return(assert.AnError)
+
+	user, err := service.RegisterUser(&RegisterUserRequest{Username: "alice", Password: "password123", DisplayName: "Alice"})
+
+	assert.ErrorIs(t, err, errors.ErrInternalError)
+	assert.Nil(t, user)
+}
+
+func TestRegisterUser_givenTakenUsername_shouldReturnError(t *testing.T) {
+	repo := new(MockUserRepository)
+	service := NewUserService(repo)
+
+	repo.On("GetUserByUsername", "alice").Return(&User{UserId: "user1", Username: "alice"}, nil)
+
+	user, err := service.RegisterUser(&RegisterUserRequest{Username: "alice", Password: "password123", DisplayName: "Alice"})
+
+	assert.ErrorIs(t, err, errors.ErrUsernameTaken)
+	assert.Nil(t, user)
+	repo.AssertNotCalled(t, "SaveUser", mock.Anything)
+}
+
+func TestRegisterUser_givenUsernameTakenWhileSaving_shouldReturnError(t *testing.T) {
+	repo := new(MockUserRepository)
+	service := NewUserService(repo)
+
+	repo.On("GetUserByUsername", "alice").Return((*User)(nil), nil)
+	repo.On("SaveUser", mock.Anything).Return(errors.ErrUsernameTaken)
+
+	user, err := service.RegisterUser(&RegisterUserRequest{Username: "alice", Password: "password123", DisplayName: "Alice"})
+
+	assert.ErrorIs(t, err, errors.ErrUsernameTaken)
+	assert.Nil(t, user)
+}
+
+func TestAuthenticate(t *testing.T) {
+	repo := new(MockUserRepository)
+	service := NewUserService(repo)
+
+	passwordHash, _ := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.MinCost)
+	repo.On("GetUserByUsername", "alice").Return(&User{UserId: "user1", Username: "alice", PasswordHash: string(passwordHash)}, nil)
+
+	user, err := service.Authenticate("alice", "password123")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "user1", user.UserId)
+}
+
+func TestAuthenticate_givenInvalidCredentials_shouldReturnError(t *testing.T) {
+	repo := new(MockUserRepository)
+	service := NewUserService(repo)
+
+	passwordHash, _ := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.MinCost)
+	repo.On("GetUserByUsername", "alice").Return(&User{UserId: "user1", Username: "alice", PasswordHash: string(passwordHash)}, nil)
+	repo.On("GetUserByUsername", "bob").Return((*User)(nil), nil)
+
+	_, err := service.Authenticate("alice", "wrong password")
+	assert.ErrorIs(t, err, errors.ErrInvalidCredentials)
+
+	_, err = service.Authenticate("bob", "password123")
+	assert.ErrorIs(t, err, errors.ErrInvalidCredentials)
+}
+
+func TestGetUserById_givenNonExistentUser_shouldReturnError(t *testing.T) {
+	repo := new(MockUserRepository)
+	service := NewUserService(repo)
+
+	repo.On("GetUserById", "user1").Return((*User)(nil), nil)
+
+	user, err := service.GetUserById("user1")
+
+	assert.ErrorIs(t, err, errors.ErrUserNotFound)
+	assert.Nil(t, user)
+}
+
+func TestGetUsersByIds(t *testing.T) {
+	repo := new(MockUserRepository)
+	service := NewUserService(repo)
+
+	repo.On("GetUsersByIds", []string{"user1", "user2"}).Return([]*User{
+		{UserId: "user1", DisplayName: "Alice"},
+	}, nil)
+
+	users, err := service.GetUsersByIds([]string{"user1", "user2"})
+
+	assert.NoError(t, err)
+	assert.Len(t, users, 1)
+	assert.Equal(t, "Alice", users["user1"].DisplayName)
+}
+
+func TestUpdateUser(t *testing.T) {
+	repo := new(MockUserRepository)
+	service := NewUserService(repo)
+
+	user := &User{UserId: "user1", DisplayName: "Alice", AvatarURL: "https://cdn.domain.io/alice.png"}
+	repo.On("GetUserById", "user1").Return(user, nil)
+	repo.On("SaveUser", mock.Anything).Return(nil)
+
+	displayName := "Alice B."
+	settings := &UserSettings{SoundEnabled: false, BoardTheme: "dark"}
+	updated, err := service.UpdateUser("user1", &UpdateUserRequest{DisplayName: &displayName, Settings: settings})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "Alice B.", updated.DisplayName)
+	assert.Equal(t, "https://cdn.domain.io/alice.png", updated.AvatarURL)
+	assert.Equal(t, *settings, updated.Settings)
+	assert.NotEmpty(t, updated.UpdatedAt)
+	repo.AssertCalled(t, "SaveUser", user)
+}
+
+func TestUpdateUser_givenInvalidDisplayName_shouldReturnError(t *testing.T) {
+	repo := new(MockUserRepository)
+	service := NewUserService(repo)
+
+	repo.On("GetUserById", "user1").Return(&User{UserId: "user1", DisplayName: "Alice"}, nil)
+
+	displayName := "A"
+	_, err := service.UpdateUser("user1", &UpdateUserRequest{DisplayName: &displayName})
+
+	assert.ErrorIs(t, err, errors.ErrBadRequest)
+	repo.AssertNotCalled(t, "SaveUser", mock.Anything)
+}
+
+func TestUpdateUser_givenBlockedUsers_shouldReplaceList(t *testing.T) {
+	repo := new(MockUserRepository)
+	service := NewUserService(repo)
+
+	user := &User{UserId: "user1", DisplayName: "Alice", BlockedUserIds: []string{"user2"}}
+	repo.On("GetUserById", "user1").Return(user, nil)
+	repo.On("SaveUser", mock.Anything).Return(nil)
+
+	blocked := []string{"user3", "user4"}
+	updated, err := service.UpdateUser("user1", &UpdateUserRequest{BlockedUserIds: &blocked})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"user3", "user4"}, updated.BlockedUserIds)
+	repo.AssertCalled(t, "SaveUser", user)
+}
+
+func TestUpdateUser_givenUserBlocksThemselves_shouldReturnError(t *testing.T) {
+	repo := new(MockUserRepository)
+	service := NewUserService(repo)
+
+	repo.On("GetUserById", "user1").Return(&User{UserId: "user1", DisplayName: "Alice"}, nil)
+
+	blocked := []string{"user2", "user1"}
+	_, err := service.UpdateUser("user1", &UpdateUserRequest{BlockedUserIds: &blocked})
+
+	assert.ErrorIs(t, err, errors.ErrBadRequest)
+	repo.AssertNotCalled(t, "SaveUser", mock.Anything)
+}
+
+func TestRegisterGuest(t *testing.T) {
+	repo := new(MockUserRepository)
+	service := NewUserService(repo)
+
+	repo.On("SaveUser", mock.Anything).Return(nil)
+
+	user, err := service.RegisterGuest()
+
+	assert.NoError(t, err)
+	assert.NotEmpty(t, user.UserId)
+	assert.True(t, user.Guest)
+	assert.Empty(t, user.Username)
+	assert.Empty(t, user.PasswordHash)
+	assert.True(t, strings.HasPrefix(user.DisplayName, GUEST_NAME_PREFIX))
+	repo.AssertCalled(t, "SaveUser", user)
+}
+
+func TestUpgradeGuest(t *testing.T) {
+	repo := new(MockUserRepository)
+	service := NewUserService(repo)
+
+	guest := &User{UserId: "guest1", Guest: true, DisplayName: "Guest-1234abcd"}
+	repo.On("GetUserById", "guest1").Return(guest, nil)
+	repo.On("GetUserByUsername", "alice").Return((*User)(nil), nil)
+	repo.On("SaveUser", mock.Anything).Return(nil)
+
+	user, err := service.UpgradeGuest("guest1", &RegisterUserRequest{Username: "alice", Password: "password123", DisplayName: "Alice"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "guest1", user.UserId)
+	assert.False(t, user.Guest)
+	assert.Equal(t, "alice", user.Username)
+	assert.Equal(t, "Alice", user.DisplayName)
+	assert.NoError(t, bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte("password123")))
+	repo.AssertCalled(t, "SaveUser", guest)
+}
+
+func TestUpgradeGuest_givenNoDisplayName_shouldKeepGuestName(t *testing.T) {
+	repo := new(MockUserRepository)
+	service := NewUserService(repo)
+
+	repo.On("GetUserById", "guest1").Return(&User{UserId: "guest1", Guest: true, DisplayName: "Guest-1234abcd"}, nil)
+	repo.On("GetUserByUsername", "alice").Return((*User)(nil), nil)
+	repo.On("SaveUser", mock.Anything).Return(nil)
+
+	user, err := service.UpgradeGuest("guest1", &RegisterUserRequest{Username: "alice", Password: "password123"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "Guest-1234abcd", user.DisplayName)
+}
+
+func TestUpgradeGuest_givenRegisteredUser_shouldReturnError(t *testing.T) {
+	repo := new(MockUserRepository)
+	service := NewUserService(repo)
+
+	repo.On("GetUserById", "user1").Return(&User{UserId: "user1", Username: "bob", DisplayName: "Bob"}, nil)
+
+	_, err := service.UpgradeGuest("user1", &RegisterUserRequest{Username: "alice", Password: "password123"})
+
+	assert.ErrorIs(t, err, errors.ErrNotAGuest)
+	repo.AssertNotCalled(t, "SaveUser", mock.Anything)
+}
+
+func TestUpgradeGuest_givenTakenUsername_shouldReturnError(t *testing.T) {
+	repo := new(MockUserRepository)
+	service := NewUserService(repo)
+
+	repo.On("GetUserById", "guest1").Return(&User{UserId: "guest1", Guest: true, DisplayName: "Guest-1234abcd"}, nil)
+	repo.On("GetUserByUsername", "alice").Return(&User{UserId: "user1", Username: "alice"}, nil)
+
+	_, err := service.UpgradeGuest("guest1", &RegisterUserRequest{Username: "alice", Password: "password123"})
+
+	assert.ErrorIs(t, err, errors.ErrUsernameTaken)
+	repo.AssertNotCalled(t, "SaveUser", mock.Anything)
+}
+
+func TestUpgradeGuest_givenUsernameTakenWhileSaving_shouldReturnError(t *testing.T) {
+	repo := new(MockUserRepository)
+	service := NewUserService(repo)
+
+	repo.On("GetUserById", "guest1").Return(&User{UserId: "guest1", Guest: true, DisplayName: "Guest-1234abcd"}, nil)
+	repo.On("GetUserByUsername", "alice").Return((*User)(nil), nil)
+	repo.On("SaveUser", mock.Anything).Return(errors.ErrUsernameTaken)
+
+	_, err := service.UpgradeGuest("guest1", &RegisterUserRequest{Username: "alice", Password: "password123"})
+
+	assert.ErrorIs(t, err, errors.ErrUsernameTaken)
+}